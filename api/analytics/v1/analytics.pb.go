@@ -0,0 +1,1731 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: analytics/v1/analytics.proto
+
+// Analytics API for internal services. It mirrors the /v1 HTTP endpoints and
+// adds streaming: live updates out, transactions in.
+
+package analyticsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type RankBy int32
+
+const (
+	RankBy_RANK_BY_UNSPECIFIED RankBy = 0
+	RankBy_RANK_BY_ORDERS      RankBy = 1
+	RankBy_RANK_BY_SPEND       RankBy = 2
+)
+
+// Enum value maps for RankBy.
+var (
+	RankBy_name = map[int32]string{
+		0: "RANK_BY_UNSPECIFIED",
+		1: "RANK_BY_ORDERS",
+		2: "RANK_BY_SPEND",
+	}
+	RankBy_value = map[string]int32{
+		"RANK_BY_UNSPECIFIED": 0,
+		"RANK_BY_ORDERS":      1,
+		"RANK_BY_SPEND":       2,
+	}
+)
+
+func (x RankBy) Enum() *RankBy {
+	p := new(RankBy)
+	*p = x
+	return p
+}
+
+func (x RankBy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RankBy) Descriptor() protoreflect.EnumDescriptor {
+	return file_analytics_v1_analytics_proto_enumTypes[0].Descriptor()
+}
+
+func (RankBy) Type() protoreflect.EnumType {
+	return &file_analytics_v1_analytics_proto_enumTypes[0]
+}
+
+func (x RankBy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RankBy.Descriptor instead.
+func (RankBy) EnumDescriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{0}
+}
+
+type SortKey int32
+
+const (
+	SortKey_SORT_KEY_UNSPECIFIED     SortKey = 0 // Sorts by orders
+	SortKey_SORT_KEY_ORDERS          SortKey = 1
+	SortKey_SORT_KEY_SPEND           SortKey = 2
+	SortKey_SORT_KEY_AVG_ORDER_VALUE SortKey = 3
+	SortKey_SORT_KEY_LAST_UPDATED    SortKey = 4
+)
+
+// Enum value maps for SortKey.
+var (
+	SortKey_name = map[int32]string{
+		0: "SORT_KEY_UNSPECIFIED",
+		1: "SORT_KEY_ORDERS",
+		2: "SORT_KEY_SPEND",
+		3: "SORT_KEY_AVG_ORDER_VALUE",
+		4: "SORT_KEY_LAST_UPDATED",
+	}
+	SortKey_value = map[string]int32{
+		"SORT_KEY_UNSPECIFIED":     0,
+		"SORT_KEY_ORDERS":          1,
+		"SORT_KEY_SPEND":           2,
+		"SORT_KEY_AVG_ORDER_VALUE": 3,
+		"SORT_KEY_LAST_UPDATED":    4,
+	}
+)
+
+func (x SortKey) Enum() *SortKey {
+	p := new(SortKey)
+	*p = x
+	return p
+}
+
+func (x SortKey) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SortKey) Descriptor() protoreflect.EnumDescriptor {
+	return file_analytics_v1_analytics_proto_enumTypes[1].Descriptor()
+}
+
+func (SortKey) Type() protoreflect.EnumType {
+	return &file_analytics_v1_analytics_proto_enumTypes[1]
+}
+
+func (x SortKey) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SortKey.Descriptor instead.
+func (SortKey) EnumDescriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{1}
+}
+
+type EventType int32
+
+const (
+	EventType_EVENT_TYPE_UNSPECIFIED EventType = 0
+	EventType_EVENT_TYPE_ANALYTICS   EventType = 1
+	EventType_EVENT_TYPE_LEADERBOARD EventType = 2
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "EVENT_TYPE_UNSPECIFIED",
+		1: "EVENT_TYPE_ANALYTICS",
+		2: "EVENT_TYPE_LEADERBOARD",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_TYPE_UNSPECIFIED": 0,
+		"EVENT_TYPE_ANALYTICS":   1,
+		"EVENT_TYPE_LEADERBOARD": 2,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_analytics_v1_analytics_proto_enumTypes[2].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_analytics_v1_analytics_proto_enumTypes[2]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{2}
+}
+
+type GetUserAnalyticsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserAnalyticsRequest) Reset() {
+	*x = GetUserAnalyticsRequest{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserAnalyticsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserAnalyticsRequest) ProtoMessage() {}
+
+func (x *GetUserAnalyticsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserAnalyticsRequest.ProtoReflect.Descriptor instead.
+func (*GetUserAnalyticsRequest) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetUserAnalyticsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type UserAnalytics struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	TotalOrders   int64                  `protobuf:"varint,2,opt,name=total_orders,json=totalOrders,proto3" json:"total_orders,omitempty"`
+	TotalSpent    float64                `protobuf:"fixed64,3,opt,name=total_spent,json=totalSpent,proto3" json:"total_spent,omitempty"`
+	FirstOrderAt  *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=first_order_at,json=firstOrderAt,proto3" json:"first_order_at,omitempty"` // Unset if no order had a timestamp
+	LastOrderAt   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_order_at,json=lastOrderAt,proto3" json:"last_order_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserAnalytics) Reset() {
+	*x = UserAnalytics{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserAnalytics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserAnalytics) ProtoMessage() {}
+
+func (x *UserAnalytics) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserAnalytics.ProtoReflect.Descriptor instead.
+func (*UserAnalytics) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *UserAnalytics) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UserAnalytics) GetTotalOrders() int64 {
+	if x != nil {
+		return x.TotalOrders
+	}
+	return 0
+}
+
+func (x *UserAnalytics) GetTotalSpent() float64 {
+	if x != nil {
+		return x.TotalSpent
+	}
+	return 0
+}
+
+func (x *UserAnalytics) GetFirstOrderAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstOrderAt
+	}
+	return nil
+}
+
+func (x *UserAnalytics) GetLastOrderAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastOrderAt
+	}
+	return nil
+}
+
+type UserSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	TotalOrders   int64                  `protobuf:"varint,2,opt,name=total_orders,json=totalOrders,proto3" json:"total_orders,omitempty"`
+	TotalSpent    float64                `protobuf:"fixed64,3,opt,name=total_spent,json=totalSpent,proto3" json:"total_spent,omitempty"`
+	FirstOrderAt  *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=first_order_at,json=firstOrderAt,proto3" json:"first_order_at,omitempty"`
+	LastOrderAt   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_order_at,json=lastOrderAt,proto3" json:"last_order_at,omitempty"`
+	AvgOrderValue float64                `protobuf:"fixed64,6,opt,name=avg_order_value,json=avgOrderValue,proto3" json:"avg_order_value,omitempty"`
+	LastUpdated   *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"` // Unset when served from the leaderboard
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserSummary) Reset() {
+	*x = UserSummary{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserSummary) ProtoMessage() {}
+
+func (x *UserSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserSummary.ProtoReflect.Descriptor instead.
+func (*UserSummary) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UserSummary) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UserSummary) GetTotalOrders() int64 {
+	if x != nil {
+		return x.TotalOrders
+	}
+	return 0
+}
+
+func (x *UserSummary) GetTotalSpent() float64 {
+	if x != nil {
+		return x.TotalSpent
+	}
+	return 0
+}
+
+func (x *UserSummary) GetFirstOrderAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstOrderAt
+	}
+	return nil
+}
+
+func (x *UserSummary) GetLastOrderAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastOrderAt
+	}
+	return nil
+}
+
+func (x *UserSummary) GetAvgOrderValue() float64 {
+	if x != nil {
+		return x.AvgOrderValue
+	}
+	return 0
+}
+
+func (x *UserSummary) GetLastUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return nil
+}
+
+// UserFilter narrows a listing; unset fields don't filter
+type UserFilter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinOrders     *int64                 `protobuf:"varint,1,opt,name=min_orders,json=minOrders,proto3,oneof" json:"min_orders,omitempty"`
+	MaxOrders     *int64                 `protobuf:"varint,2,opt,name=max_orders,json=maxOrders,proto3,oneof" json:"max_orders,omitempty"`
+	MinSpent      *float64               `protobuf:"fixed64,3,opt,name=min_spent,json=minSpent,proto3,oneof" json:"min_spent,omitempty"`
+	MaxSpent      *float64               `protobuf:"fixed64,4,opt,name=max_spent,json=maxSpent,proto3,oneof" json:"max_spent,omitempty"`
+	UpdatedSince  *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=updated_since,json=updatedSince,proto3" json:"updated_since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserFilter) Reset() {
+	*x = UserFilter{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserFilter) ProtoMessage() {}
+
+func (x *UserFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserFilter.ProtoReflect.Descriptor instead.
+func (*UserFilter) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UserFilter) GetMinOrders() int64 {
+	if x != nil && x.MinOrders != nil {
+		return *x.MinOrders
+	}
+	return 0
+}
+
+func (x *UserFilter) GetMaxOrders() int64 {
+	if x != nil && x.MaxOrders != nil {
+		return *x.MaxOrders
+	}
+	return 0
+}
+
+func (x *UserFilter) GetMinSpent() float64 {
+	if x != nil && x.MinSpent != nil {
+		return *x.MinSpent
+	}
+	return 0
+}
+
+func (x *UserFilter) GetMaxSpent() float64 {
+	if x != nil && x.MaxSpent != nil {
+		return *x.MaxSpent
+	}
+	return 0
+}
+
+func (x *UserFilter) GetUpdatedSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedSince
+	}
+	return nil
+}
+
+type ListTopUsersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sort          SortKey                `protobuf:"varint,1,opt,name=sort,proto3,enum=txprocessor.analytics.v1.SortKey" json:"sort,omitempty"`
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`  // 1 to 1000, default 10
+	Cursor        string                 `protobuf:"bytes,3,opt,name=cursor,proto3" json:"cursor,omitempty"` // next_cursor from the previous page, for the same sort
+	Filter        *UserFilter            `protobuf:"bytes,4,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTopUsersRequest) Reset() {
+	*x = ListTopUsersRequest{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTopUsersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTopUsersRequest) ProtoMessage() {}
+
+func (x *ListTopUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTopUsersRequest.ProtoReflect.Descriptor instead.
+func (*ListTopUsersRequest) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListTopUsersRequest) GetSort() SortKey {
+	if x != nil {
+		return x.Sort
+	}
+	return SortKey_SORT_KEY_UNSPECIFIED
+}
+
+func (x *ListTopUsersRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListTopUsersRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *ListTopUsersRequest) GetFilter() *UserFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type ListTopUsersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Users         []*UserSummary         `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTopUsersResponse) Reset() {
+	*x = ListTopUsersResponse{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTopUsersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTopUsersResponse) ProtoMessage() {}
+
+func (x *ListTopUsersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTopUsersResponse.ProtoReflect.Descriptor instead.
+func (*ListTopUsersResponse) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListTopUsersResponse) GetUsers() []*UserSummary {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *ListTopUsersResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type ListAnomaliesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAnomaliesRequest) Reset() {
+	*x = ListAnomaliesRequest{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAnomaliesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAnomaliesRequest) ProtoMessage() {}
+
+func (x *ListAnomaliesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAnomaliesRequest.ProtoReflect.Descriptor instead.
+func (*ListAnomaliesRequest) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{6}
+}
+
+type AnomalyUser struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	TotalOrders     int64                  `protobuf:"varint,2,opt,name=total_orders,json=totalOrders,proto3" json:"total_orders,omitempty"`
+	TotalSpent      float64                `protobuf:"fixed64,3,opt,name=total_spent,json=totalSpent,proto3" json:"total_spent,omitempty"`
+	OrderAnomaly    bool                   `protobuf:"varint,4,opt,name=order_anomaly,json=orderAnomaly,proto3" json:"order_anomaly,omitempty"`
+	SpendingAnomaly bool                   `protobuf:"varint,5,opt,name=spending_anomaly,json=spendingAnomaly,proto3" json:"spending_anomaly,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *AnomalyUser) Reset() {
+	*x = AnomalyUser{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnomalyUser) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnomalyUser) ProtoMessage() {}
+
+func (x *AnomalyUser) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnomalyUser.ProtoReflect.Descriptor instead.
+func (*AnomalyUser) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AnomalyUser) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *AnomalyUser) GetTotalOrders() int64 {
+	if x != nil {
+		return x.TotalOrders
+	}
+	return 0
+}
+
+func (x *AnomalyUser) GetTotalSpent() float64 {
+	if x != nil {
+		return x.TotalSpent
+	}
+	return 0
+}
+
+func (x *AnomalyUser) GetOrderAnomaly() bool {
+	if x != nil {
+		return x.OrderAnomaly
+	}
+	return false
+}
+
+func (x *AnomalyUser) GetSpendingAnomaly() bool {
+	if x != nil {
+		return x.SpendingAnomaly
+	}
+	return false
+}
+
+type ListAnomaliesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Anomalies     []*AnomalyUser         `protobuf:"bytes,1,rep,name=anomalies,proto3" json:"anomalies,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAnomaliesResponse) Reset() {
+	*x = ListAnomaliesResponse{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAnomaliesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAnomaliesResponse) ProtoMessage() {}
+
+func (x *ListAnomaliesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAnomaliesResponse.ProtoReflect.Descriptor instead.
+func (*ListAnomaliesResponse) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListAnomaliesResponse) GetAnomalies() []*AnomalyUser {
+	if x != nil {
+		return x.Anomalies
+	}
+	return nil
+}
+
+// Unset fields take the server's configured velocity rule
+type ListVelocityAnomaliesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Window        *durationpb.Duration   `protobuf:"bytes,1,opt,name=window,proto3" json:"window,omitempty"`
+	MinOrders     *int64                 `protobuf:"varint,2,opt,name=min_orders,json=minOrders,proto3,oneof" json:"min_orders,omitempty"`
+	MinSpent      *float64               `protobuf:"fixed64,3,opt,name=min_spent,json=minSpent,proto3,oneof" json:"min_spent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListVelocityAnomaliesRequest) Reset() {
+	*x = ListVelocityAnomaliesRequest{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListVelocityAnomaliesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVelocityAnomaliesRequest) ProtoMessage() {}
+
+func (x *ListVelocityAnomaliesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVelocityAnomaliesRequest.ProtoReflect.Descriptor instead.
+func (*ListVelocityAnomaliesRequest) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListVelocityAnomaliesRequest) GetWindow() *durationpb.Duration {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+func (x *ListVelocityAnomaliesRequest) GetMinOrders() int64 {
+	if x != nil && x.MinOrders != nil {
+		return *x.MinOrders
+	}
+	return 0
+}
+
+func (x *ListVelocityAnomaliesRequest) GetMinSpent() float64 {
+	if x != nil && x.MinSpent != nil {
+		return *x.MinSpent
+	}
+	return 0
+}
+
+type VelocityAnomaly struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	WindowEnd       *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=window_end,json=windowEnd,proto3" json:"window_end,omitempty"`
+	Orders          int64                  `protobuf:"varint,3,opt,name=orders,proto3" json:"orders,omitempty"`
+	Spent           float64                `protobuf:"fixed64,4,opt,name=spent,proto3" json:"spent,omitempty"`
+	OrderAnomaly    bool                   `protobuf:"varint,5,opt,name=order_anomaly,json=orderAnomaly,proto3" json:"order_anomaly,omitempty"`
+	SpendingAnomaly bool                   `protobuf:"varint,6,opt,name=spending_anomaly,json=spendingAnomaly,proto3" json:"spending_anomaly,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *VelocityAnomaly) Reset() {
+	*x = VelocityAnomaly{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VelocityAnomaly) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VelocityAnomaly) ProtoMessage() {}
+
+func (x *VelocityAnomaly) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VelocityAnomaly.ProtoReflect.Descriptor instead.
+func (*VelocityAnomaly) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *VelocityAnomaly) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *VelocityAnomaly) GetWindowEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WindowEnd
+	}
+	return nil
+}
+
+func (x *VelocityAnomaly) GetOrders() int64 {
+	if x != nil {
+		return x.Orders
+	}
+	return 0
+}
+
+func (x *VelocityAnomaly) GetSpent() float64 {
+	if x != nil {
+		return x.Spent
+	}
+	return 0
+}
+
+func (x *VelocityAnomaly) GetOrderAnomaly() bool {
+	if x != nil {
+		return x.OrderAnomaly
+	}
+	return false
+}
+
+func (x *VelocityAnomaly) GetSpendingAnomaly() bool {
+	if x != nil {
+		return x.SpendingAnomaly
+	}
+	return false
+}
+
+type ListVelocityAnomaliesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Window        *durationpb.Duration   `protobuf:"bytes,1,opt,name=window,proto3" json:"window,omitempty"`
+	MinOrders     int64                  `protobuf:"varint,2,opt,name=min_orders,json=minOrders,proto3" json:"min_orders,omitempty"`
+	MinSpent      float64                `protobuf:"fixed64,3,opt,name=min_spent,json=minSpent,proto3" json:"min_spent,omitempty"`
+	Anomalies     []*VelocityAnomaly     `protobuf:"bytes,4,rep,name=anomalies,proto3" json:"anomalies,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListVelocityAnomaliesResponse) Reset() {
+	*x = ListVelocityAnomaliesResponse{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListVelocityAnomaliesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVelocityAnomaliesResponse) ProtoMessage() {}
+
+func (x *ListVelocityAnomaliesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVelocityAnomaliesResponse.ProtoReflect.Descriptor instead.
+func (*ListVelocityAnomaliesResponse) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListVelocityAnomaliesResponse) GetWindow() *durationpb.Duration {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+func (x *ListVelocityAnomaliesResponse) GetMinOrders() int64 {
+	if x != nil {
+		return x.MinOrders
+	}
+	return 0
+}
+
+func (x *ListVelocityAnomaliesResponse) GetMinSpent() float64 {
+	if x != nil {
+		return x.MinSpent
+	}
+	return 0
+}
+
+func (x *ListVelocityAnomaliesResponse) GetAnomalies() []*VelocityAnomaly {
+	if x != nil {
+		return x.Anomalies
+	}
+	return nil
+}
+
+type WatchEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Types         []EventType            `protobuf:"varint,1,rep,packed,name=types,proto3,enum=txprocessor.analytics.v1.EventType" json:"types,omitempty"` // Empty receives every type
+	UserIds       []string               `protobuf:"bytes,2,rep,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`                              // Restricts analytics deltas to these users
+	By            RankBy                 `protobuf:"varint,3,opt,name=by,proto3,enum=txprocessor.analytics.v1.RankBy" json:"by,omitempty"`                 // Restricts leaderboard changes to one ranking
+	Top           int32                  `protobuf:"varint,4,opt,name=top,proto3" json:"top,omitempty"`                                                    // Only leaderboard changes within the top N
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsRequest) Reset() {
+	*x = WatchEventsRequest{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsRequest) ProtoMessage() {}
+
+func (x *WatchEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsRequest.ProtoReflect.Descriptor instead.
+func (*WatchEventsRequest) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *WatchEventsRequest) GetTypes() []EventType {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *WatchEventsRequest) GetUserIds() []string {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+func (x *WatchEventsRequest) GetBy() RankBy {
+	if x != nil {
+		return x.By
+	}
+	return RankBy_RANK_BY_UNSPECIFIED
+}
+
+func (x *WatchEventsRequest) GetTop() int32 {
+	if x != nil {
+		return x.Top
+	}
+	return 0
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"` // Unset on EventsDropped
+	At    *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=at,proto3" json:"at,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Event_Analytics
+	//	*Event_Leaderboard
+	//	*Event_Dropped
+	Payload       isEvent_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Event) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Event) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+func (x *Event) GetPayload() isEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetAnalytics() *AnalyticsUpdate {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Analytics); ok {
+			return x.Analytics
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetLeaderboard() *LeaderboardChange {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Leaderboard); ok {
+			return x.Leaderboard
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetDropped() *EventsDropped {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Dropped); ok {
+			return x.Dropped
+		}
+	}
+	return nil
+}
+
+type isEvent_Payload interface {
+	isEvent_Payload()
+}
+
+type Event_Analytics struct {
+	Analytics *AnalyticsUpdate `protobuf:"bytes,3,opt,name=analytics,proto3,oneof"`
+}
+
+type Event_Leaderboard struct {
+	Leaderboard *LeaderboardChange `protobuf:"bytes,4,opt,name=leaderboard,proto3,oneof"`
+}
+
+type Event_Dropped struct {
+	Dropped *EventsDropped `protobuf:"bytes,5,opt,name=dropped,proto3,oneof"`
+}
+
+func (*Event_Analytics) isEvent_Payload() {}
+
+func (*Event_Leaderboard) isEvent_Payload() {}
+
+func (*Event_Dropped) isEvent_Payload() {}
+
+// AnalyticsUpdate carries one committed batch's per-user changes
+type AnalyticsUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Deltas        []*UserDelta           `protobuf:"bytes,1,rep,name=deltas,proto3" json:"deltas,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyticsUpdate) Reset() {
+	*x = AnalyticsUpdate{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyticsUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyticsUpdate) ProtoMessage() {}
+
+func (x *AnalyticsUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyticsUpdate.ProtoReflect.Descriptor instead.
+func (*AnalyticsUpdate) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *AnalyticsUpdate) GetDeltas() []*UserDelta {
+	if x != nil {
+		return x.Deltas
+	}
+	return nil
+}
+
+type UserDelta struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Orders        int64                  `protobuf:"varint,2,opt,name=orders,proto3" json:"orders,omitempty"`
+	Spent         float64                `protobuf:"fixed64,3,opt,name=spent,proto3" json:"spent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserDelta) Reset() {
+	*x = UserDelta{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserDelta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserDelta) ProtoMessage() {}
+
+func (x *UserDelta) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserDelta.ProtoReflect.Descriptor instead.
+func (*UserDelta) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UserDelta) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UserDelta) GetOrders() int64 {
+	if x != nil {
+		return x.Orders
+	}
+	return 0
+}
+
+func (x *UserDelta) GetSpent() float64 {
+	if x != nil {
+		return x.Spent
+	}
+	return 0
+}
+
+type LeaderboardChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	By            RankBy                 `protobuf:"varint,1,opt,name=by,proto3,enum=txprocessor.analytics.v1.RankBy" json:"by,omitempty"`
+	Users         []*UserSummary         `protobuf:"bytes,2,rep,name=users,proto3" json:"users,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LeaderboardChange) Reset() {
+	*x = LeaderboardChange{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeaderboardChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaderboardChange) ProtoMessage() {}
+
+func (x *LeaderboardChange) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaderboardChange.ProtoReflect.Descriptor instead.
+func (*LeaderboardChange) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *LeaderboardChange) GetBy() RankBy {
+	if x != nil {
+		return x.By
+	}
+	return RankBy_RANK_BY_UNSPECIFIED
+}
+
+func (x *LeaderboardChange) GetUsers() []*UserSummary {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+// EventsDropped reports events lost because the client read too slowly;
+// the client should refetch current state
+type EventsDropped struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         uint64                 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventsDropped) Reset() {
+	*x = EventsDropped{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventsDropped) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventsDropped) ProtoMessage() {}
+
+func (x *EventsDropped) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventsDropped.ProtoReflect.Descriptor instead.
+func (*EventsDropped) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *EventsDropped) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type Transaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OrderId       string                 `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // Required
+	ProductId     string                 `protobuf:"bytes,3,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Quantity      int64                  `protobuf:"varint,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Price         float64                `protobuf:"fixed64,5,opt,name=price,proto3" json:"price,omitempty"` // Per unit
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Transaction) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *Transaction) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Transaction) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *Transaction) GetQuantity() int64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *Transaction) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Transaction) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type PushTransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      int64                  `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Batches       int64                  `protobuf:"varint,2,opt,name=batches,proto3" json:"batches,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PushTransactionsResponse) Reset() {
+	*x = PushTransactionsResponse{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PushTransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushTransactionsResponse) ProtoMessage() {}
+
+func (x *PushTransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushTransactionsResponse.ProtoReflect.Descriptor instead.
+func (*PushTransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PushTransactionsResponse) GetAccepted() int64 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *PushTransactionsResponse) GetBatches() int64 {
+	if x != nil {
+		return x.Batches
+	}
+	return 0
+}
+
+var File_analytics_v1_analytics_proto protoreflect.FileDescriptor
+
+const file_analytics_v1_analytics_proto_rawDesc = "" +
+	"\n" +
+	"\x1canalytics/v1/analytics.proto\x12\x18txprocessor.analytics.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"2\n" +
+	"\x17GetUserAnalyticsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"\xee\x01\n" +
+	"\rUserAnalytics\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12!\n" +
+	"\ftotal_orders\x18\x02 \x01(\x03R\vtotalOrders\x12\x1f\n" +
+	"\vtotal_spent\x18\x03 \x01(\x01R\n" +
+	"totalSpent\x12@\n" +
+	"\x0efirst_order_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ffirstOrderAt\x12>\n" +
+	"\rlast_order_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vlastOrderAt\"\xd3\x02\n" +
+	"\vUserSummary\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12!\n" +
+	"\ftotal_orders\x18\x02 \x01(\x03R\vtotalOrders\x12\x1f\n" +
+	"\vtotal_spent\x18\x03 \x01(\x01R\n" +
+	"totalSpent\x12@\n" +
+	"\x0efirst_order_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ffirstOrderAt\x12>\n" +
+	"\rlast_order_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vlastOrderAt\x12&\n" +
+	"\x0favg_order_value\x18\x06 \x01(\x01R\ravgOrderValue\x12=\n" +
+	"\flast_updated\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\vlastUpdated\"\x93\x02\n" +
+	"\n" +
+	"UserFilter\x12\"\n" +
+	"\n" +
+	"min_orders\x18\x01 \x01(\x03H\x00R\tminOrders\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"max_orders\x18\x02 \x01(\x03H\x01R\tmaxOrders\x88\x01\x01\x12 \n" +
+	"\tmin_spent\x18\x03 \x01(\x01H\x02R\bminSpent\x88\x01\x01\x12 \n" +
+	"\tmax_spent\x18\x04 \x01(\x01H\x03R\bmaxSpent\x88\x01\x01\x12?\n" +
+	"\rupdated_since\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\fupdatedSinceB\r\n" +
+	"\v_min_ordersB\r\n" +
+	"\v_max_ordersB\f\n" +
+	"\n" +
+	"_min_spentB\f\n" +
+	"\n" +
+	"_max_spent\"\xb8\x01\n" +
+	"\x13ListTopUsersRequest\x125\n" +
+	"\x04sort\x18\x01 \x01(\x0e2!.txprocessor.analytics.v1.SortKeyR\x04sort\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x03 \x01(\tR\x06cursor\x12<\n" +
+	"\x06filter\x18\x04 \x01(\v2$.txprocessor.analytics.v1.UserFilterR\x06filter\"t\n" +
+	"\x14ListTopUsersResponse\x12;\n" +
+	"\x05users\x18\x01 \x03(\v2%.txprocessor.analytics.v1.UserSummaryR\x05users\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\"\x16\n" +
+	"\x14ListAnomaliesRequest\"\xba\x01\n" +
+	"\vAnomalyUser\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12!\n" +
+	"\ftotal_orders\x18\x02 \x01(\x03R\vtotalOrders\x12\x1f\n" +
+	"\vtotal_spent\x18\x03 \x01(\x01R\n" +
+	"totalSpent\x12#\n" +
+	"\rorder_anomaly\x18\x04 \x01(\bR\forderAnomaly\x12)\n" +
+	"\x10spending_anomaly\x18\x05 \x01(\bR\x0fspendingAnomaly\"\\\n" +
+	"\x15ListAnomaliesResponse\x12C\n" +
+	"\tanomalies\x18\x01 \x03(\v2%.txprocessor.analytics.v1.AnomalyUserR\tanomalies\"\xb4\x01\n" +
+	"\x1cListVelocityAnomaliesRequest\x121\n" +
+	"\x06window\x18\x01 \x01(\v2\x19.google.protobuf.DurationR\x06window\x12\"\n" +
+	"\n" +
+	"min_orders\x18\x02 \x01(\x03H\x00R\tminOrders\x88\x01\x01\x12 \n" +
+	"\tmin_spent\x18\x03 \x01(\x01H\x01R\bminSpent\x88\x01\x01B\r\n" +
+	"\v_min_ordersB\f\n" +
+	"\n" +
+	"_min_spent\"\xe3\x01\n" +
+	"\x0fVelocityAnomaly\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x129\n" +
+	"\n" +
+	"window_end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\twindowEnd\x12\x16\n" +
+	"\x06orders\x18\x03 \x01(\x03R\x06orders\x12\x14\n" +
+	"\x05spent\x18\x04 \x01(\x01R\x05spent\x12#\n" +
+	"\rorder_anomaly\x18\x05 \x01(\bR\forderAnomaly\x12)\n" +
+	"\x10spending_anomaly\x18\x06 \x01(\bR\x0fspendingAnomaly\"\xd7\x01\n" +
+	"\x1dListVelocityAnomaliesResponse\x121\n" +
+	"\x06window\x18\x01 \x01(\v2\x19.google.protobuf.DurationR\x06window\x12\x1d\n" +
+	"\n" +
+	"min_orders\x18\x02 \x01(\x03R\tminOrders\x12\x1b\n" +
+	"\tmin_spent\x18\x03 \x01(\x01R\bminSpent\x12G\n" +
+	"\tanomalies\x18\x04 \x03(\v2).txprocessor.analytics.v1.VelocityAnomalyR\tanomalies\"\xae\x01\n" +
+	"\x12WatchEventsRequest\x129\n" +
+	"\x05types\x18\x01 \x03(\x0e2#.txprocessor.analytics.v1.EventTypeR\x05types\x12\x19\n" +
+	"\buser_ids\x18\x02 \x03(\tR\auserIds\x120\n" +
+	"\x02by\x18\x03 \x01(\x0e2 .txprocessor.analytics.v1.RankByR\x02by\x12\x10\n" +
+	"\x03top\x18\x04 \x01(\x05R\x03top\"\xaf\x02\n" +
+	"\x05Event\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12*\n" +
+	"\x02at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02at\x12I\n" +
+	"\tanalytics\x18\x03 \x01(\v2).txprocessor.analytics.v1.AnalyticsUpdateH\x00R\tanalytics\x12O\n" +
+	"\vleaderboard\x18\x04 \x01(\v2+.txprocessor.analytics.v1.LeaderboardChangeH\x00R\vleaderboard\x12C\n" +
+	"\adropped\x18\x05 \x01(\v2'.txprocessor.analytics.v1.EventsDroppedH\x00R\adroppedB\t\n" +
+	"\apayload\"N\n" +
+	"\x0fAnalyticsUpdate\x12;\n" +
+	"\x06deltas\x18\x01 \x03(\v2#.txprocessor.analytics.v1.UserDeltaR\x06deltas\"R\n" +
+	"\tUserDelta\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x16\n" +
+	"\x06orders\x18\x02 \x01(\x03R\x06orders\x12\x14\n" +
+	"\x05spent\x18\x03 \x01(\x01R\x05spent\"\x82\x01\n" +
+	"\x11LeaderboardChange\x120\n" +
+	"\x02by\x18\x01 \x01(\x0e2 .txprocessor.analytics.v1.RankByR\x02by\x12;\n" +
+	"\x05users\x18\x02 \x03(\v2%.txprocessor.analytics.v1.UserSummaryR\x05users\"%\n" +
+	"\rEventsDropped\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x04R\x05count\"\xcc\x01\n" +
+	"\vTransaction\x12\x19\n" +
+	"\border_id\x18\x01 \x01(\tR\aorderId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x03 \x01(\tR\tproductId\x12\x1a\n" +
+	"\bquantity\x18\x04 \x01(\x03R\bquantity\x12\x14\n" +
+	"\x05price\x18\x05 \x01(\x01R\x05price\x128\n" +
+	"\ttimestamp\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"P\n" +
+	"\x18PushTransactionsResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\x03R\baccepted\x12\x18\n" +
+	"\abatches\x18\x02 \x01(\x03R\abatches*H\n" +
+	"\x06RankBy\x12\x17\n" +
+	"\x13RANK_BY_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eRANK_BY_ORDERS\x10\x01\x12\x11\n" +
+	"\rRANK_BY_SPEND\x10\x02*\x85\x01\n" +
+	"\aSortKey\x12\x18\n" +
+	"\x14SORT_KEY_UNSPECIFIED\x10\x00\x12\x13\n" +
+	"\x0fSORT_KEY_ORDERS\x10\x01\x12\x12\n" +
+	"\x0eSORT_KEY_SPEND\x10\x02\x12\x1c\n" +
+	"\x18SORT_KEY_AVG_ORDER_VALUE\x10\x03\x12\x19\n" +
+	"\x15SORT_KEY_LAST_UPDATED\x10\x04*]\n" +
+	"\tEventType\x12\x1a\n" +
+	"\x16EVENT_TYPE_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14EVENT_TYPE_ANALYTICS\x10\x01\x12\x1a\n" +
+	"\x16EVENT_TYPE_LEADERBOARD\x10\x022\xbf\x05\n" +
+	"\x10AnalyticsService\x12n\n" +
+	"\x10GetUserAnalytics\x121.txprocessor.analytics.v1.GetUserAnalyticsRequest\x1a'.txprocessor.analytics.v1.UserAnalytics\x12m\n" +
+	"\fListTopUsers\x12-.txprocessor.analytics.v1.ListTopUsersRequest\x1a..txprocessor.analytics.v1.ListTopUsersResponse\x12p\n" +
+	"\rListAnomalies\x12..txprocessor.analytics.v1.ListAnomaliesRequest\x1a/.txprocessor.analytics.v1.ListAnomaliesResponse\x12\x88\x01\n" +
+	"\x15ListVelocityAnomalies\x126.txprocessor.analytics.v1.ListVelocityAnomaliesRequest\x1a7.txprocessor.analytics.v1.ListVelocityAnomaliesResponse\x12^\n" +
+	"\vWatchEvents\x12,.txprocessor.analytics.v1.WatchEventsRequest\x1a\x1f.txprocessor.analytics.v1.Event0\x01\x12o\n" +
+	"\x10PushTransactions\x12%.txprocessor.analytics.v1.Transaction\x1a2.txprocessor.analytics.v1.PushTransactionsResponse(\x01B+Z)tx-processor/api/analytics/v1;analyticsv1b\x06proto3"
+
+var (
+	file_analytics_v1_analytics_proto_rawDescOnce sync.Once
+	file_analytics_v1_analytics_proto_rawDescData []byte
+)
+
+func file_analytics_v1_analytics_proto_rawDescGZIP() []byte {
+	file_analytics_v1_analytics_proto_rawDescOnce.Do(func() {
+		file_analytics_v1_analytics_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_analytics_v1_analytics_proto_rawDesc), len(file_analytics_v1_analytics_proto_rawDesc)))
+	})
+	return file_analytics_v1_analytics_proto_rawDescData
+}
+
+var file_analytics_v1_analytics_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_analytics_v1_analytics_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_analytics_v1_analytics_proto_goTypes = []any{
+	(RankBy)(0),                           // 0: txprocessor.analytics.v1.RankBy
+	(SortKey)(0),                          // 1: txprocessor.analytics.v1.SortKey
+	(EventType)(0),                        // 2: txprocessor.analytics.v1.EventType
+	(*GetUserAnalyticsRequest)(nil),       // 3: txprocessor.analytics.v1.GetUserAnalyticsRequest
+	(*UserAnalytics)(nil),                 // 4: txprocessor.analytics.v1.UserAnalytics
+	(*UserSummary)(nil),                   // 5: txprocessor.analytics.v1.UserSummary
+	(*UserFilter)(nil),                    // 6: txprocessor.analytics.v1.UserFilter
+	(*ListTopUsersRequest)(nil),           // 7: txprocessor.analytics.v1.ListTopUsersRequest
+	(*ListTopUsersResponse)(nil),          // 8: txprocessor.analytics.v1.ListTopUsersResponse
+	(*ListAnomaliesRequest)(nil),          // 9: txprocessor.analytics.v1.ListAnomaliesRequest
+	(*AnomalyUser)(nil),                   // 10: txprocessor.analytics.v1.AnomalyUser
+	(*ListAnomaliesResponse)(nil),         // 11: txprocessor.analytics.v1.ListAnomaliesResponse
+	(*ListVelocityAnomaliesRequest)(nil),  // 12: txprocessor.analytics.v1.ListVelocityAnomaliesRequest
+	(*VelocityAnomaly)(nil),               // 13: txprocessor.analytics.v1.VelocityAnomaly
+	(*ListVelocityAnomaliesResponse)(nil), // 14: txprocessor.analytics.v1.ListVelocityAnomaliesResponse
+	(*WatchEventsRequest)(nil),            // 15: txprocessor.analytics.v1.WatchEventsRequest
+	(*Event)(nil),                         // 16: txprocessor.analytics.v1.Event
+	(*AnalyticsUpdate)(nil),               // 17: txprocessor.analytics.v1.AnalyticsUpdate
+	(*UserDelta)(nil),                     // 18: txprocessor.analytics.v1.UserDelta
+	(*LeaderboardChange)(nil),             // 19: txprocessor.analytics.v1.LeaderboardChange
+	(*EventsDropped)(nil),                 // 20: txprocessor.analytics.v1.EventsDropped
+	(*Transaction)(nil),                   // 21: txprocessor.analytics.v1.Transaction
+	(*PushTransactionsResponse)(nil),      // 22: txprocessor.analytics.v1.PushTransactionsResponse
+	(*timestamppb.Timestamp)(nil),         // 23: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),           // 24: google.protobuf.Duration
+}
+var file_analytics_v1_analytics_proto_depIdxs = []int32{
+	23, // 0: txprocessor.analytics.v1.UserAnalytics.first_order_at:type_name -> google.protobuf.Timestamp
+	23, // 1: txprocessor.analytics.v1.UserAnalytics.last_order_at:type_name -> google.protobuf.Timestamp
+	23, // 2: txprocessor.analytics.v1.UserSummary.first_order_at:type_name -> google.protobuf.Timestamp
+	23, // 3: txprocessor.analytics.v1.UserSummary.last_order_at:type_name -> google.protobuf.Timestamp
+	23, // 4: txprocessor.analytics.v1.UserSummary.last_updated:type_name -> google.protobuf.Timestamp
+	23, // 5: txprocessor.analytics.v1.UserFilter.updated_since:type_name -> google.protobuf.Timestamp
+	1,  // 6: txprocessor.analytics.v1.ListTopUsersRequest.sort:type_name -> txprocessor.analytics.v1.SortKey
+	6,  // 7: txprocessor.analytics.v1.ListTopUsersRequest.filter:type_name -> txprocessor.analytics.v1.UserFilter
+	5,  // 8: txprocessor.analytics.v1.ListTopUsersResponse.users:type_name -> txprocessor.analytics.v1.UserSummary
+	10, // 9: txprocessor.analytics.v1.ListAnomaliesResponse.anomalies:type_name -> txprocessor.analytics.v1.AnomalyUser
+	24, // 10: txprocessor.analytics.v1.ListVelocityAnomaliesRequest.window:type_name -> google.protobuf.Duration
+	23, // 11: txprocessor.analytics.v1.VelocityAnomaly.window_end:type_name -> google.protobuf.Timestamp
+	24, // 12: txprocessor.analytics.v1.ListVelocityAnomaliesResponse.window:type_name -> google.protobuf.Duration
+	13, // 13: txprocessor.analytics.v1.ListVelocityAnomaliesResponse.anomalies:type_name -> txprocessor.analytics.v1.VelocityAnomaly
+	2,  // 14: txprocessor.analytics.v1.WatchEventsRequest.types:type_name -> txprocessor.analytics.v1.EventType
+	0,  // 15: txprocessor.analytics.v1.WatchEventsRequest.by:type_name -> txprocessor.analytics.v1.RankBy
+	23, // 16: txprocessor.analytics.v1.Event.at:type_name -> google.protobuf.Timestamp
+	17, // 17: txprocessor.analytics.v1.Event.analytics:type_name -> txprocessor.analytics.v1.AnalyticsUpdate
+	19, // 18: txprocessor.analytics.v1.Event.leaderboard:type_name -> txprocessor.analytics.v1.LeaderboardChange
+	20, // 19: txprocessor.analytics.v1.Event.dropped:type_name -> txprocessor.analytics.v1.EventsDropped
+	18, // 20: txprocessor.analytics.v1.AnalyticsUpdate.deltas:type_name -> txprocessor.analytics.v1.UserDelta
+	0,  // 21: txprocessor.analytics.v1.LeaderboardChange.by:type_name -> txprocessor.analytics.v1.RankBy
+	5,  // 22: txprocessor.analytics.v1.LeaderboardChange.users:type_name -> txprocessor.analytics.v1.UserSummary
+	23, // 23: txprocessor.analytics.v1.Transaction.timestamp:type_name -> google.protobuf.Timestamp
+	3,  // 24: txprocessor.analytics.v1.AnalyticsService.GetUserAnalytics:input_type -> txprocessor.analytics.v1.GetUserAnalyticsRequest
+	7,  // 25: txprocessor.analytics.v1.AnalyticsService.ListTopUsers:input_type -> txprocessor.analytics.v1.ListTopUsersRequest
+	9,  // 26: txprocessor.analytics.v1.AnalyticsService.ListAnomalies:input_type -> txprocessor.analytics.v1.ListAnomaliesRequest
+	12, // 27: txprocessor.analytics.v1.AnalyticsService.ListVelocityAnomalies:input_type -> txprocessor.analytics.v1.ListVelocityAnomaliesRequest
+	15, // 28: txprocessor.analytics.v1.AnalyticsService.WatchEvents:input_type -> txprocessor.analytics.v1.WatchEventsRequest
+	21, // 29: txprocessor.analytics.v1.AnalyticsService.PushTransactions:input_type -> txprocessor.analytics.v1.Transaction
+	4,  // 30: txprocessor.analytics.v1.AnalyticsService.GetUserAnalytics:output_type -> txprocessor.analytics.v1.UserAnalytics
+	8,  // 31: txprocessor.analytics.v1.AnalyticsService.ListTopUsers:output_type -> txprocessor.analytics.v1.ListTopUsersResponse
+	11, // 32: txprocessor.analytics.v1.AnalyticsService.ListAnomalies:output_type -> txprocessor.analytics.v1.ListAnomaliesResponse
+	14, // 33: txprocessor.analytics.v1.AnalyticsService.ListVelocityAnomalies:output_type -> txprocessor.analytics.v1.ListVelocityAnomaliesResponse
+	16, // 34: txprocessor.analytics.v1.AnalyticsService.WatchEvents:output_type -> txprocessor.analytics.v1.Event
+	22, // 35: txprocessor.analytics.v1.AnalyticsService.PushTransactions:output_type -> txprocessor.analytics.v1.PushTransactionsResponse
+	30, // [30:36] is the sub-list for method output_type
+	24, // [24:30] is the sub-list for method input_type
+	24, // [24:24] is the sub-list for extension type_name
+	24, // [24:24] is the sub-list for extension extendee
+	0,  // [0:24] is the sub-list for field type_name
+}
+
+func init() { file_analytics_v1_analytics_proto_init() }
+func file_analytics_v1_analytics_proto_init() {
+	if File_analytics_v1_analytics_proto != nil {
+		return
+	}
+	file_analytics_v1_analytics_proto_msgTypes[3].OneofWrappers = []any{}
+	file_analytics_v1_analytics_proto_msgTypes[9].OneofWrappers = []any{}
+	file_analytics_v1_analytics_proto_msgTypes[13].OneofWrappers = []any{
+		(*Event_Analytics)(nil),
+		(*Event_Leaderboard)(nil),
+		(*Event_Dropped)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_analytics_v1_analytics_proto_rawDesc), len(file_analytics_v1_analytics_proto_rawDesc)),
+			NumEnums:      3,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_analytics_v1_analytics_proto_goTypes,
+		DependencyIndexes: file_analytics_v1_analytics_proto_depIdxs,
+		EnumInfos:         file_analytics_v1_analytics_proto_enumTypes,
+		MessageInfos:      file_analytics_v1_analytics_proto_msgTypes,
+	}.Build()
+	File_analytics_v1_analytics_proto = out.File
+	file_analytics_v1_analytics_proto_goTypes = nil
+	file_analytics_v1_analytics_proto_depIdxs = nil
+}
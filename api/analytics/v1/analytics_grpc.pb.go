@@ -0,0 +1,335 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: analytics/v1/analytics.proto
+
+// Analytics API for internal services. It mirrors the /v1 HTTP endpoints and
+// adds streaming: live updates out, transactions in.
+
+package analyticsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AnalyticsService_GetUserAnalytics_FullMethodName      = "/txprocessor.analytics.v1.AnalyticsService/GetUserAnalytics"
+	AnalyticsService_ListTopUsers_FullMethodName          = "/txprocessor.analytics.v1.AnalyticsService/ListTopUsers"
+	AnalyticsService_ListAnomalies_FullMethodName         = "/txprocessor.analytics.v1.AnalyticsService/ListAnomalies"
+	AnalyticsService_ListVelocityAnomalies_FullMethodName = "/txprocessor.analytics.v1.AnalyticsService/ListVelocityAnomalies"
+	AnalyticsService_WatchEvents_FullMethodName           = "/txprocessor.analytics.v1.AnalyticsService/WatchEvents"
+	AnalyticsService_PushTransactions_FullMethodName      = "/txprocessor.analytics.v1.AnalyticsService/PushTransactions"
+)
+
+// AnalyticsServiceClient is the client API for AnalyticsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AnalyticsServiceClient interface {
+	// GetUserAnalytics returns a user's totals, or NOT_FOUND for unknown users
+	GetUserAnalytics(ctx context.Context, in *GetUserAnalyticsRequest, opts ...grpc.CallOption) (*UserAnalytics, error)
+	// ListTopUsers pages through users by a sort key, highest first
+	ListTopUsers(ctx context.Context, in *ListTopUsersRequest, opts ...grpc.CallOption) (*ListTopUsersResponse, error)
+	// ListAnomalies returns users whose lifetime totals are outliers
+	ListAnomalies(ctx context.Context, in *ListAnomaliesRequest, opts ...grpc.CallOption) (*ListAnomaliesResponse, error)
+	// ListVelocityAnomalies returns users whose activity within a window broke a threshold
+	ListVelocityAnomalies(ctx context.Context, in *ListVelocityAnomaliesRequest, opts ...grpc.CallOption) (*ListVelocityAnomaliesResponse, error)
+	// WatchEvents streams live analytics deltas and leaderboard changes until
+	// the client cancels or the server shuts down. A client that reads too
+	// slowly loses events and receives an EventsDropped where they were lost.
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	// PushTransactions ingests a stream of transactions, committing them in
+	// batches. The response counts what was committed once the client closes
+	// its side; on error, batches committed before it stay committed. A server
+	// shutting down commits what it has received and responds early, so a
+	// client that sent more than was accepted resumes from that count.
+	PushTransactions(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Transaction, PushTransactionsResponse], error)
+}
+
+type analyticsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAnalyticsServiceClient(cc grpc.ClientConnInterface) AnalyticsServiceClient {
+	return &analyticsServiceClient{cc}
+}
+
+func (c *analyticsServiceClient) GetUserAnalytics(ctx context.Context, in *GetUserAnalyticsRequest, opts ...grpc.CallOption) (*UserAnalytics, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserAnalytics)
+	err := c.cc.Invoke(ctx, AnalyticsService_GetUserAnalytics_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *analyticsServiceClient) ListTopUsers(ctx context.Context, in *ListTopUsersRequest, opts ...grpc.CallOption) (*ListTopUsersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTopUsersResponse)
+	err := c.cc.Invoke(ctx, AnalyticsService_ListTopUsers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *analyticsServiceClient) ListAnomalies(ctx context.Context, in *ListAnomaliesRequest, opts ...grpc.CallOption) (*ListAnomaliesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAnomaliesResponse)
+	err := c.cc.Invoke(ctx, AnalyticsService_ListAnomalies_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *analyticsServiceClient) ListVelocityAnomalies(ctx context.Context, in *ListVelocityAnomaliesRequest, opts ...grpc.CallOption) (*ListVelocityAnomaliesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListVelocityAnomaliesResponse)
+	err := c.cc.Invoke(ctx, AnalyticsService_ListVelocityAnomalies_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *analyticsServiceClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AnalyticsService_ServiceDesc.Streams[0], AnalyticsService_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AnalyticsService_WatchEventsClient = grpc.ServerStreamingClient[Event]
+
+func (c *analyticsServiceClient) PushTransactions(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Transaction, PushTransactionsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AnalyticsService_ServiceDesc.Streams[1], AnalyticsService_PushTransactions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Transaction, PushTransactionsResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AnalyticsService_PushTransactionsClient = grpc.ClientStreamingClient[Transaction, PushTransactionsResponse]
+
+// AnalyticsServiceServer is the server API for AnalyticsService service.
+// All implementations must embed UnimplementedAnalyticsServiceServer
+// for forward compatibility.
+type AnalyticsServiceServer interface {
+	// GetUserAnalytics returns a user's totals, or NOT_FOUND for unknown users
+	GetUserAnalytics(context.Context, *GetUserAnalyticsRequest) (*UserAnalytics, error)
+	// ListTopUsers pages through users by a sort key, highest first
+	ListTopUsers(context.Context, *ListTopUsersRequest) (*ListTopUsersResponse, error)
+	// ListAnomalies returns users whose lifetime totals are outliers
+	ListAnomalies(context.Context, *ListAnomaliesRequest) (*ListAnomaliesResponse, error)
+	// ListVelocityAnomalies returns users whose activity within a window broke a threshold
+	ListVelocityAnomalies(context.Context, *ListVelocityAnomaliesRequest) (*ListVelocityAnomaliesResponse, error)
+	// WatchEvents streams live analytics deltas and leaderboard changes until
+	// the client cancels or the server shuts down. A client that reads too
+	// slowly loses events and receives an EventsDropped where they were lost.
+	WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[Event]) error
+	// PushTransactions ingests a stream of transactions, committing them in
+	// batches. The response counts what was committed once the client closes
+	// its side; on error, batches committed before it stay committed. A server
+	// shutting down commits what it has received and responds early, so a
+	// client that sent more than was accepted resumes from that count.
+	PushTransactions(grpc.ClientStreamingServer[Transaction, PushTransactionsResponse]) error
+	mustEmbedUnimplementedAnalyticsServiceServer()
+}
+
+// UnimplementedAnalyticsServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAnalyticsServiceServer struct{}
+
+func (UnimplementedAnalyticsServiceServer) GetUserAnalytics(context.Context, *GetUserAnalyticsRequest) (*UserAnalytics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserAnalytics not implemented")
+}
+func (UnimplementedAnalyticsServiceServer) ListTopUsers(context.Context, *ListTopUsersRequest) (*ListTopUsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTopUsers not implemented")
+}
+func (UnimplementedAnalyticsServiceServer) ListAnomalies(context.Context, *ListAnomaliesRequest) (*ListAnomaliesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAnomalies not implemented")
+}
+func (UnimplementedAnalyticsServiceServer) ListVelocityAnomalies(context.Context, *ListVelocityAnomaliesRequest) (*ListVelocityAnomaliesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVelocityAnomalies not implemented")
+}
+func (UnimplementedAnalyticsServiceServer) WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedAnalyticsServiceServer) PushTransactions(grpc.ClientStreamingServer[Transaction, PushTransactionsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method PushTransactions not implemented")
+}
+func (UnimplementedAnalyticsServiceServer) mustEmbedUnimplementedAnalyticsServiceServer() {}
+func (UnimplementedAnalyticsServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeAnalyticsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AnalyticsServiceServer will
+// result in compilation errors.
+type UnsafeAnalyticsServiceServer interface {
+	mustEmbedUnimplementedAnalyticsServiceServer()
+}
+
+func RegisterAnalyticsServiceServer(s grpc.ServiceRegistrar, srv AnalyticsServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAnalyticsServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AnalyticsService_ServiceDesc, srv)
+}
+
+func _AnalyticsService_GetUserAnalytics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserAnalyticsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalyticsServiceServer).GetUserAnalytics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnalyticsService_GetUserAnalytics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalyticsServiceServer).GetUserAnalytics(ctx, req.(*GetUserAnalyticsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnalyticsService_ListTopUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTopUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalyticsServiceServer).ListTopUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnalyticsService_ListTopUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalyticsServiceServer).ListTopUsers(ctx, req.(*ListTopUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnalyticsService_ListAnomalies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAnomaliesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalyticsServiceServer).ListAnomalies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnalyticsService_ListAnomalies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalyticsServiceServer).ListAnomalies(ctx, req.(*ListAnomaliesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnalyticsService_ListVelocityAnomalies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVelocityAnomaliesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalyticsServiceServer).ListVelocityAnomalies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnalyticsService_ListVelocityAnomalies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalyticsServiceServer).ListVelocityAnomalies(ctx, req.(*ListVelocityAnomaliesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnalyticsService_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AnalyticsServiceServer).WatchEvents(m, &grpc.GenericServerStream[WatchEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AnalyticsService_WatchEventsServer = grpc.ServerStreamingServer[Event]
+
+func _AnalyticsService_PushTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AnalyticsServiceServer).PushTransactions(&grpc.GenericServerStream[Transaction, PushTransactionsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AnalyticsService_PushTransactionsServer = grpc.ClientStreamingServer[Transaction, PushTransactionsResponse]
+
+// AnalyticsService_ServiceDesc is the grpc.ServiceDesc for AnalyticsService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AnalyticsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "txprocessor.analytics.v1.AnalyticsService",
+	HandlerType: (*AnalyticsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetUserAnalytics",
+			Handler:    _AnalyticsService_GetUserAnalytics_Handler,
+		},
+		{
+			MethodName: "ListTopUsers",
+			Handler:    _AnalyticsService_ListTopUsers_Handler,
+		},
+		{
+			MethodName: "ListAnomalies",
+			Handler:    _AnalyticsService_ListAnomalies_Handler,
+		},
+		{
+			MethodName: "ListVelocityAnomalies",
+			Handler:    _AnalyticsService_ListVelocityAnomalies_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _AnalyticsService_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PushTransactions",
+			Handler:       _AnalyticsService_PushTransactions_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "analytics/v1/analytics.proto",
+}
@@ -0,0 +1,5 @@
+// Package analyticsv1 holds the gRPC analytics API generated from
+// analytics.proto; run go generate after editing the .proto.
+package analyticsv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative analytics/v1/analytics.proto
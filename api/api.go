@@ -0,0 +1,10 @@
+// Package api holds the OpenAPI description of the HTTP API.
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI 3 document served at /openapi.json. Keep it in step
+// with handlers.RegisterRoutes and the client package.
+//
+//go:embed openapi.json
+var Spec []byte
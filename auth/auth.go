@@ -0,0 +1,186 @@
+// Package auth identifies API callers by hashed API key or JWT bearer token
+// and carries their identity and scopes through the request context.
+// Enforcing scopes is left to the routes, which know what they serve.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"tx-processor/config"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Scope grants access to a group of routes
+type Scope string
+
+const (
+	ScopeReadAnalytics     Scope = "read:analytics"
+	ScopeReadAnomalies     Scope = "read:anomalies"
+	ScopeWriteTransactions Scope = "write:transactions"
+	ScopeAdmin             Scope = "admin" // Implies every other scope
+)
+
+// Valid reports whether s is a known scope
+func (s Scope) Valid() bool {
+	switch s {
+	case ScopeReadAnalytics, ScopeReadAnomalies, ScopeWriteTransactions, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// Method records how a caller authenticated
+type Method string
+
+const (
+	MethodAPIKey Method = "api_key"
+	MethodJWT    Method = "jwt"
+)
+
+// Identity is an authenticated caller
+type Identity struct {
+	Subject string // API key ID or JWT subject
+	Method  Method
+	Scopes  []Scope
+}
+
+// Has reports whether the caller holds scope, directly or through admin
+func (id *Identity) Has(scope Scope) bool {
+	return slices.Contains(id.Scopes, scope) || slices.Contains(id.Scopes, ScopeAdmin)
+}
+
+var (
+	// ErrNoCredentials is returned when a request carries neither an API key nor a bearer token
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned for unknown keys and tokens that fail verification
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// KeyStore resolves API keys to the identities they were issued to
+type KeyStore interface {
+	// LookupKey returns the identity for a key hash, or nil if no live key has it
+	LookupKey(ctx context.Context, hash string) (*Identity, error)
+}
+
+type Authenticator struct {
+	keys []KeyStore
+	jwt  *JWTVerifier
+}
+
+// Option configures the credentials an Authenticator accepts
+type Option func(*Authenticator)
+
+// WithKeyStore accepts API keys found in store; stores are tried in the order given
+func WithKeyStore(store KeyStore) Option {
+	return func(a *Authenticator) {
+		a.keys = append(a.keys, store)
+	}
+}
+
+// WithJWT accepts bearer tokens that verifier accepts
+func WithJWT(verifier *JWTVerifier) Option {
+	return func(a *Authenticator) {
+		a.jwt = verifier
+	}
+}
+
+// NewAuthenticator returns an authenticator for the given credential sources.
+// At least one is required.
+func NewAuthenticator(opts ...Option) (*Authenticator, error) {
+	a := &Authenticator{}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if len(a.keys) == 0 && a.jwt == nil {
+		return nil, fmt.Errorf("auth: no API key store or JWKS configured")
+	}
+	return a, nil
+}
+
+// Authenticate identifies the caller from an Authorization header value
+// ("Bearer <jwt>") or an API key. The API key wins when both are given.
+func (a *Authenticator) Authenticate(ctx context.Context, authorization, apiKey string) (*Identity, error) {
+	if apiKey != "" {
+		return a.authenticateKey(ctx, apiKey)
+	}
+	if authorization == "" {
+		return nil, ErrNoCredentials
+	}
+
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, fmt.Errorf("%w: authorization must be a bearer token", ErrInvalidCredentials)
+	}
+	if a.jwt == nil {
+		return nil, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidCredentials)
+	}
+	return a.jwt.Verify(token)
+}
+
+func (a *Authenticator) authenticateKey(ctx context.Context, key string) (*Identity, error) {
+	hash := HashKey(key)
+	for _, store := range a.keys {
+		id, err := store.LookupKey(ctx, hash)
+		if err != nil {
+			return nil, fmt.Errorf("look up API key: %w", err)
+		}
+		if id != nil {
+			return id, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+}
+
+type identityKey struct{}
+type failureKey struct{}
+
+// WithIdentity returns ctx carrying the caller's identity
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the caller's identity, or nil for an anonymous request
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// WithFailure returns ctx recording why the request's credentials were
+// rejected, so routes that require a caller can say why there isn't one
+func WithFailure(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, failureKey{}, err)
+}
+
+// FailureFromContext returns the error recorded by WithFailure, if any
+func FailureFromContext(ctx context.Context) error {
+	err, _ := ctx.Value(failureKey{}).(error)
+	return err
+}
+
+// Setup builds an authenticator from the configured key file, api_keys table
+// and JWKS file
+func Setup(cfg *config.AuthConfig, db *sqlx.DB) (*Authenticator, error) {
+	var opts []Option
+	if cfg.KeysFile != "" {
+		store, err := LoadKeyFile(cfg.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithKeyStore(store))
+	}
+	if cfg.KeysPostgres {
+		opts = append(opts, WithKeyStore(NewPostgresKeyStore(db, cfg.KeyCacheTTL)))
+	}
+	if cfg.JWKSFile != "" {
+		verifier, err := LoadJWKS(cfg.JWKSFile, cfg.JWTIssuer, cfg.JWTAudience, cfg.JWTLeeway)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithJWT(verifier))
+	}
+	return NewAuthenticator(opts...)
+}
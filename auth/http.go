@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"tx-processor/logger"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware identifies callers from the X-API-Key or Authorization header.
+// Requests without credentials, or whose credentials are rejected, continue
+// anonymously with the failure recorded; routes that require a caller reject
+// them, and public routes such as health checks still answer.
+func Middleware(a *Authenticator, log logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			id, err := a.Authenticate(ctx, r.Header.Get("Authorization"), r.Header.Get("X-API-Key"))
+			switch {
+			case err == nil:
+				ctx = WithIdentity(ctx, id)
+				ctx = logger.ContextWith(ctx, "caller", id.Subject, "auth_method", id.Method)
+				trace.SpanFromContext(ctx).SetAttributes(attribute.String("enduser.id", id.Subject))
+			case errors.Is(err, ErrNoCredentials):
+			default:
+				if errors.Is(err, ErrInvalidCredentials) {
+					logger.WithTrace(ctx, log).Info("rejected credentials", "path", r.URL.Path, "error", err)
+				} else {
+					logger.WithTrace(ctx, log).Error("failed to authenticate request", "error", err)
+				}
+				ctx = WithFailure(ctx, err)
+			}
+
+			// The matched route reaches middleware further out through the
+			// slot middleware.WithRoute added to ctx
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
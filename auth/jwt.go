@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwk is the subset of RFC 7517 needed for signature verification keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// JWTVerifier checks bearer tokens against keys from a local JWKS file
+type JWTVerifier struct {
+	keys     map[string]crypto.PublicKey // By kid; "" holds the only key of a single-key set
+	methods  []string
+	parser   *jwt.Parser
+	issuer   string
+	audience string
+}
+
+// LoadJWKS reads RSA, EC and Ed25519 verification keys from a JWKS file and
+// returns a verifier for tokens signed with them. issuer and audience are
+// checked when set.
+func LoadJWKS(path, issuer, audience string, leeway time.Duration) (*JWTVerifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWKS: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS %s: %w", path, err)
+	}
+
+	v := &JWTVerifier{keys: make(map[string]crypto.PublicKey), issuer: issuer, audience: audience}
+	methods := make(map[string]bool)
+	for i, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		pub, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS %s: key %d: %w", path, i, err)
+		}
+		if _, ok := v.keys[key.Kid]; ok {
+			return nil, fmt.Errorf("JWKS %s: duplicate kid %q", path, key.Kid)
+		}
+		v.keys[key.Kid] = pub
+		for _, method := range signingMethods(pub) {
+			methods[method] = true
+		}
+	}
+	if len(v.keys) == 0 {
+		return nil, fmt.Errorf("JWKS %s has no signature keys", path)
+	}
+	if len(v.keys) == 1 {
+		for _, pub := range v.keys {
+			v.keys[""] = pub
+		}
+	}
+	for method := range methods {
+		v.methods = append(v.methods, method)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(v.methods),
+		jwt.WithLeeway(leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+// Verify checks a token's signature and claims and returns the caller it names.
+// Scopes come from the space-separated scope claim or the scp claim; scopes
+// this API doesn't define are ignored.
+func (v *JWTVerifier) Verify(token string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	id := &Identity{Subject: subject, Method: MethodJWT}
+	for _, name := range tokenScopes(claims) {
+		if scope := Scope(name); scope.Valid() {
+			id.Scopes = append(id.Scopes, scope)
+		}
+	}
+	return id, nil
+}
+
+func (v *JWTVerifier) key(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	pub, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	// The parser only checks the algorithm against every key's methods
+	if alg := token.Method.Alg(); !slices.Contains(signingMethods(pub), alg) {
+		return nil, fmt.Errorf("key %q does not verify %s", kid, alg)
+	}
+	return pub, nil
+}
+
+func tokenScopes(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	switch scp := claims["scp"].(type) {
+	case string:
+		return strings.Fields(scp)
+	case []any:
+		names := make([]string, 0, len(scp))
+		for _, s := range scp {
+			if name, ok := s.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// signingMethods lists the algorithms a key may verify, so a token can't
+// pick one its key wasn't meant for
+func signingMethods(pub crypto.PublicKey) []string {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return []string{"ES256"}
+		case elliptic.P384():
+			return []string{"ES384"}
+		case elliptic.P521():
+			return []string{"ES512"}
+		}
+	case ed25519.PublicKey:
+		return []string{"EdDSA"}
+	}
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("RSA modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA exponent is invalid")
+		}
+		if n.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key must be at least 2048 bits")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("EC x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("EC y: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if _, err := pub.ECDH(); err != nil {
+			return nil, fmt.Errorf("EC point is not on curve %s", k.Crv)
+		}
+		return pub, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 key is invalid")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("not base64url")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
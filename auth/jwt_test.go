@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testKeys are generated once, as RSA keys are slow to make
+var testKeys = newTestKeys()
+
+type signingKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newTestKeys() signingKeys {
+	var keys signingKeys
+	var err error
+	if keys.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if keys.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+	if _, keys.ed25519, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		panic(err)
+	}
+	return keys
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// toJWK describes pub as a JWKS entry
+func toJWK(kid string, pub crypto.PublicKey) jwk {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return jwk{Kty: "RSA", Kid: kid, N: encodeInt(pub.N), E: encodeInt(big.NewInt(int64(pub.E)))}
+	case *ecdsa.PublicKey:
+		return jwk{Kty: "EC", Kid: kid, Crv: pub.Curve.Params().Name, X: encodeInt(pub.X), Y: encodeInt(pub.Y)}
+	case ed25519.PublicKey:
+		return jwk{Kty: "OKP", Kid: kid, Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub)}
+	}
+	panic("unsupported key")
+}
+
+// writeJWKS writes keys as a JWKS file and returns its path
+func writeJWKS(t *testing.T, keys ...jwk) string {
+	t.Helper()
+	data, err := json.Marshal(map[string][]jwk{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// sign returns a token with claims signed by key, naming kid when it's set
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// claims returns claims for a token valid for another hour, with extra set
+func claims(extra jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"sub": "svc-billing",
+		"iss": "https://issuer.example",
+		"aud": "tx-processor",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+func newVerifier(t *testing.T) *JWTVerifier {
+	t.Helper()
+	path := writeJWKS(t,
+		toJWK("rsa", testKeys.rsa.Public()),
+		toJWK("ec", testKeys.ec.Public()),
+		toJWK("ed", testKeys.ed25519.Public()),
+	)
+	v, err := LoadJWKS(path, "https://issuer.example", "tx-processor", 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// noneToken returns an unsigned token naming the alg none
+func noneToken(t *testing.T, kid string) string {
+	t.Helper()
+	return sign(t, jwt.SigningMethodNone, kid, jwt.UnsafeAllowNoneSignatureType, claims(nil))
+}
+
+// hmacWithRSAKey signs a token with HS256 keyed by the RSA public key, the
+// classic algorithm confusion attack on verifiers that trust the header
+func hmacWithRSAKey(t *testing.T, kid string) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(testKeys.rsa.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sign(t, jwt.SigningMethodHS256, kid, der, claims(nil))
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	v := newVerifier(t)
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"RS256 with RSA key", sign(t, jwt.SigningMethodRS256, "rsa", testKeys.rsa, claims(nil)), true},
+		{"PS384 with RSA key", sign(t, jwt.SigningMethodPS384, "rsa", testKeys.rsa, claims(nil)), true},
+		{"ES256 with P-256 key", sign(t, jwt.SigningMethodES256, "ec", testKeys.ec, claims(nil)), true},
+		{"EdDSA with Ed25519 key", sign(t, jwt.SigningMethodEdDSA, "ed", testKeys.ed25519, claims(nil)), true},
+		{"ES256 naming the RSA key", sign(t, jwt.SigningMethodES256, "rsa", testKeys.ec, claims(nil)), false},
+		{"RS256 naming the EC key", sign(t, jwt.SigningMethodRS256, "ec", testKeys.rsa, claims(nil)), false},
+		{"EdDSA naming the EC key", sign(t, jwt.SigningMethodEdDSA, "ec", testKeys.ed25519, claims(nil)), false},
+		{"alg none", noneToken(t, "rsa"), false},
+		{"HS256 keyed by the RSA public key", hmacWithRSAKey(t, "rsa"), false},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "other", testKeys.rsa, claims(nil)), false},
+		{"no kid with several keys", sign(t, jwt.SigningMethodRS256, "", testKeys.rsa, claims(nil)), false},
+		{"malformed", "not.a.token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := v.Verify(tt.token)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if id.Subject != "svc-billing" || id.Method != MethodJWT {
+					t.Errorf("identity = %+v, want svc-billing by jwt", id)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Verify = %+v, %v; want ErrInvalidCredentials", id, err)
+			}
+		})
+	}
+}
+
+func TestVerifySingleKeyNeedsNoKid(t *testing.T) {
+	v, err := LoadJWKS(writeJWKS(t, toJWK("only", testKeys.rsa.Public())), "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(sign(t, jwt.SigningMethodRS256, "", testKeys.rsa, claims(nil))); err != nil {
+		t.Errorf("Verify without kid: %v", err)
+	}
+	for name, token := range map[string]string{
+		"alg none": noneToken(t, ""),
+		"HS256":    hmacWithRSAKey(t, ""),
+		"ES256":    sign(t, jwt.SigningMethodES256, "", testKeys.ec, claims(nil)),
+	} {
+		if _, err := v.Verify(token); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Verify %s = %v, want ErrInvalidCredentials", name, err)
+		}
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	v := newVerifier(t)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		ok     bool
+	}{
+		{"valid", nil, true},
+		{"expired", jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}, false},
+		{"expired within leeway", jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()}, true},
+		{"no expiry", jwt.MapClaims{"exp": nil}, false},
+		{"not yet valid", jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()}, false},
+		{"not yet valid within leeway", jwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix()}, true},
+		{"wrong issuer", jwt.MapClaims{"iss": "https://other.example"}, false},
+		{"wrong audience", jwt.MapClaims{"aud": "other"}, false},
+		{"audience among several", jwt.MapClaims{"aud": []string{"other", "tx-processor"}}, true},
+		{"no subject", jwt.MapClaims{"sub": nil}, false},
+		{"empty subject", jwt.MapClaims{"sub": ""}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(sign(t, jwt.SigningMethodRS256, "rsa", testKeys.rsa, claims(tt.claims)))
+			if tt.ok && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Verify = %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+}
+
+func TestVerifyScopes(t *testing.T) {
+	v := newVerifier(t)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   []Scope
+	}{
+		{"none", nil, nil},
+		{"scope", jwt.MapClaims{"scope": "read:analytics  read:anomalies"}, []Scope{ScopeReadAnalytics, ScopeReadAnomalies}},
+		{"scp string", jwt.MapClaims{"scp": "write:transactions"}, []Scope{ScopeWriteTransactions}},
+		{"scp array", jwt.MapClaims{"scp": []any{"admin", 7, "read:analytics"}}, []Scope{ScopeAdmin, ScopeReadAnalytics}},
+		{"scope wins over scp", jwt.MapClaims{"scope": "read:anomalies", "scp": "admin"}, []Scope{ScopeReadAnomalies}},
+		{"unknown scopes dropped", jwt.MapClaims{"scope": "openid read:analytics delete:everything"}, []Scope{ScopeReadAnalytics}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := v.Verify(sign(t, jwt.SigningMethodRS256, "rsa", testKeys.rsa, claims(tt.claims)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(id.Scopes, tt.want) {
+				t.Errorf("scopes = %v, want %v", id.Scopes, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentityHas(t *testing.T) {
+	reader := &Identity{Scopes: []Scope{ScopeReadAnalytics}}
+	if !reader.Has(ScopeReadAnalytics) || reader.Has(ScopeReadAnomalies) {
+		t.Errorf("reader scopes = %v", reader.Scopes)
+	}
+	admin := &Identity{Scopes: []Scope{ScopeAdmin}}
+	if !admin.Has(ScopeWriteTransactions) {
+		t.Error("admin should hold every scope")
+	}
+}
+
+func TestLoadJWKS(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := toJWK("small", weak.Public())
+	offCurve := toJWK("off", testKeys.ec.Public())
+	offCurve.Y = encodeInt(big.NewInt(1))
+	encryption := toJWK("enc", testKeys.ec.Public())
+	encryption.Use = "enc"
+
+	tests := []struct {
+		name    string
+		keys    []jwk
+		wantErr string
+	}{
+		{"RSA under 2048 bits", []jwk{small}, "at least 2048 bits"},
+		{"point off the curve", []jwk{offCurve}, "not on curve"},
+		{"unknown curve", []jwk{{Kty: "EC", Crv: "P-192", X: "AQ", Y: "AQ"}}, `unsupported curve "P-192"`},
+		{"unknown key type", []jwk{{Kty: "oct"}}, `unsupported key type "oct"`},
+		{"duplicate kid", []jwk{toJWK("a", testKeys.ec.Public()), toJWK("a", testKeys.rsa.Public())}, `duplicate kid "a"`},
+		{"only encryption keys", []jwk{encryption}, "no signature keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadJWKS(writeJWKS(t, tt.keys...), "", "", 0)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadJWKS = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("encryption keys skipped", func(t *testing.T) {
+		v, err := LoadJWKS(writeJWKS(t, encryption, toJWK("sig", testKeys.rsa.Public())), "", "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := v.keys["enc"]; ok {
+			t.Error("encryption key loaded for verification")
+		}
+		if slices.Contains(v.methods, "ES256") {
+			t.Errorf("methods = %v, want only RSA methods", v.methods)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// keyPrefix marks generated keys, so leaked ones are easy to spot in scans
+const keyPrefix = "txp_"
+
+// GenerateKey returns a new random API key. Only its hash should be stored.
+func GenerateKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generate API key: %w", err)
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashKey returns the stored form of an API key. Keys are random and long, so
+// a fast unsalted hash is enough and lets keys be looked up by hash.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// keyFile is the JSON layout read by LoadKeyFile
+type keyFile struct {
+	Keys []struct {
+		ID       string  `json:"id"`
+		Hash     string  `json:"hash"`
+		Scopes   []Scope `json:"scopes"`
+		Disabled bool    `json:"disabled"`
+	} `json:"keys"`
+}
+
+// FileKeyStore serves API keys listed in a JSON file
+type FileKeyStore struct {
+	keys map[string]*Identity
+}
+
+// LoadKeyFile reads hashed API keys from a JSON file of the form
+// {"keys": [{"id": "billing", "hash": "sha256:...", "scopes": ["read:analytics"]}]}
+func LoadKeyFile(path string) (*FileKeyStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read API key file: %w", err)
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse API key file %s: %w", path, err)
+	}
+
+	store := &FileKeyStore{keys: make(map[string]*Identity)}
+	for i, key := range file.Keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("API key file %s: key %d has no id", path, i)
+		}
+		if !strings.HasPrefix(key.Hash, "sha256:") || len(key.Hash) != len("sha256:")+sha256.Size*2 {
+			return nil, fmt.Errorf("API key file %s: key %s hash must be sha256:<64 hex digits>", path, key.ID)
+		}
+		if err := validScopes(key.Scopes); err != nil {
+			return nil, fmt.Errorf("API key file %s: key %s: %w", path, key.ID, err)
+		}
+		if _, ok := store.keys[key.Hash]; ok {
+			return nil, fmt.Errorf("API key file %s: key %s duplicates another key's hash", path, key.ID)
+		}
+		if key.Disabled {
+			continue
+		}
+		store.keys[key.Hash] = &Identity{Subject: key.ID, Method: MethodAPIKey, Scopes: key.Scopes}
+	}
+	return store, nil
+}
+
+func (s *FileKeyStore) LookupKey(ctx context.Context, hash string) (*Identity, error) {
+	return s.keys[hash], nil
+}
+
+func validScopes(scopes []Scope) error {
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// PostgresKeyStore serves API keys from the api_keys table. Found keys are
+// cached for ttl, so a revocation takes up to ttl to apply.
+type PostgresKeyStore struct {
+	db  *sqlx.DB
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex
+	cached map[string]cachedIdentity
+}
+
+type cachedIdentity struct {
+	identity *Identity
+	expires  time.Time
+}
+
+func NewPostgresKeyStore(db *sqlx.DB, ttl time.Duration) *PostgresKeyStore {
+	return &PostgresKeyStore{db: db, ttl: ttl, now: time.Now, cached: make(map[string]cachedIdentity)}
+}
+
+func (s *PostgresKeyStore) LookupKey(ctx context.Context, hash string) (*Identity, error) {
+	now := s.now()
+	s.mu.Lock()
+	entry, ok := s.cached[hash]
+	s.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.identity, nil
+	}
+
+	var (
+		keyID  string
+		scopes pq.StringArray
+	)
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, scopes FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL", hash,
+	).Scan(&keyID, &scopes)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.mu.Lock()
+		delete(s.cached, hash)
+		s.mu.Unlock()
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select api key: %w", err)
+	}
+
+	id := &Identity{Subject: keyID, Method: MethodAPIKey}
+	for _, scope := range scopes {
+		id.Scopes = append(id.Scopes, Scope(scope))
+	}
+
+	s.mu.Lock()
+	// Drop expired entries as we go so the cache stays bounded by live keys
+	for key, entry := range s.cached {
+		if now.After(entry.expires) {
+			delete(s.cached, key)
+		}
+	}
+	s.cached[hash] = cachedIdentity{identity: id, expires: now.Add(s.ttl)}
+	s.mu.Unlock()
+	return id, nil
+}
+
+// CreateKey stores the hash of a new key issued to id
+func (s *PostgresKeyStore) CreateKey(ctx context.Context, id, hash string, scopes []Scope) error {
+	if err := validScopes(scopes); err != nil {
+		return err
+	}
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	if _, err := s.db.ExecContext(ctx,
+		"INSERT INTO api_keys (id, key_hash, scopes) VALUES ($1, $2, $3)", id, hash, pq.Array(names),
+	); err != nil {
+		return fmt.Errorf("insert api key: %w", err)
+	}
+	return nil
+}
+
+// RevokeKey disables the key issued to id. Replicas stop accepting it once their cached copy expires.
+func (s *PostgresKeyStore) RevokeKey(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no live API key with id %s", id)
+	}
+	return nil
+}
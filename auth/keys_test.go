@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+// writeKeyFile writes an API key file and returns its path
+func writeKeyFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	billing, ops := HashKey("txp_billing"), HashKey("txp_ops")
+	path := writeKeyFile(t, `{"keys": [
+		{"id": "billing", "hash": "`+billing+`", "scopes": ["read:analytics", "read:anomalies"]},
+		{"id": "ops", "hash": "`+ops+`", "scopes": ["admin"], "disabled": true}
+	]}`)
+	store, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := store.LookupKey(context.Background(), billing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == nil || id.Subject != "billing" || id.Method != MethodAPIKey ||
+		!slices.Equal(id.Scopes, []Scope{ScopeReadAnalytics, ScopeReadAnomalies}) {
+		t.Errorf("billing key = %+v", id)
+	}
+	if id, _ := store.LookupKey(context.Background(), ops); id != nil {
+		t.Errorf("disabled key = %+v, want nil", id)
+	}
+	if id, _ := store.LookupKey(context.Background(), HashKey("txp_unknown")); id != nil {
+		t.Errorf("unknown key = %+v, want nil", id)
+	}
+}
+
+func TestLoadKeyFileErrors(t *testing.T) {
+	hash := HashKey("txp_billing")
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{"malformed JSON", `{"keys": [`, "parse API key file"},
+		{"missing id", `{"keys": [{"hash": "` + hash + `"}]}`, "key 0 has no id"},
+		{"unprefixed hash", `{"keys": [{"id": "a", "hash": "` + strings.TrimPrefix(hash, "sha256:") + `"}]}`, "key a hash must be sha256:"},
+		{"short hash", `{"keys": [{"id": "a", "hash": "sha256:abc"}]}`, "key a hash must be sha256:"},
+		{"unknown scope", `{"keys": [{"id": "a", "hash": "` + hash + `", "scopes": ["write:everything"]}]}`, `key a: unknown scope "write:everything"`},
+		{"duplicate hash", `{"keys": [{"id": "a", "hash": "` + hash + `"}, {"id": "b", "hash": "` + hash + `", "disabled": true}]}`, "key b duplicates another key's hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadKeyFile(writeKeyFile(t, tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadKeyFile = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadKeyFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadKeyFile of a missing file succeeded")
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, keyPrefix) {
+		t.Errorf("key %q lacks the %s prefix", key, keyPrefix)
+	}
+	if other, _ := GenerateKey(); other == key {
+		t.Error("GenerateKey repeated a key")
+	}
+	if hash := HashKey(key); len(hash) != len("sha256:")+64 || HashKey(key) != hash {
+		t.Errorf("HashKey = %q, want a stable sha256 digest", hash)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	store, err := LoadKeyFile(writeKeyFile(t, `{"keys": [{"id": "billing", "hash": "`+HashKey("txp_billing")+`"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthenticator(WithKeyStore(store), WithJWT(newVerifier(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, jwt.SigningMethodRS256, "rsa", testKeys.rsa, claims(nil))
+
+	tests := []struct {
+		name          string
+		authorization string
+		apiKey        string
+		subject       string
+		wantErr       error
+	}{
+		{"API key", "", "txp_billing", "billing", nil},
+		{"bearer token", "Bearer " + token, "", "svc-billing", nil},
+		{"lowercase scheme", "bearer " + token, "", "svc-billing", nil},
+		{"API key wins", "Bearer " + token, "txp_billing", "billing", nil},
+		{"unknown API key", "Bearer " + token, "txp_other", "", ErrInvalidCredentials},
+		{"basic auth", "Basic dXNlcjpwYXNz", "", "", ErrInvalidCredentials},
+		{"empty bearer", "Bearer ", "", "", ErrInvalidCredentials},
+		{"nothing", "", "", "", ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := a.Authenticate(context.Background(), tt.authorization, tt.apiKey)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Authenticate = %+v, %v; want %v", id, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id.Subject != tt.subject {
+				t.Errorf("subject = %q, want %q", id.Subject, tt.subject)
+			}
+		})
+	}
+
+	keysOnly, err := NewAuthenticator(WithKeyStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keysOnly.Authenticate(context.Background(), "Bearer "+token, ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("bearer token without a JWKS = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := NewAuthenticator(); err == nil {
+		t.Error("NewAuthenticator with no credential sources succeeded")
+	}
+}
+
+func TestPostgresKeyStoreCache(t *testing.T) {
+	hash := HashKey("txp_billing")
+	db := &fakeKeyDB{keys: map[string]string{hash: "{read:analytics}"}}
+	store := NewPostgresKeyStore(sqlx.NewDb(sql.OpenDB(db), "postgres"), time.Minute)
+	now := time.Unix(1_700_000_000, 0)
+	store.now = func() time.Time { return now }
+
+	lookup := func(hash string) *Identity {
+		t.Helper()
+		id, err := store.LookupKey(context.Background(), hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	id := lookup(hash)
+	if id == nil || id.Subject != "billing" || !slices.Equal(id.Scopes, []Scope{ScopeReadAnalytics}) {
+		t.Fatalf("LookupKey = %+v", id)
+	}
+	if lookup(hash) != id || db.queryCount() != 1 {
+		t.Errorf("second lookup queried again: %d queries", db.queryCount())
+	}
+
+	// A revocation isn't seen until the cached copy expires
+	db.revoke(hash)
+	now = now.Add(59 * time.Second)
+	if lookup(hash) == nil {
+		t.Error("cached key dropped before its TTL")
+	}
+	now = now.Add(2 * time.Second)
+	if id := lookup(hash); id != nil {
+		t.Errorf("revoked key = %+v after its TTL, want nil", id)
+	}
+	if db.queryCount() != 2 {
+		t.Errorf("queries = %d, want 2", db.queryCount())
+	}
+
+	// Misses aren't cached, so a new key works at once
+	unknown := HashKey("txp_new")
+	lookup(unknown)
+	db.add(unknown, "{}")
+	if id := lookup(unknown); id == nil || id.Subject != "billing" {
+		t.Errorf("new key = %+v, want found", id)
+	}
+	if len(store.cached) != 1 {
+		t.Errorf("cache holds %d entries, want only the live key", len(store.cached))
+	}
+}
+
+func TestPostgresKeyStoreError(t *testing.T) {
+	db := &fakeKeyDB{err: errors.New("connection refused")}
+	store := NewPostgresKeyStore(sqlx.NewDb(sql.OpenDB(db), "postgres"), time.Minute)
+	if _, err := store.LookupKey(context.Background(), HashKey("txp_billing")); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("LookupKey = %v, want the query error", err)
+	}
+}
+
+// fakeKeyDB is a database/sql driver answering the key lookup from a map of
+// hash to scopes array literal. Every key it holds is issued to billing.
+type fakeKeyDB struct {
+	mu      sync.Mutex
+	keys    map[string]string
+	queries int
+	err     error
+}
+
+func (db *fakeKeyDB) revoke(hash string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	delete(db.keys, hash)
+}
+
+func (db *fakeKeyDB) add(hash, scopes string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.keys[hash] = scopes
+}
+
+func (db *fakeKeyDB) queryCount() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.queries
+}
+
+func (db *fakeKeyDB) Connect(context.Context) (driver.Conn, error) { return fakeKeyConn{db}, nil }
+func (db *fakeKeyDB) Driver() driver.Driver                        { return nil }
+
+type fakeKeyConn struct{ db *fakeKeyDB }
+
+func (c fakeKeyConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeKeyConn: prepared statements unsupported")
+}
+func (c fakeKeyConn) Close() error { return nil }
+func (c fakeKeyConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fakeKeyConn: transactions unsupported")
+}
+
+func (c fakeKeyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.queries++
+	if c.db.err != nil {
+		return nil, c.db.err
+	}
+	rows := &fakeKeyRows{}
+	if scopes, ok := c.db.keys[args[0].Value.(string)]; ok {
+		rows.values = [][]driver.Value{{"billing", []byte(scopes)}}
+	}
+	return rows, nil
+}
+
+type fakeKeyRows struct{ values [][]driver.Value }
+
+func (r *fakeKeyRows) Columns() []string { return []string{"id", "scopes"} }
+func (r *fakeKeyRows) Close() error      { return nil }
+
+func (r *fakeKeyRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
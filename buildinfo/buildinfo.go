@@ -0,0 +1,36 @@
+// Package buildinfo identifies the running binary from what the Go toolchain
+// stamps into it, plus a release version set at link time:
+//
+//	go build -ldflags "-X tx-processor/buildinfo.Version=v1.2.3"
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"tx-processor/models"
+)
+
+// Version is the release version, "dev" unless set by the linker
+var Version = "dev"
+
+// Get returns the running binary's version, VCS revision and toolchain.
+// Revision and commit time are empty for binaries built outside a checkout,
+// such as with go run.
+func Get() models.BuildInfo {
+	info := models.BuildInfo{Version: Version, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.CommitTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
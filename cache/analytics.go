@@ -2,11 +2,31 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"tx-processor/models"
 )
 
+// ErrCacheMiss is returned by Get when the user isn't cached
+var ErrCacheMiss = errors.New("cache miss")
+
+// ErrPublish is wrapped by deletes from a shared cache that took effect but
+// could not be announced. Other processes keep their local copies of the
+// users until those expire.
+var ErrPublish = errors.New("invalidation not published")
+
 type AnalyticsCache interface {
 	Get(ctx context.Context, userID string) (*models.UserAnalytics, error)
 	Set(ctx context.Context, analytics models.UserAnalytics) error
 	Delete(ctx context.Context, userID string) error
+	DeleteMany(ctx context.Context, userIDs []string) error
+	// DeletePrefix removes users whose IDs start with prefix, every user for
+	// an empty prefix, and returns how many entries it removed
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// Invalidation names users one process removed from a shared cache, so others
+// can drop their local copies
+type Invalidation struct {
+	UserIDs []string `json:"user_ids,omitempty"`
+	Prefix  *string  `json:"prefix,omitempty"` // Set for every user whose ID starts with it; empty for every user
 }
@@ -0,0 +1,87 @@
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"time"
+	"tx-processor/cache"
+	"tx-processor/models"
+)
+
+// InstrumentedAnalyticsCache records hits, misses, errors and latency for the
+// cache it wraps without changing its behaviour.
+type InstrumentedAnalyticsCache struct {
+	inner cache.AnalyticsCache
+	stats *Stats
+}
+
+func NewInstrumentedAnalyticsCache(inner cache.AnalyticsCache, stats *Stats) *InstrumentedAnalyticsCache {
+	return &InstrumentedAnalyticsCache{
+		inner: inner,
+		stats: stats,
+	}
+}
+
+func (c *InstrumentedAnalyticsCache) Get(ctx context.Context, userID string) (*models.UserAnalytics, error) {
+	start := time.Now()
+	analytics, err := c.inner.Get(ctx, userID)
+	c.stats.latency[opGet].Observe(time.Since(start).Seconds())
+
+	switch {
+	case err == nil:
+		c.stats.hits.Inc()
+	case errors.Is(err, cache.ErrCacheMiss):
+		c.stats.misses.Inc()
+	default:
+		c.stats.errors[opGet].Inc()
+	}
+	return analytics, err
+}
+
+func (c *InstrumentedAnalyticsCache) Set(ctx context.Context, analytics models.UserAnalytics) error {
+	start := time.Now()
+	err := c.inner.Set(ctx, analytics)
+	c.stats.latency[opSet].Observe(time.Since(start).Seconds())
+
+	c.stats.writes[opSet].Inc()
+	if err != nil {
+		c.stats.errors[opSet].Inc()
+	}
+	return err
+}
+
+func (c *InstrumentedAnalyticsCache) Delete(ctx context.Context, userID string) error {
+	start := time.Now()
+	err := c.inner.Delete(ctx, userID)
+	c.stats.latency[opDelete].Observe(time.Since(start).Seconds())
+
+	c.stats.writes[opDelete].Inc()
+	if err != nil {
+		c.stats.errors[opDelete].Inc()
+	}
+	return err
+}
+
+func (c *InstrumentedAnalyticsCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	start := time.Now()
+	deleted, err := c.inner.DeletePrefix(ctx, prefix)
+	c.stats.latency[opDelete].Observe(time.Since(start).Seconds())
+
+	c.stats.writes[opDelete].Add(float64(deleted))
+	if err != nil {
+		c.stats.errors[opDelete].Inc()
+	}
+	return deleted, err
+}
+
+func (c *InstrumentedAnalyticsCache) DeleteMany(ctx context.Context, userIDs []string) error {
+	start := time.Now()
+	err := c.inner.DeleteMany(ctx, userIDs)
+	c.stats.latency[opDelete].Observe(time.Since(start).Seconds())
+
+	c.stats.writes[opDelete].Add(float64(len(userIDs)))
+	if err != nil {
+		c.stats.errors[opDelete].Inc()
+	}
+	return err
+}
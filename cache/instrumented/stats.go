@@ -0,0 +1,140 @@
+package instrumented
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type op string
+
+const (
+	opGet    op = "get"
+	opSet    op = "set"
+	opDelete op = "delete"
+)
+
+var ops = []op{opGet, opSet, opDelete}
+
+// latencyBuckets are upper bounds in seconds, spanning in-process lookups to slow Redis calls
+var latencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// Metrics records outcomes for every cache tier, labelled by tier
+type Metrics struct {
+	hits    *prometheus.CounterVec
+	misses  *prometheus.CounterVec
+	writes  *prometheus.CounterVec
+	errors  *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+}
+
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "analytics_cache_hits_total",
+			Help: "Cache lookups that found the user.",
+		}, []string{"tier"}),
+		misses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "analytics_cache_misses_total",
+			Help: "Cache lookups that did not find the user.",
+		}, []string{"tier"}),
+		writes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "analytics_cache_writes_total",
+			Help: "Users set in or deleted from the cache, by operation.",
+		}, []string{"tier", "op"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "analytics_cache_errors_total",
+			Help: "Cache operations that failed, by operation.",
+		}, []string{"tier", "op"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "analytics_cache_operation_duration_seconds",
+			Help:    "Cache operation latency, by operation.",
+			Buckets: latencyBuckets,
+		}, []string{"tier", "op"}),
+	}
+	reg.MustRegister(m.hits, m.misses, m.writes, m.errors, m.latency)
+	return m
+}
+
+// Tier returns the stats for one cache tier
+func (m *Metrics) Tier(tier string) *Stats {
+	s := &Stats{
+		tier:    tier,
+		hits:    m.hits.WithLabelValues(tier),
+		misses:  m.misses.WithLabelValues(tier),
+		writes:  make(map[op]prometheus.Counter, len(ops)),
+		errors:  make(map[op]prometheus.Counter, len(ops)),
+		latency: make(map[op]prometheus.Histogram, len(ops)),
+	}
+	// Every series exists from the start, so rates work before the first failure
+	for _, o := range ops {
+		s.writes[o] = m.writes.WithLabelValues(tier, string(o))
+		s.errors[o] = m.errors.WithLabelValues(tier, string(o))
+		s.latency[o] = m.latency.WithLabelValues(tier, string(o)).(prometheus.Histogram)
+	}
+	return s
+}
+
+// Stats records outcomes for one cache tier. It is safe for concurrent use.
+type Stats struct {
+	tier    string
+	hits    prometheus.Counter
+	misses  prometheus.Counter
+	writes  map[op]prometheus.Counter // Only set and delete are used
+	errors  map[op]prometheus.Counter
+	latency map[op]prometheus.Histogram
+}
+
+// LatencySnapshot summarises one operation's latency
+type LatencySnapshot struct {
+	Count   uint64  `json:"count"`
+	AvgMs   float64 `json:"avg_ms"`
+	SumSecs float64 `json:"sum_seconds"`
+}
+
+// Snapshot is a point-in-time copy of a tier's counters
+type Snapshot struct {
+	Tier         string                     `json:"tier"`
+	Hits         uint64                     `json:"hits"`
+	Misses       uint64                     `json:"misses"`
+	HitRatio     float64                    `json:"hit_ratio"`
+	GetErrors    uint64                     `json:"get_errors"`
+	Sets         uint64                     `json:"sets"`
+	SetErrors    uint64                     `json:"set_errors"` // Includes failed background fills
+	Deletes      uint64                     `json:"deletes"`
+	DeleteErrors uint64                     `json:"delete_errors"`
+	Latency      map[string]LatencySnapshot `json:"latency"`
+}
+
+// Snapshot reads the tier's series back for the admin stats endpoint
+func (s *Stats) Snapshot() Snapshot {
+	snap := Snapshot{
+		Tier:         s.tier,
+		Hits:         counterValue(s.hits),
+		Misses:       counterValue(s.misses),
+		GetErrors:    counterValue(s.errors[opGet]),
+		Sets:         counterValue(s.writes[opSet]),
+		SetErrors:    counterValue(s.errors[opSet]),
+		Deletes:      counterValue(s.writes[opDelete]),
+		DeleteErrors: counterValue(s.errors[opDelete]),
+		Latency:      make(map[string]LatencySnapshot, len(ops)),
+	}
+	if lookups := snap.Hits + snap.Misses; lookups > 0 {
+		snap.HitRatio = float64(snap.Hits) / float64(lookups)
+	}
+	for _, o := range ops {
+		var m dto.Metric
+		s.latency[o].Write(&m)
+		latency := LatencySnapshot{Count: m.GetHistogram().GetSampleCount(), SumSecs: m.GetHistogram().GetSampleSum()}
+		if latency.Count > 0 {
+			latency.AvgMs = latency.SumSecs / float64(latency.Count) * 1000
+		}
+		snap.Latency[string(o)] = latency
+	}
+	return snap
+}
+
+func counterValue(c prometheus.Counter) uint64 {
+	var m dto.Metric
+	c.Write(&m)
+	return uint64(m.GetCounter().GetValue())
+}
@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"tx-processor/models"
+)
+
+// ErrLeaderboardNotBuilt reports a leaderboard that has only seen increments
+// since it was last empty, so it holds deltas rather than totals
+var ErrLeaderboardNotBuilt = errors.New("leaderboard not built")
+
+// Leaderboard keeps users ranked by total orders and total spend
+type Leaderboard interface {
+	// Increment adds per-user deltas to both rankings
+	Increment(ctx context.Context, updates map[string]*models.UserAnalytics) error
+	// Top returns the highest ranked users, best first, or
+	// ErrLeaderboardNotBuilt until the first Rebuild
+	Top(ctx context.Context, by models.RankBy, limit int) ([]models.UserAnalytics, error)
+	// Rank returns the user's position, shared with any users tied on score,
+	// or nil if the user isn't ranked. It returns ErrLeaderboardNotBuilt until
+	// the first Rebuild.
+	Rank(ctx context.Context, by models.RankBy, userID string) (*models.UserRank, error)
+	// Rebuild replaces both rankings with the given users and returns how
+	// many were loaded. Increments made while it runs are kept.
+	Rebuild(ctx context.Context, users iter.Seq2[models.UserAnalytics, error]) (int, error)
+}
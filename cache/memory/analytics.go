@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"tx-processor/cache"
+	"tx-processor/models"
+)
+
+// MemoryAnalyticsCache is an in-process LRU cache whose entries expire after a TTL
+type MemoryAnalyticsCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // Front is most recently used
+	entries  map[string]*list.Element
+	now      func() time.Time
+}
+
+type entry struct {
+	analytics models.UserAnalytics
+	expiresAt time.Time
+}
+
+func NewMemoryAnalyticsCache(capacity int, ttl time.Duration) *MemoryAnalyticsCache {
+	return &MemoryAnalyticsCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// SetTTL changes how long entries set from now on live
+func (m *MemoryAnalyticsCache) SetTTL(ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ttl = ttl
+}
+
+func (m *MemoryAnalyticsCache) Get(ctx context.Context, userID string) (*models.UserAnalytics, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.entries[userID]
+	if !ok {
+		return nil, cache.ErrCacheMiss
+	}
+
+	e := elem.Value.(*entry)
+	if m.now().After(e.expiresAt) {
+		m.remove(elem)
+		return nil, cache.ErrCacheMiss
+	}
+
+	m.order.MoveToFront(elem)
+	analytics := e.analytics
+	return &analytics, nil
+}
+
+func (m *MemoryAnalyticsCache) Set(ctx context.Context, analytics models.UserAnalytics) error {
+	if m.capacity <= 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiresAt := m.now().Add(m.ttl)
+	if elem, ok := m.entries[analytics.UserID]; ok {
+		elem.Value = &entry{analytics: analytics, expiresAt: expiresAt}
+		m.order.MoveToFront(elem)
+		return nil
+	}
+
+	m.entries[analytics.UserID] = m.order.PushFront(&entry{analytics: analytics, expiresAt: expiresAt})
+	for m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+	}
+
+	return nil
+}
+
+func (m *MemoryAnalyticsCache) Delete(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.entries[userID]; ok {
+		m.remove(elem)
+	}
+	return nil
+}
+
+func (m *MemoryAnalyticsCache) DeleteMany(ctx context.Context, userIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, userID := range userIDs {
+		if elem, ok := m.entries[userID]; ok {
+			m.remove(elem)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryAnalyticsCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	for userID, elem := range m.entries {
+		if strings.HasPrefix(userID, prefix) {
+			m.remove(elem)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Len returns the number of entries, including expired ones not yet evicted
+func (m *MemoryAnalyticsCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// remove must be called with mu held
+func (m *MemoryAnalyticsCache) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.entries, elem.Value.(*entry).analytics.UserID)
+}
@@ -4,24 +4,43 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
+	"tx-processor/cache"
+	"tx-processor/config"
 	"tx-processor/models"
+	"tx-processor/tracing"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("tx-processor/cache/redis")
+
+// scanBatch is how many keys DeletePrefix asks SCAN for, and deletes, at a time
+const scanBatch = 500
+
 type RedisAnalyticsCache struct {
 	client      *redis.Client
 	PrefixState string
-	defaultTTL  time.Duration
+	defaultTTL  atomic.Int64 // time.Duration, swapped on reload
 }
 
-func NewRedisAnalyticsCache(client *redis.Client) *RedisAnalyticsCache {
-	return &RedisAnalyticsCache{
+// NewRedisAnalyticsCache keys analytics under cfg.CachePrefix and keeps them
+// for cfg.CacheTTL
+func NewRedisAnalyticsCache(client *redis.Client, cfg *config.RedisConfig) *RedisAnalyticsCache {
+	r := &RedisAnalyticsCache{
 		client:      client,
-		PrefixState: "analytics:",
-		defaultTTL:  5 * time.Hour,
+		PrefixState: cfg.CachePrefix,
 	}
+	r.SetTTL(cfg.CacheTTL)
+	return r
+}
+
+// SetTTL changes how long analytics cached from now on live
+func (r *RedisAnalyticsCache) SetTTL(ttl time.Duration) {
+	r.defaultTTL.Store(int64(ttl))
 }
 
 func (r *RedisAnalyticsCache) buildKeyState(state string) string {
@@ -29,45 +48,195 @@ func (r *RedisAnalyticsCache) buildKeyState(state string) string {
 }
 
 func (r *RedisAnalyticsCache) Get(ctx context.Context, userID string) (*models.UserAnalytics, error) {
+	ctx, span := tracer.Start(ctx, "RedisAnalyticsCache.Get")
+	defer span.End()
+
 	key := r.buildKeyState(userID)
 
 	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
-		return nil, nil // Cache miss
+		return nil, cache.ErrCacheMiss
 	} else if err != nil {
-		return nil, fmt.Errorf("failed to get user info from cache: %w", err)
+		return nil, tracing.Error(span, fmt.Errorf("failed to get user info from cache: %w", err))
 	}
 
 	var analytics models.UserAnalytics
 	if err := json.Unmarshal([]byte(val), &analytics); err != nil {
-		return nil, err
+		return nil, tracing.Error(span, err)
 	}
 
 	return &analytics, nil
 }
 
 func (r *RedisAnalyticsCache) Set(ctx context.Context, analytics models.UserAnalytics) error {
+	ctx, span := tracer.Start(ctx, "RedisAnalyticsCache.Set")
+	defer span.End()
+
 	key := r.buildKeyState(analytics.UserID)
-	expiration := r.defaultTTL
+	expiration := time.Duration(r.defaultTTL.Load())
 
 	data, err := json.Marshal(analytics)
 	if err != nil {
-		return fmt.Errorf("failed to marshal user info: %w", err)
+		return tracing.Error(span, fmt.Errorf("failed to marshal user info: %w", err))
 	}
 
 	if err := r.client.Set(ctx, key, data, expiration).Err(); err != nil {
-		return fmt.Errorf("failed to set user info in cache: %w", err)
+		return tracing.Error(span, fmt.Errorf("failed to set user info in cache: %w", err))
 	}
 
 	return nil
 }
 
 func (r *RedisAnalyticsCache) Delete(ctx context.Context, userID string) error {
+	ctx, span := tracer.Start(ctx, "RedisAnalyticsCache.Delete")
+	defer span.End()
+
 	key := r.buildKeyState(userID)
 
 	if err := r.client.Del(ctx, key).Err(); err != nil {
-		return fmt.Errorf("failed to delete user info in cache: %w", err)
+		return tracing.Error(span, fmt.Errorf("failed to delete user info in cache: %w", err))
+	}
+
+	return tracing.Error(span, r.publishInvalidation(ctx, cache.Invalidation{UserIDs: []string{userID}}))
+}
+
+func (r *RedisAnalyticsCache) DeleteMany(ctx context.Context, userIDs []string) error {
+	ctx, span := tracer.Start(ctx, "RedisAnalyticsCache.DeleteMany")
+	defer span.End()
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = r.buildKeyState(userID)
+	}
+
+	if err := r.client.Del(ctx, keys...).Err(); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to delete user info in cache: %w", err))
+	}
+
+	return tracing.Error(span, r.publishInvalidation(ctx, cache.Invalidation{UserIDs: userIDs}))
+}
+
+// DeletePrefix scans for matching keys rather than tracking them, deleting
+// them scanBatch at a time. It then announces the prefix rather than the
+// users deleted, so other processes also drop local copies of users Redis no
+// longer held.
+func (r *RedisAnalyticsCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	ctx, span := tracer.Start(ctx, "RedisAnalyticsCache.DeletePrefix")
+	defer span.End()
+
+	keyPrefix := r.buildKeyState("")
+	keys := make([]string, 0, scanBatch)
+	deleted := 0
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		n, err := r.client.Del(ctx, keys...).Result()
+		if err != nil {
+			return fmt.Errorf("failed to delete user info in cache: %w", err)
+		}
+		deleted += int(n)
+		keys = keys[:0]
+		return nil
+	}
+
+	it := r.client.Scan(ctx, 0, escapeGlob(keyPrefix+prefix)+"*", scanBatch).Iterator()
+	for it.Next(ctx) {
+		keys = append(keys, it.Val())
+		if len(keys) == scanBatch {
+			if err := flush(); err != nil {
+				return deleted, tracing.Error(span, err)
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return deleted, tracing.Error(span, fmt.Errorf("failed to scan cache: %w", err))
+	}
+	if err := flush(); err != nil {
+		return deleted, tracing.Error(span, err)
+	}
+	if err := r.publishInvalidation(ctx, cache.Invalidation{Prefix: &prefix}); err != nil {
+		return deleted, tracing.Error(span, err)
+	}
+	return deleted, nil
+}
+
+// escapeGlob quotes the characters SCAN's MATCH pattern treats specially
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (r *RedisAnalyticsCache) invalidationChannel() string {
+	return r.buildKeyState("invalidate")
+}
+
+// publishInvalidation tells other processes to drop their local copies of
+// the users. Its errors wrap cache.ErrPublish, as the delete they follow
+// has already taken effect.
+func (r *RedisAnalyticsCache) publishInvalidation(ctx context.Context, inv cache.Invalidation) error {
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return fmt.Errorf("%w: marshal: %w", cache.ErrPublish, err)
+	}
+
+	if err := r.client.Publish(ctx, r.invalidationChannel(), payload).Err(); err != nil {
+		return fmt.Errorf("%w: %w", cache.ErrPublish, err)
 	}
 
 	return nil
 }
+
+// SubscribeInvalidations calls onInvalidate with the users deleted by any
+// process sharing this Redis, until ctx is cancelled.
+func (r *RedisAnalyticsCache) SubscribeInvalidations(ctx context.Context, onInvalidate func(inv cache.Invalidation)) error {
+	sub := r.client.Subscribe(ctx, r.invalidationChannel())
+	defer sub.Close()
+
+	// Wait for the subscription to be confirmed so no invalidation is missed
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to invalidations: %w", err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var inv cache.Invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				continue // Not ours to handle
+			}
+			onInvalidate(inv)
+		}
+	}
+}
+
+// NewClient connects to Redis and verifies the connection
+func NewClient(ctx context.Context, cfg *config.RedisConfig) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPw,
+		DB:       cfg.RedisDB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+	return client, nil
+}
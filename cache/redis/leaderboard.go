@@ -0,0 +1,295 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"time"
+	"tx-processor/cache"
+	"tx-processor/models"
+	"tx-processor/tracing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// rebuildChunkSize bounds how many members go into a single pipeline during rebuild
+	rebuildChunkSize = 1000
+	// rebuildLease is how long a rebuild holds its lock without staging a
+	// chunk, so a crashed rebuild doesn't keep increments doubling forever
+	rebuildLease = 30 * time.Second
+	// spendTolerance is half a cent. Spend is stored to the cent, so scores
+	// closer than this differ only by float drift from accumulated increments.
+	spendTolerance = 0.005
+)
+
+// incrementScript adds deltas to the live boards and, while a rebuild holds
+// its lock, to the staging boards too, so the rebuild keeps them
+var incrementScript = redis.NewScript(`
+local staging = redis.call('EXISTS', KEYS[5]) == 1
+for i = 1, #ARGV, 3 do
+    redis.call('ZINCRBY', KEYS[1], ARGV[i + 1], ARGV[i])
+    redis.call('ZINCRBY', KEYS[2], ARGV[i + 2], ARGV[i])
+    if staging then
+        redis.call('ZINCRBY', KEYS[3], ARGV[i + 1], ARGV[i])
+        redis.call('ZINCRBY', KEYS[4], ARGV[i + 2], ARGV[i])
+    end
+end
+return 0
+`)
+
+// beginRebuildScript takes the rebuild lock and clears the staging boards,
+// or returns 0 if another rebuild holds the lock
+var beginRebuildScript = redis.NewScript(`
+if not redis.call('SET', KEYS[3], '1', 'NX', 'PX', ARGV[1]) then
+    return 0
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+return 1
+`)
+
+// swapScript moves the staging boards over the live ones and marks them
+// built, or returns 0 if the lock lapsed and increments may have been missed
+var swapScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[5]) == 0 then
+    redis.call('DEL', KEYS[3], KEYS[4])
+    return 0
+end
+for i = 1, 2 do
+    if redis.call('EXISTS', KEYS[i + 2]) == 1 then
+        redis.call('RENAME', KEYS[i + 2], KEYS[i])
+    else
+        redis.call('DEL', KEYS[i])
+    end
+end
+redis.call('SET', KEYS[6], '1')
+redis.call('DEL', KEYS[5])
+return 1
+`)
+
+type RedisLeaderboard struct {
+	client *redis.Client
+	Prefix string
+}
+
+func NewRedisLeaderboard(client *redis.Client) *RedisLeaderboard {
+	return &RedisLeaderboard{
+		client: client,
+		Prefix: "leaderboard",
+	}
+}
+
+func (l *RedisLeaderboard) buildKey(by models.RankBy) string {
+	return fmt.Sprintf("%s:%s", l.Prefix, by)
+}
+
+func (l *RedisLeaderboard) stagingKey(by models.RankBy) string {
+	return l.buildKey(by) + ":rebuild"
+}
+
+// builtKey marks boards loaded by a rebuild; increments alone only hold deltas
+func (l *RedisLeaderboard) builtKey() string {
+	return l.Prefix + ":built"
+}
+
+// rebuildingKey is the rebuild lock, which also routes increments to staging
+func (l *RedisLeaderboard) rebuildingKey() string {
+	return l.Prefix + ":rebuilding"
+}
+
+// boardKeys lists the live boards, then their staging boards
+func (l *RedisLeaderboard) boardKeys() []string {
+	return []string{
+		l.buildKey(models.RankByOrders), l.buildKey(models.RankBySpend),
+		l.stagingKey(models.RankByOrders), l.stagingKey(models.RankBySpend),
+	}
+}
+
+func (l *RedisLeaderboard) Increment(ctx context.Context, updates map[string]*models.UserAnalytics) error {
+	ctx, span := tracer.Start(ctx, "RedisLeaderboard.Increment")
+	defer span.End()
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, 3*len(updates))
+	for userID, update := range updates {
+		args = append(args, userID, update.TotalOrders, update.TotalSpent)
+	}
+
+	keys := append(l.boardKeys(), l.rebuildingKey())
+	if err := incrementScript.Run(ctx, l.client, keys, args...).Err(); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to increment leaderboard: %w", err))
+	}
+	return nil
+}
+
+func (l *RedisLeaderboard) Top(ctx context.Context, by models.RankBy, limit int) ([]models.UserAnalytics, error) {
+	ctx, span := tracer.Start(ctx, "RedisLeaderboard.Top")
+	defer span.End()
+
+	if limit <= 0 {
+		return nil, tracing.Error(span, fmt.Errorf("limit must be positive, got %d", limit))
+	}
+
+	pipe := l.client.Pipeline()
+	builtCmd := pipe.Exists(ctx, l.builtKey())
+	rangeCmd := pipe.ZRevRangeWithScores(ctx, l.buildKey(by), 0, int64(limit-1))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to read leaderboard: %w", err))
+	}
+	if builtCmd.Val() == 0 {
+		return nil, cache.ErrLeaderboardNotBuilt
+	}
+	ranked := rangeCmd.Val()
+	if len(ranked) == 0 {
+		return nil, nil
+	}
+
+	members := make([]string, len(ranked))
+	for i, z := range ranked {
+		members[i] = z.Member.(string)
+	}
+
+	// Fill in the other score so callers get complete totals
+	other := models.RankBySpend
+	if by == models.RankBySpend {
+		other = models.RankByOrders
+	}
+	otherScores, err := l.client.ZMScore(ctx, l.buildKey(other), members...).Result()
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to read leaderboard scores: %w", err))
+	}
+
+	users := make([]models.UserAnalytics, len(ranked))
+	for i, z := range ranked {
+		orders, spent := z.Score, otherScores[i]
+		if by == models.RankBySpend {
+			orders, spent = otherScores[i], z.Score
+		}
+		users[i] = models.UserAnalytics{
+			UserID:      members[i],
+			TotalOrders: int(orders),
+			TotalSpent:  spent,
+		}
+	}
+
+	return users, nil
+}
+
+// Rank counts the users scoring above the user, so tied users share a rank
+// just as they do in the database
+func (l *RedisLeaderboard) Rank(ctx context.Context, by models.RankBy, userID string) (*models.UserRank, error) {
+	ctx, span := tracer.Start(ctx, "RedisLeaderboard.Rank")
+	defer span.End()
+
+	key := l.buildKey(by)
+
+	pipe := l.client.Pipeline()
+	builtCmd := pipe.Exists(ctx, l.builtKey())
+	scoreCmd := pipe.ZScore(ctx, key, userID)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, tracing.Error(span, fmt.Errorf("failed to read leaderboard rank: %w", err))
+	}
+	if builtCmd.Val() == 0 {
+		return nil, cache.ErrLeaderboardNotBuilt
+	}
+
+	score, err := scoreCmd.Result()
+	if err == redis.Nil {
+		return nil, nil // Not ranked
+	} else if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to read leaderboard rank: %w", err))
+	}
+
+	above := score
+	if by == models.RankBySpend {
+		above += spendTolerance
+	}
+	higher, err := l.client.ZCount(ctx, key, "("+strconv.FormatFloat(above, 'f', -1, 64), "+inf").Result()
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to read leaderboard rank: %w", err))
+	}
+
+	return &models.UserRank{
+		UserID: userID,
+		By:     by,
+		Rank:   int(higher) + 1,
+		Score:  score,
+	}, nil
+}
+
+// Rebuild loads users into staging boards and swaps them over the live ones,
+// so readers never see a partially built leaderboard. While it runs,
+// increments go to the staging boards as well as the live ones; one from a
+// batch committed just before users were read can count twice until the next
+// rebuild, but none are lost.
+func (l *RedisLeaderboard) Rebuild(ctx context.Context, users iter.Seq2[models.UserAnalytics, error]) (count int, err error) {
+	ctx, span := tracer.Start(ctx, "RedisLeaderboard.Rebuild")
+	defer span.End()
+
+	keys := l.boardKeys()
+	started, err := beginRebuildScript.Run(ctx, l.client,
+		[]string{keys[2], keys[3], l.rebuildingKey()}, rebuildLease.Milliseconds()).Int()
+	if err != nil {
+		return 0, tracing.Error(span, fmt.Errorf("failed to start leaderboard rebuild: %w", err))
+	}
+	if started == 0 {
+		return 0, tracing.Error(span, fmt.Errorf("a leaderboard rebuild is already running"))
+	}
+	defer func() {
+		if err != nil {
+			// Release the lock so increments stop going to staging
+			l.client.Del(context.WithoutCancel(ctx), l.rebuildingKey(), keys[2], keys[3])
+		}
+	}()
+
+	// Users are added to whatever increments staging has collected
+	var chunk []models.UserAnalytics
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		pipe := l.client.Pipeline()
+		for _, user := range chunk {
+			pipe.ZIncrBy(ctx, keys[2], float64(user.TotalOrders), user.UserID)
+			pipe.ZIncrBy(ctx, keys[3], user.TotalSpent, user.UserID)
+		}
+		pipe.PExpire(ctx, l.rebuildingKey(), rebuildLease)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to stage leaderboard: %w", err)
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for user, err := range users {
+		if err != nil {
+			return count, tracing.Error(span, err)
+		}
+		chunk = append(chunk, user)
+		count++
+
+		if len(chunk) >= rebuildChunkSize {
+			if err := flush(); err != nil {
+				return count, tracing.Error(span, err)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return count, tracing.Error(span, err)
+	}
+
+	swapped, err := swapScript.Run(ctx, l.client, append(keys, l.rebuildingKey(), l.builtKey())).Int()
+	if err != nil {
+		return count, tracing.Error(span, fmt.Errorf("failed to swap leaderboard: %w", err))
+	}
+	if swapped == 0 {
+		return count, tracing.Error(span, fmt.Errorf("leaderboard rebuild lock lapsed after %s without progress", rebuildLease))
+	}
+
+	return count, nil
+}
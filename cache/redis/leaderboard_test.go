@@ -0,0 +1,245 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+	"tx-processor/cache"
+	"tx-processor/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestLeaderboard(t *testing.T) (*RedisLeaderboard, *miniredis.Miniredis) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLeaderboard(client), m
+}
+
+// seq yields users, calling during after the first one is read
+func seq(during func(), users ...models.UserAnalytics) iter.Seq2[models.UserAnalytics, error] {
+	return func(yield func(models.UserAnalytics, error) bool) {
+		for i, user := range users {
+			if !yield(user, nil) {
+				return
+			}
+			if i == 0 && during != nil {
+				during()
+			}
+		}
+	}
+}
+
+func user(id string, orders int, spent float64) models.UserAnalytics {
+	return models.UserAnalytics{UserID: id, TotalOrders: orders, TotalSpent: spent}
+}
+
+func increment(t *testing.T, l *RedisLeaderboard, updates ...models.UserAnalytics) {
+	t.Helper()
+	deltas := make(map[string]*models.UserAnalytics)
+	for _, u := range updates {
+		deltas[u.UserID] = &u
+	}
+	if err := l.Increment(context.Background(), deltas); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func top(t *testing.T, l *RedisLeaderboard, by models.RankBy) []models.UserAnalytics {
+	t.Helper()
+	users, err := l.Top(context.Background(), by, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return users
+}
+
+func TestLeaderboardNotBuilt(t *testing.T) {
+	l, _ := newTestLeaderboard(t)
+	increment(t, l, user("u1", 1, 10))
+
+	if _, err := l.Top(context.Background(), models.RankByOrders, 10); !errors.Is(err, cache.ErrLeaderboardNotBuilt) {
+		t.Errorf("Top = %v, want ErrLeaderboardNotBuilt", err)
+	}
+	if _, err := l.Rank(context.Background(), models.RankBySpend, "u1"); !errors.Is(err, cache.ErrLeaderboardNotBuilt) {
+		t.Errorf("Rank = %v, want ErrLeaderboardNotBuilt", err)
+	}
+}
+
+func TestLeaderboardIncrementRebuildSwap(t *testing.T) {
+	l, _ := newTestLeaderboard(t)
+	ctx := context.Background()
+
+	// Increments before the first rebuild are replaced by its totals
+	increment(t, l, user("stale", 100, 1000))
+
+	// One committed mid-rebuild lands in both the live and staging boards
+	during := func() { increment(t, l, user("u2", 5, 50), user("u4", 1, 1)) }
+	count, err := l.Rebuild(ctx, seq(during,
+		user("u1", 3, 300),
+		user("u2", 2, 20),
+		user("u3", 4, 40),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Rebuild loaded %d users, want 3", count)
+	}
+
+	increment(t, l, user("u3", 1, 5))
+
+	wantOrders := []models.UserAnalytics{user("u2", 7, 70), user("u3", 5, 45), user("u1", 3, 300), user("u4", 1, 1)}
+	if got := top(t, l, models.RankByOrders); !slices.Equal(got, wantOrders) {
+		t.Errorf("Top by orders = %v, want %v", got, wantOrders)
+	}
+	wantSpend := []models.UserAnalytics{user("u1", 3, 300), user("u2", 7, 70), user("u3", 5, 45), user("u4", 1, 1)}
+	if got := top(t, l, models.RankBySpend); !slices.Equal(got, wantSpend) {
+		t.Errorf("Top by spend = %v, want %v", got, wantSpend)
+	}
+
+	page, err := l.Top(ctx, models.RankByOrders, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(page, wantOrders[:2]) {
+		t.Errorf("Top 2 by orders = %v, want %v", page, wantOrders[:2])
+	}
+
+	rank, err := l.Rank(ctx, models.RankBySpend, "u3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rank == nil || rank.Rank != 3 || rank.Score != 45 {
+		t.Errorf("Rank of u3 by spend = %+v, want 3rd with 45", rank)
+	}
+	if rank, err := l.Rank(ctx, models.RankByOrders, "stale"); err != nil || rank != nil {
+		t.Errorf("Rank of a user dropped by the rebuild = %+v, %v; want nil", rank, err)
+	}
+
+	// A later rebuild replaces the totals it loaded
+	if _, err := l.Rebuild(ctx, seq(nil, user("u1", 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := top(t, l, models.RankByOrders), []models.UserAnalytics{user("u1", 1, 1)}; !slices.Equal(got, want) {
+		t.Errorf("Top after a second rebuild = %v, want %v", got, want)
+	}
+}
+
+func TestLeaderboardTiesShareRank(t *testing.T) {
+	l, _ := newTestLeaderboard(t)
+	ctx := context.Background()
+
+	if _, err := l.Rebuild(ctx, seq(nil,
+		user("a", 5, 0.3),
+		user("b", 5, 0.1),
+		user("c", 2, 0.2),
+	)); err != nil {
+		t.Fatal(err)
+	}
+	// b's spend drifts to 0.30000000000000004, which still ties with a's 0.3
+	increment(t, l, user("b", 0, 0.2))
+
+	tests := []struct {
+		by   models.RankBy
+		user string
+		want int
+	}{
+		{models.RankByOrders, "a", 1},
+		{models.RankByOrders, "b", 1},
+		{models.RankByOrders, "c", 3},
+		{models.RankBySpend, "a", 1},
+		{models.RankBySpend, "b", 1},
+		{models.RankBySpend, "c", 3},
+	}
+	for _, tt := range tests {
+		rank, err := l.Rank(ctx, tt.by, tt.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rank == nil || rank.Rank != tt.want {
+			t.Errorf("Rank of %s by %s = %+v, want %d", tt.user, tt.by, rank, tt.want)
+		}
+	}
+}
+
+func TestLeaderboardRebuildFailures(t *testing.T) {
+	ctx := context.Background()
+	built := []models.UserAnalytics{user("u1", 1, 10)}
+
+	tests := []struct {
+		name    string
+		users   func(l *RedisLeaderboard, m *miniredis.Miniredis) iter.Seq2[models.UserAnalytics, error]
+		wantErr string
+	}{
+		{
+			name: "source error",
+			users: func(*RedisLeaderboard, *miniredis.Miniredis) iter.Seq2[models.UserAnalytics, error] {
+				return func(yield func(models.UserAnalytics, error) bool) {
+					if yield(user("u2", 9, 90), nil) {
+						yield(models.UserAnalytics{}, errors.New("connection reset"))
+					}
+				}
+			},
+			wantErr: "connection reset",
+		},
+		{
+			name: "concurrent rebuild",
+			users: func(l *RedisLeaderboard, _ *miniredis.Miniredis) iter.Seq2[models.UserAnalytics, error] {
+				return seq(func() {
+					if _, err := l.Rebuild(ctx, seq(nil)); err == nil || !strings.Contains(err.Error(), "already running") {
+						t.Errorf("concurrent Rebuild = %v, want already running", err)
+					}
+				}, user("u2", 9, 90), user("u3", 9, 90))
+			},
+		},
+		{
+			name: "lease lapsed",
+			users: func(_ *RedisLeaderboard, m *miniredis.Miniredis) iter.Seq2[models.UserAnalytics, error] {
+				return func(yield func(models.UserAnalytics, error) bool) {
+					m.FastForward(rebuildLease + 1)
+					yield(user("u2", 9, 90), nil)
+				}
+			},
+			wantErr: "lock lapsed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, m := newTestLeaderboard(t)
+			if _, err := l.Rebuild(ctx, seq(nil, built...)); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := l.Rebuild(ctx, tt.users(l, m))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Rebuild = %v, want error containing %q", err, tt.wantErr)
+			}
+
+			// The live boards are untouched and the next rebuild can start
+			if got := top(t, l, models.RankByOrders); !slices.Equal(got, built) {
+				t.Errorf("Top after a failed rebuild = %v, want %v", got, built)
+			}
+			for _, key := range []string{l.rebuildingKey(), l.stagingKey(models.RankByOrders), l.stagingKey(models.RankBySpend)} {
+				if m.Exists(key) {
+					t.Errorf("%s left behind", key)
+				}
+			}
+			if _, err := l.Rebuild(ctx, seq(nil, built...)); err != nil {
+				t.Errorf("Rebuild after a failed one: %v", err)
+			}
+		})
+	}
+}
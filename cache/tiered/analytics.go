@@ -0,0 +1,66 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"tx-processor/cache"
+	"tx-processor/models"
+)
+
+// TieredAnalyticsCache checks a fast local cache before a shared remote one,
+// copying remote hits into the local tier.
+type TieredAnalyticsCache struct {
+	local  cache.AnalyticsCache
+	remote cache.AnalyticsCache
+}
+
+func NewTieredAnalyticsCache(local, remote cache.AnalyticsCache) *TieredAnalyticsCache {
+	return &TieredAnalyticsCache{
+		local:  local,
+		remote: remote,
+	}
+}
+
+func (t *TieredAnalyticsCache) Get(ctx context.Context, userID string) (*models.UserAnalytics, error) {
+	if analytics, err := t.local.Get(ctx, userID); err == nil {
+		return analytics, nil
+	}
+
+	analytics, err := t.remote.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed local fill only costs another remote lookup
+	_ = t.local.Set(ctx, *analytics)
+	return analytics, nil
+}
+
+func (t *TieredAnalyticsCache) Set(ctx context.Context, analytics models.UserAnalytics) error {
+	return errors.Join(
+		t.remote.Set(ctx, analytics),
+		t.local.Set(ctx, analytics),
+	)
+}
+
+func (t *TieredAnalyticsCache) Delete(ctx context.Context, userID string) error {
+	return errors.Join(
+		t.remote.Delete(ctx, userID),
+		t.local.Delete(ctx, userID),
+	)
+}
+
+func (t *TieredAnalyticsCache) DeleteMany(ctx context.Context, userIDs []string) error {
+	return errors.Join(
+		t.remote.DeleteMany(ctx, userIDs),
+		t.local.DeleteMany(ctx, userIDs),
+	)
+}
+
+// DeletePrefix reports the entries removed from the remote tier, which holds
+// every user the local one does and more
+func (t *TieredAnalyticsCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	deleted, remoteErr := t.remote.DeletePrefix(ctx, prefix)
+	_, localErr := t.local.DeletePrefix(ctx, prefix)
+	return deleted, errors.Join(remoteErr, localErr)
+}
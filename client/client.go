@@ -0,0 +1,521 @@
+// Package client is a typed Go client for the /v1 HTTP API described in
+// api/openapi.json.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"tx-processor/models"
+)
+
+// Error is a non-2xx response from the API
+type Error struct {
+	StatusCode int
+	Code       string // One of the models.ErrCode* values, empty if the body was not an error envelope
+	Message    string
+	RetryAfter time.Duration // From the Retry-After header of rate limited responses
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("tx-processor: HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("tx-processor: HTTP %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// ErrEventsDropped reports that the server dropped events because the client read too slowly
+var ErrEventsDropped = errors.New("tx-processor: events dropped, refetch current state")
+
+// IsRateLimited reports whether err is an API rate_limited error; its
+// RetryAfter says how long to back off
+func IsRateLimited(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == models.ErrCodeRateLimited
+}
+
+// IsNotFound reports whether err is an API not_found error
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == models.ErrCodeNotFound
+}
+
+// IsConflict reports whether err is an API conflict error, such as starting
+// a job while one of its kind runs
+func IsConflict(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == models.ErrCodeConflict
+}
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	header     http.Header
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests through httpClient instead of a default
+// client with a 30s timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader adds a header to every request, e.g. for credentials
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithAPIKey authenticates every request with an API key
+func WithAPIKey(key string) Option {
+	return WithHeader("X-API-Key", key)
+}
+
+// WithBearerToken authenticates every request with a JWT
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// New returns a client for the API served at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("base url %q must include scheme and host", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		header:     http.Header{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// UserAnalytics returns a user's lifetime totals; unknown users have zero totals
+func (c *Client) UserAnalytics(ctx context.Context, userID string) (*models.UserAnalytics, error) {
+	return get[models.UserAnalytics](ctx, c, "/v1/users/"+url.PathEscape(userID)+"/analytics", nil)
+}
+
+// UserVelocity returns a user's activity within the window ending at anchor;
+// zero values use the server's defaults, the configured window ending now
+func (c *Client) UserVelocity(ctx context.Context, userID string, window time.Duration, anchor models.VelocityAnchor) (*models.UserVelocity, error) {
+	query := url.Values{}
+	if window > 0 {
+		query.Set("window", window.String())
+	}
+	if anchor != "" {
+		query.Set("anchor", string(anchor))
+	}
+	return get[models.UserVelocity](ctx, c, "/v1/users/"+url.PathEscape(userID)+"/velocity", query)
+}
+
+// UserRank returns a user's rank by orders or spend. A user with no
+// analytics yields an error satisfying IsNotFound.
+func (c *Client) UserRank(ctx context.Context, userID string, by models.RankBy) (*models.UserRank, error) {
+	query := url.Values{}
+	if by != "" {
+		query.Set("by", string(by))
+	}
+	return get[models.UserRank](ctx, c, "/v1/users/"+url.PathEscape(userID)+"/rank", query)
+}
+
+// UserProfile returns a user's totals, order times, ranks, percentiles and
+// anomaly status. A user with no analytics yields an error satisfying IsNotFound.
+func (c *Client) UserProfile(ctx context.Context, userID string) (*models.UserProfile, error) {
+	return get[models.UserProfile](ctx, c, "/v1/users/"+url.PathEscape(userID)+"/profile", nil)
+}
+
+// UserProfiles returns profiles for up to 100 users in one request
+func (c *Client) UserProfiles(ctx context.Context, userIDs []string) (*models.UserProfiles, error) {
+	request := struct {
+		UserIDs []string `json:"user_ids"`
+	}{UserIDs: userIDs}
+	return post[models.UserProfiles](ctx, c, "/v1/users/profiles", request)
+}
+
+// Leaderboard returns one page of users sorted by query.Sort. Zero fields use
+// the server's defaults; pass the previous page's NextCursor, parsed with
+// models.ParseCursor, as query.After to continue.
+func (c *Client) Leaderboard(ctx context.Context, query models.UserQuery) (*models.TopUsers, error) {
+	return get[models.TopUsers](ctx, c, "/v1/leaderboard", userQueryValues(query))
+}
+
+// AllUsers pages through every user matching query, stopping at the first error
+func (c *Client) AllUsers(ctx context.Context, query models.UserQuery) iter.Seq2[models.UserSummary, error] {
+	return func(yield func(models.UserSummary, error) bool) {
+		for {
+			page, err := c.Leaderboard(ctx, query)
+			if err != nil {
+				yield(models.UserSummary{}, err)
+				return
+			}
+			for _, user := range page.Users {
+				if !yield(user, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			if query.After, err = models.ParseCursor(page.NextCursor); err != nil {
+				yield(models.UserSummary{}, fmt.Errorf("parse next cursor: %w", err))
+				return
+			}
+		}
+	}
+}
+
+func userQueryValues(query models.UserQuery) url.Values {
+	values := url.Values{}
+	if query.Sort != "" {
+		values.Set("sort", string(query.Sort))
+	}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.After != nil {
+		values.Set("cursor", query.After.Encode())
+	}
+
+	filter := query.Filter
+	if filter.MinOrders != nil {
+		values.Set("min_orders", strconv.Itoa(*filter.MinOrders))
+	}
+	if filter.MaxOrders != nil {
+		values.Set("max_orders", strconv.Itoa(*filter.MaxOrders))
+	}
+	if filter.MinSpent != nil {
+		values.Set("min_spent", strconv.FormatFloat(*filter.MinSpent, 'f', -1, 64))
+	}
+	if filter.MaxSpent != nil {
+		values.Set("max_spent", strconv.FormatFloat(*filter.MaxSpent, 'f', -1, 64))
+	}
+	if filter.UpdatedSince != nil {
+		values.Set("updated_since", filter.UpdatedSince.Format(time.RFC3339Nano))
+	}
+	return values
+}
+
+// ExportUsers streams every user matching filter in the given format. The
+// caller must close the returned body; a read error means the export was cut
+// short by the server.
+func (c *Client) ExportUsers(ctx context.Context, format models.ExportFormat, filter models.UserFilter) (io.ReadCloser, error) {
+	query := userQueryValues(models.UserQuery{Filter: filter})
+	query.Set("format", string(format))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL.String()+"/v1/export/users?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+
+	// Exports can run far longer than the client's request timeout
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GET /v1/export/users: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp.Body, nil
+}
+
+// Events streams live updates matching filter until ctx is cancelled or the
+// server ends the stream. The server reports events it had to drop for a
+// slow reader as an error satisfying errors.Is(err, ErrEventsDropped); the
+// stream continues after it.
+func (c *Client) Events(ctx context.Context, filter models.EventFilter) iter.Seq2[models.Event, error] {
+	return func(yield func(models.Event, error) bool) {
+		query := url.Values{}
+		for _, eventType := range filter.Types {
+			query.Add("type", string(eventType))
+		}
+		for _, userID := range filter.UserIDs {
+			query.Add("user_id", userID)
+		}
+		if filter.By != "" {
+			query.Set("by", string(filter.By))
+		}
+		if filter.Top > 0 {
+			query.Set("top", strconv.Itoa(filter.Top))
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL.String()+"/v1/events?"+query.Encode(), nil)
+		if err != nil {
+			yield(models.Event{}, fmt.Errorf("build request: %w", err))
+			return
+		}
+		for key, values := range c.header {
+			req.Header[key] = values
+		}
+		req.Header.Set("Accept", "text/event-stream")
+
+		httpClient := *c.httpClient
+		httpClient.Timeout = 0
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			yield(models.Event{}, fmt.Errorf("GET /v1/events: %w", err))
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			yield(models.Event{}, decodeError(resp))
+			return
+		}
+
+		var eventName, data string
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				eventName = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && data != "":
+				var event models.Event
+				err := json.Unmarshal([]byte(data), &event)
+				if eventName == "dropped" {
+					err = ErrEventsDropped
+				} else if err != nil {
+					err = fmt.Errorf("decode event: %w", err)
+				}
+				if !yield(event, err) {
+					return
+				}
+				eventName, data = "", ""
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			yield(models.Event{}, fmt.Errorf("read events: %w", err))
+		}
+	}
+}
+
+// Anomalies returns users whose lifetime totals are statistical outliers
+func (c *Client) Anomalies(ctx context.Context) ([]models.AnomalyUser, error) {
+	anomalies, err := get[[]models.AnomalyUser](ctx, c, "/v1/anomalies", nil)
+	if err != nil {
+		return nil, err
+	}
+	return *anomalies, nil
+}
+
+// VelocityAnomalies returns users whose busiest window within the rule's
+// lookback reached its thresholds. Zero fields fall back to the server's
+// configured rule.
+func (c *Client) VelocityAnomalies(ctx context.Context, rule models.VelocityRule) ([]models.VelocityAnomaly, error) {
+	query := url.Values{}
+	if rule.Window > 0 {
+		query.Set("window", rule.Window.String())
+	}
+	if rule.OrderThreshold > 0 {
+		query.Set("min_orders", strconv.Itoa(rule.OrderThreshold))
+	}
+	if rule.SpendThreshold > 0 {
+		query.Set("min_spent", strconv.FormatFloat(rule.SpendThreshold, 'f', -1, 64))
+	}
+	if rule.Lookback > 0 {
+		query.Set("lookback", rule.Lookback.String())
+	}
+
+	anomalies, err := get[[]models.VelocityAnomaly](ctx, c, "/v1/anomalies/velocity", query)
+	if err != nil {
+		return nil, err
+	}
+	return *anomalies, nil
+}
+
+// Settings returns the server's reloadable settings, keyed by environment
+// variable. It needs the admin scope.
+func (c *Client) Settings(ctx context.Context) (*models.RuntimeSettings, error) {
+	return get[models.RuntimeSettings](ctx, c, "/v1/admin/settings", nil)
+}
+
+// UpdateSettings overrides reloadable settings, such as LOG_LEVEL, until the
+// server next reloads its configuration
+func (c *Client) UpdateSettings(ctx context.Context, settings map[string]string) (*models.RuntimeSettings, error) {
+	request := struct {
+		Settings map[string]string `json:"settings"`
+	}{Settings: settings}
+	return send[models.RuntimeSettings](ctx, c, http.MethodPatch, "/v1/admin/settings", request)
+}
+
+// ReloadSettings has the server re-read its config file and environment, as
+// SIGHUP does, dropping settings overridden with UpdateSettings
+func (c *Client) ReloadSettings(ctx context.Context) (*models.RuntimeSettings, error) {
+	return send[models.RuntimeSettings](ctx, c, http.MethodPost, "/v1/admin/settings/reload", nil)
+}
+
+// InvalidateUserCache drops a user's cached analytics, so the next read goes
+// to the database. It needs the admin scope, as do the admin calls below.
+func (c *Client) InvalidateUserCache(ctx context.Context, userID string) (*models.CacheInvalidation, error) {
+	return send[models.CacheInvalidation](ctx, c, http.MethodDelete, "/v1/admin/cache/users/"+url.PathEscape(userID), nil)
+}
+
+// InvalidateCachePrefix drops cached analytics for users whose IDs start
+// with prefix, or for every user when prefix is empty
+func (c *Client) InvalidateCachePrefix(ctx context.Context, prefix string) (*models.CacheInvalidation, error) {
+	query := url.Values{"prefix": {prefix}}
+	if prefix == "" {
+		query = url.Values{"all": {"true"}}
+	}
+	return send[models.CacheInvalidation](ctx, c, http.MethodDelete, "/v1/admin/cache/users?"+query.Encode(), nil)
+}
+
+// RebuildLeaderboard starts repopulating the leaderboard from the database.
+// Poll the returned job with Job; starting a second rebuild while one runs
+// yields an error satisfying IsConflict.
+func (c *Client) RebuildLeaderboard(ctx context.Context) (*models.Job, error) {
+	return post[models.Job](ctx, c, "/v1/admin/leaderboard/rebuild", nil)
+}
+
+// PruneActivity starts deleting activity older than olderThan; zero uses the
+// server's configured retention
+func (c *Client) PruneActivity(ctx context.Context, olderThan time.Duration) (*models.Job, error) {
+	path := "/v1/admin/retention/prune"
+	if olderThan > 0 {
+		path += "?" + url.Values{"older_than": {olderThan.String()}}.Encode()
+	}
+	return post[models.Job](ctx, c, path, nil)
+}
+
+// Ingest starts processing a transaction file, named relative to the
+// server's INGEST_DIR
+func (c *Client) Ingest(ctx context.Context, file string) (*models.Job, error) {
+	request := struct {
+		File string `json:"file"`
+	}{File: file}
+	return post[models.Job](ctx, c, "/v1/admin/ingest", request)
+}
+
+// Jobs lists running and recently finished admin jobs, newest first
+func (c *Client) Jobs(ctx context.Context) ([]models.Job, error) {
+	jobs, err := get[[]models.Job](ctx, c, "/v1/admin/jobs", nil)
+	if err != nil {
+		return nil, err
+	}
+	return *jobs, nil
+}
+
+// Job returns an admin job. An unknown ID yields an error satisfying IsNotFound.
+func (c *Client) Job(ctx context.Context, id string) (*models.Job, error) {
+	return get[models.Job](ctx, c, "/v1/admin/jobs/"+url.PathEscape(id), nil)
+}
+
+// CancelJob stops a running job and returns it once stopped, or still
+// running if the server's deadline passed first. A finished job yields an
+// error satisfying IsConflict.
+func (c *Client) CancelJob(ctx context.Context, id string) (*models.Job, error) {
+	return post[models.Job](ctx, c, "/v1/admin/jobs/"+url.PathEscape(id)+"/cancel", nil)
+}
+
+// ServerInfo returns the server's build, uptime, schema version and
+// configuration, with secrets redacted
+func (c *Client) ServerInfo(ctx context.Context) (*models.ServerInfo, error) {
+	return get[models.ServerInfo](ctx, c, "/v1/admin/info", nil)
+}
+
+func get[T any](ctx context.Context, c *Client, path string, query url.Values) (*T, error) {
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	return do[T](ctx, c, http.MethodGet, target, nil)
+}
+
+func post[T any](ctx context.Context, c *Client, path string, body any) (*T, error) {
+	return send[T](ctx, c, http.MethodPost, path, body)
+}
+
+// send encodes body, when not nil, as the JSON request body
+func send[T any](ctx context.Context, c *Client, method, path string, body any) (*T, error) {
+	if body == nil {
+		return do[T](ctx, c, method, path, nil)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s request: %w", path, err)
+	}
+	return do[T](ctx, c, method, path, data)
+}
+
+func do[T any](ctx context.Context, c *Client, method, path string, body []byte) (*T, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, decodeError(resp)
+	}
+
+	var envelope models.Response[T]
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("decode %s response: %w", path, err)
+	}
+	if envelope.Data == nil {
+		return nil, fmt.Errorf("%s response has no data", path)
+	}
+	return envelope.Data, nil
+}
+
+// decodeError turns a failed response into an *Error, using the v1 error
+// envelope when the body has one
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	var envelope models.Response[struct{}]
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err == nil && envelope.Error != nil {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+	}
+	return apiErr
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"tx-processor/config"
+)
+
+// configFlags defines the flags of "config print", which are every setting's
+func configFlags(string) (*flag.FlagSet, *config.Loader) {
+	flags := newFlagSet("config print")
+	return flags, config.RegisterFlags(flags)
+}
+
+// printConfig writes the configuration the other commands would run with,
+// after the file, environment and flags are applied, with secrets redacted
+func printConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return usageError("config", args)
+	}
+	flags, loader := configFlags(args[0])
+	flags.Parse(args[1:])
+
+	cfg, err := loader.Load()
+	if err != nil {
+		return err
+	}
+	return cfg.Print(os.Stdout)
+}
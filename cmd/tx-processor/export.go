@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+	"tx-processor/config"
+	"tx-processor/db"
+	"tx-processor/export"
+	logging "tx-processor/logger"
+	"tx-processor/models"
+	"tx-processor/repository"
+)
+
+func exportUsers(args []string) error {
+	flags := newFlagSet("export")
+	format := flags.String("format", string(models.ExportCSV), "Output format: csv, ndjson or parquet")
+	out := flags.String("out", "-", "Output file, - for stdout")
+	updatedSince := flags.String("updated-since", "", "Only users updated at or after this RFC 3339 time")
+	minOrders := flags.Int("min-orders", 0, "Only users with at least this many orders")
+	loader := config.RegisterFlags(flags)
+	flags.Parse(args)
+
+	if !models.ExportFormat(*format).Valid() {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	var filter models.UserFilter
+	if *updatedSince != "" {
+		since, err := time.Parse(time.RFC3339, *updatedSince)
+		if err != nil {
+			return fmt.Errorf("updated-since: %w", err)
+		}
+		filter.UpdatedSince = &since
+	}
+	if *minOrders > 0 {
+		filter.MinOrders = minOrders
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	// Logs go to stderr so they can't corrupt an export written to stdout
+	logger, err := logging.New(os.Stderr, cfg.LogConfig.Options())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	dbConn, err := db.NewPostgresDB(&cfg.DatabaseConfig)
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer dbConn.Close()
+
+	output := os.Stdout
+	if *out != "-" {
+		// Write beside the target and rename, so a failed export never leaves a partial file
+		output, err = os.CreateTemp(filepath.Dir(*out), filepath.Base(*out)+".*.tmp")
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer os.Remove(output.Name())
+		defer output.Close()
+		if err := output.Chmod(0o644); err != nil {
+			return fmt.Errorf("chmod output: %w", err)
+		}
+	}
+
+	writer, err := export.NewWriter(models.ExportFormat(*format), output)
+	if err != nil {
+		return err
+	}
+
+	repo := repository.NewAnalyticsRepo(dbConn, logger)
+	start := time.Now()
+	count, err := export.Copy(writer, repo.ExportUsers(ctx, filter))
+	if err != nil {
+		return fmt.Errorf("export after %d users: %w", count, err)
+	}
+
+	if *out != "-" {
+		if err := output.Close(); err != nil {
+			return fmt.Errorf("close output: %w", err)
+		}
+		if err := os.Rename(output.Name(), *out); err != nil {
+			return fmt.Errorf("rename output: %w", err)
+		}
+	}
+
+	logger.Info("Export complete",
+		"format", *format,
+		"users", count,
+		"elapsed_sec", time.Since(start).Seconds())
+	return nil
+}
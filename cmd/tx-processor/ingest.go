@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	rds "tx-processor/cache/redis"
+	"tx-processor/config"
+	"tx-processor/db"
+	"tx-processor/events"
+	logging "tx-processor/logger"
+	"tx-processor/metrics"
+	"tx-processor/processor"
+	"tx-processor/repository"
+	"tx-processor/services"
+	"tx-processor/tracing"
+)
+
+func ingest(args []string) error {
+	flags := newFlagSet("ingest")
+	loader := config.RegisterFlags(flags)
+	filePath := flags.String("file", "", "Path to the JSON file (required)")
+	// Shorthands kept from before the ingest settings moved into config
+	flags.Func("workers", "Number of concurrent workers, as -ingest-workers", func(v string) error {
+		return flags.Set("ingest-workers", v)
+	})
+	flags.Func("batch", "Batch size for processing, as -ingest-batch-size", func(v string) error {
+		return flags.Set("ingest-batch-size", v)
+	})
+	metricsAddr := flags.String("metrics-addr", "", "Serve Prometheus metrics on this address while running, e.g. :9091")
+	flags.Parse(args)
+
+	if *filePath == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		return err
+	}
+	return processFile(loader, cfg, *filePath, *metricsAddr)
+}
+
+func processFile(loader *config.Loader, cfg *config.Config, filePath string, metricsAddr string) error {
+	workerCount, batchSize := cfg.IngestConfig.Workers, cfg.IngestConfig.BatchSize
+	logger, err := logging.New(os.Stdout, cfg.LogConfig.Options())
+	if err != nil {
+		return err
+	}
+	logger.Info("Starting transaction processor",
+		"file", filePath,
+		"workers", workerCount,
+		"batch_size", batchSize)
+
+	dbConn, err := db.NewPostgresDB(&cfg.DatabaseConfig)
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer dbConn.Close()
+
+	// A signal, or a worker failing, stops reading input
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Batches commit under their own context so stopping doesn't lose the
+	// transactions already read; they get ShutdownTimeout to finish
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	stopDrain := context.AfterFunc(ctx, func() {
+		cancel() // A second signal kills the process
+		logger.Warn("stopping, committing transactions already read", "timeout", cfg.ShutdownTimeout)
+		time.AfterFunc(cfg.ShutdownTimeout, cancelWork)
+	})
+	defer stopDrain()
+
+	shutdownTracing, err := tracing.Setup(ctx, &cfg.TracingConfig)
+	if err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	repo := repository.NewAnalyticsRepo(dbConn, logger)
+
+	// SIGHUP re-reads the config file, so a long ingest can be retuned
+	store := config.NewStore(loader, cfg, logger)
+	store.Subscribe(func(c *config.Config) { logger.Reconfigure(c.LogConfig.Options()) })
+	go store.ReloadOnSignal(ctx, syscall.SIGHUP)
+
+	var opts []processor.Option
+	if cfg.RedisConfig.RedisEnabled {
+		redisClient, err := rds.NewClient(ctx, &cfg.RedisConfig)
+		if err != nil {
+			return fmt.Errorf("redis connect: %w", err)
+		}
+		defer redisClient.Close()
+		redisCache := rds.NewRedisAnalyticsCache(redisClient, &cfg.RedisConfig)
+		store.Subscribe(func(c *config.Config) { redisCache.SetTTL(c.RedisConfig.CacheTTL) })
+		// Invalidating through a service keeps the cache policy in one place
+		invalidator := services.NewAnalyticsService(repo, redisCache, services.WithCacheFills(0, 0))
+		defer invalidator.Close()
+		opts = append(opts,
+			processor.WithLeaderboard(rds.NewRedisLeaderboard(redisClient)),
+			processor.WithInvalidator(invalidator),
+			processor.WithPublisher(events.NewRedisPublisher(redisClient)),
+		)
+	}
+
+	registry := metrics.NewRegistry()
+	opts = append(opts,
+		processor.WithMetrics(metrics.NewIngestMetrics(registry)),
+		// Counts the users seen for the summary
+		processor.WithSnapshot(),
+	)
+
+	if metricsAddr != "" {
+		metricsServer := &http.Server{Addr: metricsAddr, Handler: metrics.Handler(registry)}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("metrics server failed", "addr", metricsAddr, "error", err)
+			}
+		}()
+		defer metricsServer.Close()
+		logger.Info("Serving metrics", "addr", metricsAddr)
+	}
+
+	proc := processor.NewProcessor(cfg, logger, repo, opts...)
+	metrics.RegisterQueueDepth(registry, proc.QueueDepth)
+	store.Subscribe(func(c *config.Config) { proc.SetBatchSize(c.IngestConfig.BatchSize) })
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	start := time.Now()
+	totalLines, err := proc.Ingest(ctx, workCtx, file, workerCount, cfg.IngestConfig.Buffer, nil)
+
+	if ctx.Err() != nil {
+		logger.Warn("Processing interrupted before completion", "transactions_read", totalLines)
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start).Seconds()
+	throughput := float64(totalLines) / elapsed
+
+	stats := proc.Snapshot()
+	logger.Info("Processing complete",
+		"transactions", totalLines,
+		"elapsed_sec", elapsed,
+		"throughput_tps", throughput,
+		"unique_users", len(stats))
+
+	return nil
+}
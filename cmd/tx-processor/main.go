@@ -1,76 +1,177 @@
+// Command tx-processor serves the analytics API and runs the tools operators
+// need around it: ingestion, queries, exports, migrations and maintenance.
+// Commands that need settings read -config, $CONFIG_FILE and the
+// environment, and take each setting as a flag too; "tx-processor help
+// config" lists them.
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
-	"log/slog"
 	"os"
-	"os/signal"
-	"syscall"
-	rds "tx-processor/cache/redis"
+	"strings"
 	"tx-processor/config"
-	"tx-processor/db"
-	"tx-processor/handlers"
-	"tx-processor/logger"
-	"tx-processor/repository"
-	"tx-processor/server"
-	"tx-processor/services"
-
-	"github.com/redis/go-redis/v9"
 )
 
-func run() error {
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer cancel()
+// command is one of the binary's subcommands
+type command struct {
+	name     string
+	synopsis string // Arguments after the name
+	summary  string
+	run      func(args []string) error
+}
+
+var commands []command
+
+// subcommandFlags defines the flags of each command that takes a
+// subcommand, so help can list them without running it
+var subcommandFlags map[string]func(sub string) *flag.FlagSet
 
-	cfg, err := config.New()
-	if err != nil {
-		return err
+// Assigned in init, as help reads the tables it is listed in
+func init() {
+	commands = []command{
+		{"serve", "[flags]", "Serve the HTTP API, and gRPC when GRPC_ENABLED is set", serve},
+		{"ingest", "-file=FILE [-workers=N] [-batch=N] [-metrics-addr=ADDR] [flags]", "Process a file of JSON transactions into the database", ingest},
+		{"query", "user|top|anomalies [-json] [flags]", "Print a user, the leaderboard or anomalies as a table or JSON", query},
+		{"export", "[-format=csv|ndjson|parquet] [-out=FILE] [-updated-since=RFC3339] [-min-orders=N] [flags]", "Write every user matching a filter to a file", exportUsers},
+		{"migrate", "[-status] [flags]", "Bring the database schema up to this build's version", migrate},
+		{"replay", "-file=FILE [-addr=HOST:PORT] [-speed=X] [-skip=N] [flags]", "Push a file of JSON transactions to a running server over gRPC", replay},
+		{"rebuild-leaderboard", "[flags]", "Repopulate the Redis leaderboard from the database", rebuildLeaderboard},
+		{"api-key", "create|revoke -id=ID [-scopes=SCOPE,...] [-postgres] [flags]", "Issue or revoke an API key", apiKey},
+		{"config", "print [flags]", "Print the configuration commands run with, secrets redacted", printConfig},
+		{"version", "[-json]", "Print the build's version, VCS revision and Go version", version},
+		{"help", "[command]", "Describe a command", help},
 	}
+	subcommandFlags = map[string]func(sub string) *flag.FlagSet{
+		"query":   func(sub string) *flag.FlagSet { flags, _ := queryFlags(sub); return flags },
+		"api-key": func(sub string) *flag.FlagSet { flags, _ := apiKeyFlags(sub); return flags },
+		"config":  func(sub string) *flag.FlagSet { flags, _ := configFlags(sub); return flags },
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tx-processor: ")
 
-	jsonHandler := slog.NewJSONHandler(os.Stdout, nil)
-	appLogger := slog.New(jsonHandler)
-	loggerWrapper := logger.NewSlogAdapter(appLogger)
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	name, args := os.Args[1], os.Args[2:]
+	if name == "-h" || name == "-help" || name == "--help" {
+		name = "help"
+	}
 
-	database, err := db.NewPostgresDB(&cfg.DatabaseConfig)
-	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+	cmd, ok := lookup(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "tx-processor: unknown command %q\n\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
 	}
-	defer database.Close()
+	if err := cmd.run(args); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisConfig.RedisAddr,
-		Password: cfg.RedisConfig.RedisPw,
-		DB:       cfg.RedisConfig.RedisDB,
-	})
-	if err := redisClient.Ping(ctx).Err(); err != nil {
-		return fmt.Errorf("redis client: %w", err)
+func lookup(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
 	}
-	defer redisClient.Close()
+	return command{}, false
+}
 
-	analyticsCache := rds.NewRedisAnalyticsCache(redisClient)
-	analyticsRepo := repository.NewAnalyticsRepo(database)
+// usage lists the commands
+func usage(w *os.File) {
+	fmt.Fprintln(w, "Usage: tx-processor <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-20s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "tx-processor help <command>" for a command's flags, and`)
+	fmt.Fprintln(w, `"tx-processor help config" for the settings flags they all take.`)
+}
 
-	// Create analytics service
-	analyticsService := services.NewAnalyticsService(analyticsRepo, analyticsCache)
+// newFlagSet returns the flag set for the named command, with help text
+// drawn from the command table. Subcommands such as "api-key create" name
+// the set by their full path.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		cmd, _ := lookup(strings.Fields(name)[0])
+		synopsis := cmd.synopsis
+		if _, ok := subcommandFlags[cmd.name]; ok {
+			synopsis = "[flags]"
+		}
+		w := flags.Output()
+		fmt.Fprintf(w, "Usage: tx-processor %s %s\n\n%s.\n\nFlags:\n", name, synopsis, cmd.summary)
+		printFlags(w, flags)
+	}
+	return flags
+}
 
-	handler := handlers.NewHandler(analyticsService, cfg, loggerWrapper)
+// printFlags lists the flags of flags, leaving the settings flags every
+// command takes to "help config", which lists nothing else
+func printFlags(w io.Writer, flags *flag.FlagSet) {
+	own := flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+	own.SetOutput(w)
+	settings := flags.Name() == "config print"
+	skipped := false
+	flags.VisitAll(func(f *flag.Flag) {
+		if !settings && config.IsSettingFlag(f.Name) {
+			skipped = true
+			return
+		}
+		own.Var(f.Value, f.Name, f.Usage)
+	})
+	own.PrintDefaults()
+	if skipped {
+		fmt.Fprintln(w, `  Settings flags such as -db-host are taken too; "tx-processor help config" lists them.`)
+	}
+}
 
-	serverCfg := server.Config{
-		Port:   cfg.Port,
-		Logger: loggerWrapper,
+// commandHelp describes a command that takes a subcommand, with the flags of each
+func commandHelp(w io.Writer, cmd command) {
+	fmt.Fprintf(w, "Usage: tx-processor %s %s\n\n%s.\n", cmd.name, cmd.synopsis, cmd.summary)
+	for _, sub := range strings.Split(strings.Fields(cmd.synopsis)[0], "|") {
+		fmt.Fprintf(w, "\nFlags for %s %s:\n", cmd.name, sub)
+		printFlags(w, subcommandFlags[cmd.name](sub))
 	}
+}
 
-	srv := server.New(serverCfg, handler)
-	if err := srv.Start(ctx); err != nil {
-		return err
+func help(args []string) error {
+	if len(args) == 0 {
+		usage(os.Stdout)
+		return nil
+	}
+	cmd, ok := lookup(args[0])
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if cmd.name == "help" {
+		usage(os.Stdout)
+		return nil
 	}
-	return nil
+	if _, ok := subcommandFlags[cmd.name]; ok {
+		commandHelp(os.Stdout, cmd)
+		return nil
+	}
+	// Each command's -help prints its flags and exits
+	return cmd.run([]string{"-help"})
 }
 
-func main() {
-	if err := run(); err != nil {
-		log.Fatal(err)
+// usageError reports a missing or unknown subcommand of the named command,
+// showing its help instead when args ask for it
+func usageError(name string, args []string) error {
+	cmd, _ := lookup(name)
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		commandHelp(os.Stdout, cmd)
+		return nil
 	}
+	return fmt.Errorf("usage: tx-processor %s %s", cmd.name, cmd.synopsis)
 }
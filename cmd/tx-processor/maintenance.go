@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+	"tx-processor/auth"
+	rds "tx-processor/cache/redis"
+	"tx-processor/config"
+	"tx-processor/db"
+	logging "tx-processor/logger"
+	"tx-processor/repository"
+	"tx-processor/services"
+)
+
+func rebuildLeaderboard(args []string) error {
+	flags := newFlagSet("rebuild-leaderboard")
+	loader := config.RegisterFlags(flags)
+	flags.Parse(args)
+
+	cfg, err := loader.Load()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	logger, err := logging.New(os.Stdout, cfg.LogConfig.Options())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	dbConn, err := db.NewPostgresDB(&cfg.DatabaseConfig)
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer dbConn.Close()
+
+	redisClient, err := rds.NewClient(ctx, &cfg.RedisConfig)
+	if err != nil {
+		return fmt.Errorf("redis connect: %w", err)
+	}
+	defer redisClient.Close()
+
+	service := services.NewAnalyticsService(
+		repository.NewAnalyticsRepo(dbConn, logger),
+		rds.NewRedisAnalyticsCache(redisClient, &cfg.RedisConfig),
+		services.WithLeaderboard(rds.NewRedisLeaderboard(redisClient)),
+	)
+	defer service.Close()
+
+	start := time.Now()
+	count, err := service.RebuildLeaderboard(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Leaderboard rebuilt",
+		"users", count,
+		"elapsed_sec", time.Since(start).Seconds())
+	return nil
+}
+
+// apiKeyOptions holds the flags of an api-key subcommand
+type apiKeyOptions struct {
+	id       string
+	scopes   string
+	postgres bool
+	loader   *config.Loader
+}
+
+// apiKeyFlags defines the flags of "api-key action"
+func apiKeyFlags(action string) (*flag.FlagSet, *apiKeyOptions) {
+	flags := newFlagSet("api-key " + action)
+	opts := &apiKeyOptions{}
+	flags.StringVar(&opts.id, "id", "", "Key ID, logged as the caller (required)")
+	if action == "create" {
+		flags.StringVar(&opts.scopes, "scopes", "", "Comma-separated scopes: read:analytics, read:anomalies, write:transactions, admin (required)")
+		flags.BoolVar(&opts.postgres, "postgres", false, "Store the new key in the api_keys table")
+	}
+	opts.loader = config.RegisterFlags(flags)
+	return flags, opts
+}
+
+// apiKey issues and revokes API keys. A new key is printed once and only its
+// hash is kept: in the api_keys table with -postgres, otherwise as an entry
+// to add to the key file.
+func apiKey(args []string) error {
+	if len(args) == 0 || (args[0] != "create" && args[0] != "revoke") {
+		return usageError("api-key", args)
+	}
+	flags, opts := apiKeyFlags(args[0])
+	flags.Parse(args[1:])
+
+	if opts.id == "" {
+		return fmt.Errorf("-id is required")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if args[0] == "revoke" {
+		// Keys in the key file are revoked by marking them disabled there
+		store, closeDB, err := postgresKeyStore(opts.loader)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+		return store.RevokeKey(ctx, opts.id)
+	}
+
+	var scopes []auth.Scope
+	for _, name := range strings.Split(opts.scopes, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		scope := auth.Scope(name)
+		if !scope.Valid() {
+			return fmt.Errorf("unknown scope %q", name)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return fmt.Errorf("-scopes is required")
+	}
+
+	key, err := auth.GenerateKey()
+	if err != nil {
+		return err
+	}
+	hash := auth.HashKey(key)
+
+	if opts.postgres {
+		store, closeDB, err := postgresKeyStore(opts.loader)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+		if err := store.CreateKey(ctx, opts.id, hash, scopes); err != nil {
+			return err
+		}
+	} else {
+		entry, err := json.Marshal(struct {
+			ID     string       `json:"id"`
+			Hash   string       `json:"hash"`
+			Scopes []auth.Scope `json:"scopes"`
+		}{opts.id, hash, scopes})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Add this entry to the API key file's keys list:\n%s\n", entry)
+	}
+
+	// The key itself goes alone to stdout so it can be captured by a script
+	fmt.Println(key)
+	return nil
+}
+
+func postgresKeyStore(loader *config.Loader) (*auth.PostgresKeyStore, func(), error) {
+	cfg, err := loader.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("config: %w", err)
+	}
+	dbConn, err := db.NewPostgresDB(&cfg.DatabaseConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("db connect: %w", err)
+	}
+	return auth.NewPostgresKeyStore(dbConn, 0), func() { dbConn.Close() }, nil
+}
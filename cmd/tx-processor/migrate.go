@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"tx-processor/config"
+	"tx-processor/db"
+)
+
+// migrate applies pending migrations. The server and the other commands
+// migrate on connecting too; running this first lets a deploy fail on a bad
+// migration before any new process starts.
+func migrate(args []string) error {
+	flags := newFlagSet("migrate")
+	status := flags.Bool("status", false, "Print the schema version without migrating")
+	loader := config.RegisterFlags(flags)
+	flags.Parse(args)
+
+	cfg, err := loader.Load()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	dbConn, err := db.Open(&cfg.DatabaseConfig)
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer dbConn.Close()
+
+	from, err := db.CurrentVersion(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+	switch {
+	case from > db.SchemaVersion:
+		// An older build must not run against a schema it doesn't know
+		return fmt.Errorf("schema is at version %d, newer than this build's %d", from, db.SchemaVersion)
+	case *status || from == db.SchemaVersion:
+		fmt.Printf("schema at version %d of %d\n", from, db.SchemaVersion)
+		return nil
+	}
+
+	if err := db.Migrate(ctx, dbConn); err != nil {
+		return err
+	}
+	to, err := db.CurrentVersion(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema migrated from version %d to %d\n", from, to)
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"tx-processor/cache/memory"
+	"tx-processor/config"
+	"tx-processor/db"
+	logging "tx-processor/logger"
+	"tx-processor/models"
+	"tx-processor/repository"
+	"tx-processor/services"
+)
+
+// queryOptions holds the flags of a query subcommand
+type queryOptions struct {
+	asJSON   bool
+	userID   string
+	sort     string
+	limit    int
+	velocity bool
+	window   time.Duration
+	loader   *config.Loader
+}
+
+// queryFlags defines the flags of "query what"
+func queryFlags(what string) (*flag.FlagSet, *queryOptions) {
+	flags := newFlagSet("query " + what)
+	opts := &queryOptions{}
+	flags.BoolVar(&opts.asJSON, "json", false, "Print JSON instead of a table")
+	switch what {
+	case "user":
+		flags.StringVar(&opts.userID, "id", "", "User ID (required)")
+	case "top":
+		flags.StringVar(&opts.sort, "sort", string(models.SortBySpend), "Sort by orders, spend, avg_order_value or last_updated")
+		flags.IntVar(&opts.limit, "limit", 10, "Users to list")
+	case "anomalies":
+		flags.BoolVar(&opts.velocity, "velocity", false, "List users whose activity burst past the velocity rule, rather than lifetime outliers")
+		flags.DurationVar(&opts.window, "window", 0, "Velocity window, as -anomaly-velocity-window")
+	}
+	opts.loader = config.RegisterFlags(flags)
+	return flags, opts
+}
+
+// query prints what the API would serve, read straight from the database so
+// it works with the server down
+func query(args []string) error {
+	if len(args) == 0 || (args[0] != "user" && args[0] != "top" && args[0] != "anomalies") {
+		return usageError("query", args)
+	}
+	what := args[0]
+	flags, opts := queryFlags(what)
+	flags.Parse(args[1:])
+
+	switch {
+	case what == "user" && opts.userID == "":
+		return fmt.Errorf("-id is required")
+	case what == "top" && !models.SortKey(opts.sort).Valid():
+		return fmt.Errorf("unknown sort %q", opts.sort)
+	case what == "top" && opts.limit <= 0:
+		return fmt.Errorf("-limit must be positive, got %d", opts.limit)
+	}
+	if opts.window > 0 {
+		if err := flags.Set("anomaly-velocity-window", opts.window.String()); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := opts.loader.Load()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	// Logs go to stderr so they can't corrupt the output
+	logger, err := logging.New(os.Stderr, cfg.LogConfig.Options())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	dbConn, err := db.NewPostgresDB(&cfg.DatabaseConfig)
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer dbConn.Close()
+
+	// A one-shot command has nothing to gain from caching
+	service := services.NewAnalyticsService(
+		repository.NewAnalyticsRepo(dbConn, logger),
+		memory.NewMemoryAnalyticsCache(1, time.Second),
+		services.WithCacheFills(0, 0),
+	)
+	defer service.Close()
+
+	var (
+		data  any
+		table func(w io.Writer)
+	)
+	switch {
+	case what == "user":
+		profile, err := service.GetUserProfile(ctx, opts.userID)
+		if errors.Is(err, services.ErrUserNotFound) {
+			return fmt.Errorf("user %s has no analytics", opts.userID)
+		}
+		if err != nil {
+			return err
+		}
+		data, table = profile, func(w io.Writer) { profileTable(w, profile) }
+	case what == "top":
+		page, err := service.GetTopUsers(ctx, models.UserQuery{Sort: models.SortKey(opts.sort), Limit: opts.limit})
+		if err != nil {
+			return err
+		}
+		data, table = page.Users, func(w io.Writer) { topTable(w, page.Users) }
+	case opts.velocity:
+		anomalies, err := service.DetectVelocityAnomalies(ctx, cfg.AnomalyConfig.VelocityRule())
+		if err != nil {
+			return err
+		}
+		data, table = anomalies, func(w io.Writer) { velocityTable(w, anomalies) }
+	default:
+		anomalies, err := service.DetectAnomalies(ctx)
+		if err != nil {
+			return err
+		}
+		data, table = anomalies, func(w io.Writer) { anomaliesTable(w, anomalies) }
+	}
+
+	if opts.asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func profileTable(w io.Writer, p *models.UserProfile) {
+	fmt.Fprintf(w, "USER\t%s\n", p.UserID)
+	fmt.Fprintf(w, "ORDERS\t%d\n", p.TotalOrders)
+	fmt.Fprintf(w, "SPENT\t%s\n", money(p.TotalSpent))
+	fmt.Fprintf(w, "AVG ORDER\t%s\n", money(p.AvgOrderValue))
+	fmt.Fprintf(w, "FIRST ORDER\t%s\n", timestamp(p.FirstOrderAt))
+	fmt.Fprintf(w, "LAST ORDER\t%s\n", timestamp(p.LastOrderAt))
+	fmt.Fprintf(w, "RANK\torders %d, spend %d of %d\n", p.Rank.Orders, p.Rank.Spend, p.Population)
+	fmt.Fprintf(w, "PERCENTILE\torders %.1f, spend %.1f\n", p.Percentile.Orders, p.Percentile.Spend)
+	fmt.Fprintf(w, "ANOMALY\t%s\n", anomalyFlags(p.OrderAnomaly, p.SpendingAnomaly))
+}
+
+func topTable(w io.Writer, users []models.UserSummary) {
+	fmt.Fprintln(w, "#\tUSER\tORDERS\tSPENT\tAVG ORDER")
+	for i, u := range users {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\n", i+1, u.UserID, u.TotalOrders, money(u.TotalSpent), money(u.AvgOrderValue))
+	}
+}
+
+func anomaliesTable(w io.Writer, anomalies []models.AnomalyUser) {
+	fmt.Fprintln(w, "USER\tORDERS\tSPENT\tANOMALY")
+	for _, a := range anomalies {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", a.UserID, a.TotalOrders, money(a.TotalSpent), anomalyFlags(a.OrderAnomaly, a.SpendingAnomaly))
+	}
+}
+
+func velocityTable(w io.Writer, anomalies []models.VelocityAnomaly) {
+	fmt.Fprintln(w, "USER\tWINDOW END\tORDERS\tSPENT\tANOMALY")
+	for _, a := range anomalies {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", a.UserID, timestamp(&a.WindowEnd), a.Orders, money(a.Spent), anomalyFlags(a.OrderAnomaly, a.SpendingAnomaly))
+	}
+}
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func timestamp(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func anomalyFlags(orders, spending bool) string {
+	switch {
+	case orders && spending:
+		return "orders, spending"
+	case orders:
+		return "orders"
+	case spending:
+		return "spending"
+	default:
+		return "-"
+	}
+}
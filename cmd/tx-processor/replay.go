@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+	analyticsv1 "tx-processor/api/analytics/v1"
+	"tx-processor/config"
+	logging "tx-processor/logger"
+	"tx-processor/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// replay pushes a transaction file to a running server, which commits it as
+// it would the ingest command's batches. Paced by the transactions' timestamps
+// it reproduces recorded traffic; unpaced it backfills. A server that stops
+// part way reports how much it accepted, and -skip resumes from there.
+func replay(args []string) error {
+	flags := newFlagSet("replay")
+	filePath := flags.String("file", "", "Path to the JSON file (required)")
+	addr := flags.String("addr", "localhost:9090", "Server's gRPC address")
+	apiKey := flags.String("api-key", os.Getenv("API_KEY"), "API key with the write:transactions scope, when the server requires one; defaults to $API_KEY")
+	speed := flags.Float64("speed", 0, "Replay at this multiple of the recorded pace, e.g. 1 for real time; 0 sends as fast as the server accepts")
+	skip := flags.Int("skip", 0, "Skip this many transactions, to resume an interrupted replay")
+	loader := config.RegisterFlags(flags)
+	flags.Parse(args)
+
+	if *filePath == "" {
+		return fmt.Errorf("-file is required")
+	}
+	if *speed < 0 {
+		return fmt.Errorf("-speed must not be negative, got %g", *speed)
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	logger, err := logging.New(os.Stdout, cfg.LogConfig.Options())
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(*filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("grpc client: %w", err)
+	}
+	defer conn.Close()
+
+	// An interrupt stops sending, but the stream stays open so the server
+	// commits what it has and reports how much that was
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	streamCtx := context.Background()
+	if *apiKey != "" {
+		streamCtx = metadata.AppendToOutgoingContext(streamCtx, "x-api-key", *apiKey)
+	}
+	stream, err := analyticsv1.NewAnalyticsServiceClient(conn).PushTransactions(streamCtx)
+	if err != nil {
+		return fmt.Errorf("push transactions: %w", err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 10*1024*1024)
+
+	start := time.Now()
+	var (
+		skipped, sent, invalid int
+		prev                   time.Time // Latest timestamp sent, for pacing
+	)
+Send:
+	for scanner.Scan() && ctx.Err() == nil {
+		var tx models.Transaction
+		if err := json.Unmarshal(scanner.Bytes(), &tx); err != nil {
+			logger.Warn("Skipping invalid JSON", "error", err)
+			invalid++
+			continue
+		}
+		if skipped < *skip {
+			skipped++
+			continue
+		}
+
+		if *speed > 0 && tx.Timestamp.After(prev) {
+			if !prev.IsZero() {
+				select {
+				case <-ctx.Done():
+					break Send
+				case <-time.After(time.Duration(float64(tx.Timestamp.Sub(prev)) / *speed)):
+				}
+			}
+			prev = tx.Timestamp
+		}
+
+		if err := stream.Send(transactionProto(tx)); err != nil {
+			// The server has ended the stream; its reason comes with the response
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("send transaction %d: %w", *skip+sent, err)
+		}
+		sent++
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Error("failed to read file", "error", err)
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("push after %d transactions sent: %w", sent, err)
+	}
+
+	elapsed := time.Since(start).Seconds()
+	logger.Info("Replay complete",
+		"sent", sent,
+		"accepted", resp.GetAccepted(),
+		"batches", resp.GetBatches(),
+		"invalid", invalid,
+		"elapsed_sec", elapsed,
+		"throughput_tps", float64(resp.GetAccepted())/elapsed)
+
+	if resp.GetAccepted() < int64(sent) || ctx.Err() != nil {
+		return fmt.Errorf("replay stopped early; resume with -skip=%d", int64(*skip)+resp.GetAccepted())
+	}
+	return nil
+}
+
+func transactionProto(tx models.Transaction) *analyticsv1.Transaction {
+	out := &analyticsv1.Transaction{
+		OrderId:   tx.OrderID,
+		UserId:    tx.UserID,
+		ProductId: tx.ProductID,
+		Quantity:  int64(tx.Quantity),
+		Price:     tx.Price,
+	}
+	if !tx.Timestamp.IsZero() {
+		out.Timestamp = timestamppb.New(tx.Timestamp)
+	}
+	return out
+}
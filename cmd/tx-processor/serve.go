@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"tx-processor/auth"
+	"tx-processor/cache"
+	"tx-processor/cache/instrumented"
+	"tx-processor/cache/memory"
+	rds "tx-processor/cache/redis"
+	"tx-processor/cache/tiered"
+	"tx-processor/config"
+	"tx-processor/db"
+	"tx-processor/events"
+	"tx-processor/handlers"
+	"tx-processor/health"
+	"tx-processor/jobs"
+	"tx-processor/lifecycle"
+	"tx-processor/logger"
+	"tx-processor/metrics"
+	"tx-processor/middleware"
+	"tx-processor/processor"
+	"tx-processor/ratelimit"
+	"tx-processor/repository"
+	"tx-processor/rpc"
+	"tx-processor/server"
+	"tx-processor/services"
+	"tx-processor/tracing"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+)
+
+// serve runs the HTTP API, and gRPC when enabled, until SIGINT or SIGTERM
+// starts a graceful shutdown
+func serve(args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	// Once shutdown begins, a second signal kills the process
+	context.AfterFunc(ctx, cancel)
+
+	flags := newFlagSet("serve")
+	loader := config.RegisterFlags(flags)
+	flags.Parse(args)
+	cfg, err := loader.Load()
+	if err != nil {
+		return err
+	}
+
+	loggerWrapper, err := logger.New(os.Stdout, cfg.LogConfig.Options())
+	if err != nil {
+		return err
+	}
+
+	// Components stop in the reverse of the order they are added: the server
+	// drains first, then what it depends on, and traces are flushed last
+	lc := lifecycle.NewManager(loggerWrapper, cfg.ShutdownTimeout)
+
+	// Reloadable settings change on SIGHUP or through the admin API; the
+	// components below subscribe to those they hold
+	store := config.NewStore(loader, cfg, loggerWrapper)
+	store.Subscribe(func(c *config.Config) { loggerWrapper.Reconfigure(c.LogConfig.Options()) })
+	lc.Go("settings reload", func(ctx context.Context) error {
+		return store.ReloadOnSignal(ctx, syscall.SIGHUP)
+	})
+
+	shutdownTracing, err := tracing.Setup(ctx, &cfg.TracingConfig)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	lc.Add("tracing", nil, shutdownTracing)
+
+	database, err := db.NewPostgresDB(&cfg.DatabaseConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	lc.AddCloser("postgres", database.Close)
+
+	healthRegistry := health.NewRegistry(cfg.HealthTimeout)
+	healthRegistry.Register("postgres", health.Postgres(database))
+
+	registry := metrics.NewRegistry()
+
+	localCache := memory.NewMemoryAnalyticsCache(cfg.CacheConfig.LocalSize, cfg.CacheConfig.LocalTTL)
+	cacheMetrics := instrumented.NewMetrics(registry)
+	localStats := cacheMetrics.Tier("local")
+	cacheStats := []*instrumented.Stats{localStats}
+
+	negativeCache := memory.NewMemoryAnalyticsCache(cfg.CacheConfig.LocalSize, cfg.CacheConfig.NegativeTTL)
+	store.Subscribe(func(c *config.Config) {
+		localCache.SetTTL(c.CacheConfig.LocalTTL)
+		negativeCache.SetTTL(c.CacheConfig.NegativeTTL)
+	})
+
+	// Small deployments run on the in-process tier alone
+	var analyticsCache cache.AnalyticsCache = instrumented.NewInstrumentedAnalyticsCache(localCache, localStats)
+	serviceOpts := []services.Option{
+		services.WithNegativeCache(negativeCache),
+		services.WithLocalCache(localCache),
+		services.WithCacheFills(cfg.CacheConfig.FillWorkers, cfg.CacheConfig.FillQueue),
+	}
+	var (
+		redisClient *redis.Client
+		redisCache  *rds.RedisAnalyticsCache
+	)
+	if cfg.RedisConfig.RedisEnabled {
+		redisClient, err = rds.NewClient(ctx, &cfg.RedisConfig)
+		if err != nil {
+			return fmt.Errorf("redis client: %w", err)
+		}
+		lc.AddCloser("redis", redisClient.Close)
+
+		healthRegistry.Register("redis", health.Redis(redisClient))
+
+		redisCache = rds.NewRedisAnalyticsCache(redisClient, &cfg.RedisConfig)
+		store.Subscribe(func(c *config.Config) { redisCache.SetTTL(c.RedisConfig.CacheTTL) })
+		redisStats := cacheMetrics.Tier("redis")
+		cacheStats = append(cacheStats, redisStats)
+		analyticsCache = tiered.NewTieredAnalyticsCache(analyticsCache, instrumented.NewInstrumentedAnalyticsCache(redisCache, redisStats))
+		serviceOpts = append(serviceOpts, services.WithLeaderboard(rds.NewRedisLeaderboard(redisClient)))
+	}
+	loggerWrapper.Info("analytics cache configured", "redis_enabled", cfg.RedisConfig.RedisEnabled)
+
+	// Live updates reach this process through Redis when the processor runs elsewhere
+	broker := events.NewBroker(cfg.EventsConfig.Buffer)
+	lc.AddCloser("events broker", func() error {
+		broker.Close()
+		return nil
+	})
+	if redisClient != nil {
+		lc.Go("event relay", func(ctx context.Context) error {
+			return events.Relay(ctx, redisClient, events.DefaultChannel, broker)
+		})
+	}
+
+	analyticsRepo := repository.NewAnalyticsRepo(database, loggerWrapper)
+
+	// Create analytics service
+	analyticsService := services.NewAnalyticsService(analyticsRepo, analyticsCache, serviceOpts...)
+	lc.AddCloser("analytics service", func() error {
+		analyticsService.Close()
+		return nil
+	})
+
+	if redisCache != nil {
+		// Drop local copies when the processor or another replica invalidates users
+		lc.Go("cache invalidations", func(ctx context.Context) error {
+			return redisCache.SubscribeInvalidations(ctx, func(inv cache.Invalidation) {
+				analyticsService.EvictLocal(ctx, inv)
+			})
+		})
+	}
+
+	registry.MustRegister(metrics.NewCacheFillCollector(analyticsService))
+	registry.MustRegister(metrics.NewEventsCollector(broker))
+
+	lc.Go("leaderboard watcher", func(ctx context.Context) error {
+		events.WatchLeaderboard(ctx, broker, analyticsService,
+			cfg.EventsConfig.LeaderboardSize, cfg.EventsConfig.LeaderboardInterval, loggerWrapper)
+		return nil
+	})
+
+	// Pushed transactions and admin file ingestion take the same path as the
+	// ingest command's, so caches, leaderboard and live updates stay in step
+	procOpts := []processor.Option{
+		processor.WithInvalidator(analyticsService),
+		processor.WithMetrics(metrics.NewIngestMetrics(registry)),
+		processor.WithPublisher(broker),
+	}
+	if redisClient != nil {
+		procOpts = append(procOpts,
+			processor.WithLeaderboard(rds.NewRedisLeaderboard(redisClient)),
+			// The relay delivers to this process's broker too
+			processor.WithPublisher(events.NewRedisPublisher(redisClient)),
+		)
+	}
+	proc := processor.NewProcessor(cfg, loggerWrapper, analyticsRepo, procOpts...)
+	metrics.RegisterQueueDepth(registry, proc.QueueDepth)
+	healthRegistry.Register("processor", proc)
+	store.Subscribe(func(c *config.Config) { proc.SetBatchSize(c.IngestConfig.BatchSize) })
+
+	// Admin jobs are cancelled once the server has drained, and their
+	// queued batches commit before the database closes
+	jobManager := jobs.NewManager(loggerWrapper)
+	lc.Add("admin jobs", nil, jobManager.Shutdown)
+
+	handlerOpts := []handlers.Option{
+		handlers.WithCacheStats(cacheStats...),
+		handlers.WithHealth(healthRegistry),
+		handlers.WithEvents(broker),
+		handlers.WithSettings(store),
+		handlers.WithJobs(jobManager),
+		handlers.WithIngester(proc),
+	}
+	var limiter ratelimit.Limiter
+	if cfg.RateLimitConfig.Enabled {
+		limiter, err = ratelimit.Setup(&cfg.RateLimitConfig, redisClient)
+		if err != nil {
+			return fmt.Errorf("failed to set up rate limiting: %w", err)
+		}
+		handlerOpts = append(handlerOpts, handlers.WithRateLimiter(limiter))
+		store.Subscribe(func(c *config.Config) { limiter.SetPolicy(ratelimit.NewPolicy(&c.RateLimitConfig)) })
+	}
+	loggerWrapper.Info("rate limiting configured", "enabled", cfg.RateLimitConfig.Enabled, "backend", cfg.RateLimitConfig.Backend)
+
+	handler := handlers.NewHandler(analyticsService, cfg, loggerWrapper, handlerOpts...)
+
+	// Outermost first: every log line carries the request ID, and panics
+	// become 500s before tracing and metrics record the status
+	stack := []func(http.Handler) http.Handler{
+		middleware.RequestID,
+		middleware.AccessLog(loggerWrapper),
+		tracing.Middleware,
+		metrics.NewHTTPMetrics(registry).Middleware,
+		middleware.Recover(loggerWrapper),
+	}
+	var (
+		unaryInterceptors  []grpc.UnaryServerInterceptor
+		streamInterceptors []grpc.StreamServerInterceptor
+	)
+	if cfg.AuthConfig.Enabled {
+		authenticator, err := auth.Setup(&cfg.AuthConfig, database)
+		if err != nil {
+			return fmt.Errorf("failed to set up authentication: %w", err)
+		}
+		stack = append(stack, auth.Middleware(authenticator, loggerWrapper))
+		unary, stream := rpc.AuthInterceptors(authenticator, loggerWrapper)
+		unaryInterceptors = append(unaryInterceptors, unary)
+		streamInterceptors = append(streamInterceptors, stream)
+	}
+	loggerWrapper.Info("authentication configured", "enabled", cfg.AuthConfig.Enabled)
+	if limiter != nil {
+		// After authentication, so callers are metered by identity
+		unary, stream := rpc.RateLimitInterceptors(limiter, loggerWrapper)
+		unaryInterceptors = append(unaryInterceptors, unary)
+		streamInterceptors = append(streamInterceptors, stream)
+	}
+
+	stack = append(stack, middleware.MaxBodySize(cfg.HTTPConfig.MaxBodyBytes))
+	if cfg.HTTPConfig.Compression {
+		stack = append(stack, middleware.Gzip)
+	}
+
+	serverCfg := server.Config{
+		Port:              cfg.Port,
+		Logger:            loggerWrapper,
+		Metrics:           metrics.Handler(registry),
+		Middleware:        stack,
+		Health:            healthRegistry,
+		DrainDelay:        cfg.DrainDelay,
+		OnShutdown:        []func(){broker.Close},
+		OnError:           lc.Fail,
+		ReadHeaderTimeout: cfg.HTTPConfig.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPConfig.ReadTimeout,
+		WriteTimeout:      cfg.HTTPConfig.WriteTimeout,
+		IdleTimeout:       cfg.HTTPConfig.IdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPConfig.MaxHeaderBytes,
+	}
+
+	if cfg.GRPCConfig.Enabled {
+		grpcServer := grpc.NewServer(
+			grpc.StatsHandler(otelgrpc.NewServerHandler()),
+			grpc.ChainUnaryInterceptor(unaryInterceptors...),
+			grpc.ChainStreamInterceptor(streamInterceptors...),
+		)
+		analyticsServer := rpc.NewAnalyticsServer(analyticsService, cfg, loggerWrapper,
+			rpc.WithEvents(broker),
+			rpc.WithIngester(proc),
+			rpc.WithSettings(store),
+		)
+		analyticsServer.Register(grpcServer)
+		// Pushes commit what they have before the server stops
+		serverCfg.OnShutdown = append(serverCfg.OnShutdown, analyticsServer.Drain)
+
+		serverCfg.GRPC = grpcServer
+		serverCfg.GRPCAddr = cfg.GRPCConfig.Addr
+	}
+
+	srv := server.New(serverCfg, handler)
+	lc.Add("server", srv.Start, srv.Shutdown)
+
+	return lc.Run(ctx)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"tx-processor/buildinfo"
+)
+
+func version(args []string) error {
+	flags := newFlagSet("version")
+	asJSON := flags.Bool("json", false, "Print JSON instead of text")
+	flags.Parse(args)
+
+	info := buildinfo.Get()
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(info)
+	}
+
+	fmt.Printf("tx-processor %s %s\n", info.Version, info.GoVersion)
+	if info.Revision != "" {
+		modified := ""
+		if info.Modified {
+			modified = " (modified)"
+		}
+		fmt.Printf("revision %s%s, committed %s\n", info.Revision, modified, info.CommitTime)
+	}
+	return nil
+}
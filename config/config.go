@@ -2,42 +2,186 @@ package config
 
 import (
 	"fmt"
-
-	"github.com/caarlos0/env/v11"
+	"log/slog"
+	"time"
+	"tx-processor/logger"
+	"tx-processor/models"
 )
 
+// Config is the whole service's configuration. Each setting is named by its
+// environment variable; Load also reads them from a file and from flags.
+// Settings tagged secret are redacted when the configuration is printed, and
+// those tagged reload can change while running, through a Store.
 type Config struct {
-	Port           string         `env:"PORT" envDefault:":8080"`
-	RedisConfig    RedisConfig    `envPrefix:"REDIS_"`
-	DatabaseConfig DatabaseConfig `envPrefix:"DB_"`
+	Port            string          `env:"PORT" envDefault:"8080"`
+	DrainDelay      time.Duration   `env:"DRAIN_DELAY" envDefault:"5s"`       // Readiness fails this long before the server stops accepting requests
+	ShutdownTimeout time.Duration   `env:"SHUTDOWN_TIMEOUT" envDefault:"25s"` // Bounds the whole shutdown, drain delay included; keep it under the pod's grace period
+	HealthTimeout   time.Duration   `env:"HEALTH_TIMEOUT" envDefault:"2s"`    // Per dependency check
+	LogConfig       LogConfig       `envPrefix:"LOG_"`
+	HTTPConfig      HTTPConfig      `envPrefix:"HTTP_"`
+	RedisConfig     RedisConfig     `envPrefix:"REDIS_"`
+	DatabaseConfig  DatabaseConfig  `envPrefix:"DB_"`
+	AnomalyConfig   AnomalyConfig   `envPrefix:"ANOMALY_"`
+	CacheConfig     CacheConfig     `envPrefix:"CACHE_"`
+	TracingConfig   TracingConfig   `envPrefix:"TRACING_"`
+	EventsConfig    EventsConfig    `envPrefix:"EVENTS_"`
+	GRPCConfig      GRPCConfig      `envPrefix:"GRPC_"`
+	AuthConfig      AuthConfig      `envPrefix:"AUTH_"`
+	RateLimitConfig RateLimitConfig `envPrefix:"RATE_LIMIT_"`
+	IngestConfig    IngestConfig    `envPrefix:"INGEST_"`
+	RetentionConfig RetentionConfig `envPrefix:"RETENTION_"`
+}
+
+// LogConfig shapes log output. Sampling keeps a flood of one warning, such as
+// a bad file's invalid lines, to SampleBurst lines per SampleInterval.
+type LogConfig struct {
+	Level          string        `env:"LEVEL" envDefault:"info" reload:"true"` // debug, info, warn or error
+	Format         string        `env:"FORMAT" envDefault:"json"`              // json or text
+	SampleInterval time.Duration `env:"SAMPLE_INTERVAL" envDefault:"1s" reload:"true"`
+	SampleBurst    int           `env:"SAMPLE_BURST" envDefault:"10" reload:"true"`
+	Redact         []string      `env:"REDACT" envDefault:"password,secret,token,authorization,api_key,user_id"` // Attribute keys whose values are never logged
+}
+
+// HTTPConfig bounds how long the server waits on clients and on each route
+type HTTPConfig struct {
+	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT" envDefault:"5s"` // Cuts off clients that trickle their headers
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT" envDefault:"30s"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT" envDefault:"60s"` // Exports and event streams lift it for themselves
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT" envDefault:"2m"`
+	MaxHeaderBytes    int           `env:"MAX_HEADER_BYTES" envDefault:"65536"`
+	MaxBodyBytes      int64         `env:"MAX_BODY_BYTES" envDefault:"1048576"`
+	RequestTimeout    time.Duration `env:"REQUEST_TIMEOUT" envDefault:"10s"` // Per request, for routes that look up a few users
+	ScanTimeout       time.Duration `env:"SCAN_TIMEOUT" envDefault:"60s"`    // Per request, for anomaly scans and batch profiles
+	Compression       bool          `env:"COMPRESSION" envDefault:"true"`    // Gzip JSON, CSV and NDJSON for clients that accept it
 }
 
 type RedisConfig struct {
-	RedisEnabled bool   `env:"ENABLED" envDefault:"false"`
-	RedisAddr    string `env:"ADDR" envDefault:"localhost:6379"`
-	RedisPw      string `env:"PASSWORD" envDefault:""`
-	RedisDB      int    `env:"DB" envDefault:"0"`
+	RedisEnabled bool          `env:"ENABLED" envDefault:"false"`
+	RedisAddr    string        `env:"ADDR" envDefault:"localhost:6379"`
+	RedisPw      string        `env:"PASSWORD" envDefault:"" secret:"true"`
+	RedisDB      int           `env:"DB" envDefault:"0"`
+	CacheTTL     time.Duration `env:"CACHE_TTL" envDefault:"5h" reload:"true"` // How long cached analytics live in Redis
+	CachePrefix  string        `env:"CACHE_PREFIX" envDefault:"analytics:"`    // Cached analytics are keyed <prefix>:<user ID>
 }
 
 type DatabaseConfig struct {
-	Host     string `env:"HOST" envDefault:"localhost"`
-	Port     string `env:"PORT" envDefault:"5432"`
-	User     string `env:"USER" envDefault:"postgres"`
-	Password string `env:"PASSWORD" envDefault:"postgres"`
-	DBName   string `env:"NAME" envDefault:"ecommerce"`
-	SSLMode  string `env:"SSLMODE" envDefault:"disable"`
+	Host            string        `env:"HOST" envDefault:"localhost"`
+	Port            string        `env:"PORT" envDefault:"5432"`
+	User            string        `env:"USER" envDefault:"postgres"`
+	Password        string        `env:"PASSWORD" envDefault:"postgres" secret:"true"`
+	DBName          string        `env:"NAME" envDefault:"ecommerce"`
+	SSLMode         string        `env:"SSLMODE" envDefault:"disable"`
+	MaxOpenConns    int           `env:"MAX_OPEN_CONNS" envDefault:"25"`
+	MaxIdleConns    int           `env:"MAX_IDLE_CONNS" envDefault:"5"`
+	ConnMaxLifetime time.Duration `env:"CONN_MAX_LIFETIME" envDefault:"0"` // Zero keeps connections until they fail
 }
 
-func (d *DatabaseConfig) ConnectionString() string {
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		d.Host, d.Port, d.User, d.Password, d.DBName, d.SSLMode)
+// CacheConfig sizes the in-process cache tier, which runs with or without Redis
+type CacheConfig struct {
+	LocalSize   int           `env:"LOCAL_SIZE" envDefault:"10000"`
+	LocalTTL    time.Duration `env:"LOCAL_TTL" envDefault:"30s" reload:"true"`
+	NegativeTTL time.Duration `env:"NEGATIVE_TTL" envDefault:"30s" reload:"true"` // How long unknown users stay cached
+	FillWorkers int           `env:"FILL_WORKERS" envDefault:"4"`
+	FillQueue   int           `env:"FILL_QUEUE" envDefault:"1024"`
 }
 
-func New() (*Config, error) {
-	var cfg Config
-	cfg, err := env.ParseAs[Config]()
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+// TracingConfig selects where spans are exported. Exporter is one of none,
+// stdout (the stdout exporter, writing to File when set and stderr otherwise)
+// or otlp (HTTP, to Endpoint).
+type TracingConfig struct {
+	Exporter    string  `env:"EXPORTER" envDefault:"none"`
+	Endpoint    string  `env:"ENDPOINT" envDefault:"localhost:4318"`
+	Insecure    bool    `env:"INSECURE" envDefault:"true"`
+	File        string  `env:"FILE" envDefault:""`
+	SampleRatio float64 `env:"SAMPLE_RATIO" envDefault:"1"`
+	ServiceName string  `env:"SERVICE_NAME" envDefault:"tx-processor"`
+}
+
+// EventsConfig tunes live update streams
+type EventsConfig struct {
+	Buffer              int           `env:"BUFFER" envDefault:"256"`              // Events held per subscriber before newer ones are dropped
+	Heartbeat           time.Duration `env:"HEARTBEAT" envDefault:"15s"`           // Keeps idle streams open through proxies
+	WriteTimeout        time.Duration `env:"WRITE_TIMEOUT" envDefault:"30s"`       // A client stuck this long on one write is disconnected
+	LeaderboardSize     int           `env:"LEADERBOARD_SIZE" envDefault:"100"`    // Users per leaderboard event
+	LeaderboardInterval time.Duration `env:"LEADERBOARD_INTERVAL" envDefault:"1s"` // Minimum time between leaderboard reads
+}
+
+// GRPCConfig controls the gRPC API, served on its own address beside HTTP
+type GRPCConfig struct {
+	Enabled         bool   `env:"ENABLED" envDefault:"false"`
+	Addr            string `env:"ADDR" envDefault:":9090"`
+	IngestBatchSize int    `env:"INGEST_BATCH_SIZE" envDefault:"500" reload:"true"` // Pushed transactions committed together
+}
+
+// AuthConfig selects where API keys and JWT verification keys come from.
+// With auth enabled at least one source is required.
+type AuthConfig struct {
+	Enabled      bool          `env:"ENABLED" envDefault:"false"`
+	KeysFile     string        `env:"KEYS_FILE" envDefault:""`          // JSON file of hashed API keys
+	KeysPostgres bool          `env:"KEYS_POSTGRES" envDefault:"false"` // Also accept keys from the api_keys table
+	KeyCacheTTL  time.Duration `env:"KEY_CACHE_TTL" envDefault:"1m"`    // How long a revoked Postgres key may still be accepted
+	JWKSFile     string        `env:"JWKS_FILE" envDefault:""`          // Local JWKS for verifying bearer tokens
+	JWTIssuer    string        `env:"JWT_ISSUER" envDefault:""`         // Checked against iss when set
+	JWTAudience  string        `env:"JWT_AUDIENCE" envDefault:""`       // Checked against aud when set
+	JWTLeeway    time.Duration `env:"JWT_LEEWAY" envDefault:"30s"`      // Clock skew allowed on exp and nbf
+}
+
+// RateLimitConfig sizes the token bucket each caller gets. A request spends
+// its route's cost; the default cost is one token.
+type RateLimitConfig struct {
+	Enabled        bool    `env:"ENABLED" envDefault:"false"`
+	Backend        string  `env:"BACKEND" envDefault:"memory"`                      // memory for one instance, redis to share buckets across a fleet
+	Rate           float64 `env:"RATE" envDefault:"10" reload:"true"`               // Tokens refilled per second
+	Burst          int     `env:"BURST" envDefault:"50" reload:"true"`              // Bucket capacity
+	TrustForwarded bool    `env:"TRUST_FORWARDED" envDefault:"false" reload:"true"` // Key anonymous callers by X-Forwarded-For; only behind a proxy that sets it
+}
+
+// IngestConfig tunes file ingestion, by the ingest command or through the admin API
+type IngestConfig struct {
+	Workers   int    `env:"WORKERS" envDefault:"10"`
+	BatchSize int    `env:"BATCH_SIZE" envDefault:"500" reload:"true"` // Transactions committed together
+	Buffer    int    `env:"BUFFER" envDefault:"10000"`                 // Lines read ahead of the workers
+	Dir       string `env:"DIR"`                                       // Directory the admin API ingests files from; empty disables admin ingestion
+}
+
+// RetentionConfig bounds how long detailed history is kept. Totals are kept
+// for good; pruning only drops the activity buckets velocity checks read.
+type RetentionConfig struct {
+	Activity time.Duration `env:"ACTIVITY" envDefault:"2160h" reload:"true"` // Activity buckets older than this are pruned
+}
+
+// AnomalyConfig holds the default velocity rule used for burst detection
+type AnomalyConfig struct {
+	VelocityWindow         time.Duration `env:"VELOCITY_WINDOW" envDefault:"5m" reload:"true"`
+	VelocityOrderThreshold int           `env:"VELOCITY_ORDER_THRESHOLD" envDefault:"20" reload:"true"`
+	VelocitySpendThreshold float64       `env:"VELOCITY_SPEND_THRESHOLD" envDefault:"0" reload:"true"`
+}
+
+// VelocityRule returns the configured default velocity rule
+func (a *AnomalyConfig) VelocityRule() models.VelocityRule {
+	return models.VelocityRule{
+		Window:         a.VelocityWindow,
+		OrderThreshold: a.VelocityOrderThreshold,
+		SpendThreshold: a.VelocitySpendThreshold,
+	}
+}
+
+// Options returns the logger options l configures. An unknown level is info.
+func (l *LogConfig) Options() logger.Options {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		level = slog.LevelInfo
 	}
-	return &cfg, nil
+	return logger.Options{
+		Level:          level,
+		Format:         l.Format,
+		Redact:         l.Redact,
+		SampleInterval: l.SampleInterval,
+		SampleBurst:    l.SampleBurst,
+	}
+}
+
+func (d *DatabaseConfig) ConnectionString() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, d.Password, d.DBName, d.SSLMode)
 }
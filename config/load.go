@@ -0,0 +1,277 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/caarlos0/env/v11"
+	"go.yaml.in/yaml/v3"
+)
+
+// FileEnv names the environment variable read for a config file when no
+// -config flag is given
+const FileEnv = "CONFIG_FILE"
+
+// layerDefaults is a tag no field carries. Parsing a layer with it in place
+// of envDefault leaves the settings the layer doesn't mention as earlier
+// layers set them.
+const layerDefaults = "layerDefault"
+
+// Loader builds a Config in layers, each overriding the one before:
+// defaults, a YAML or TOML file, the environment, then flags
+type Loader struct {
+	file  string
+	flags map[string]string
+}
+
+// New loads configuration from defaults, the file named by CONFIG_FILE and
+// the environment
+func New() (*Config, error) {
+	return (&Loader{}).Load()
+}
+
+// RegisterFlags adds -config and a flag for every setting to fs, named after
+// its environment variable: DB_MAX_OPEN_CONNS is set with -db-max-open-conns.
+// The returned Loader applies them once fs has been parsed.
+func RegisterFlags(fs *flag.FlagSet) *Loader {
+	l := &Loader{flags: make(map[string]string)}
+	fs.StringVar(&l.file, "config", "", "YAML or TOML config file (default $"+FileEnv+")")
+	for _, s := range settings() {
+		key := s.Key
+		fs.Func(FlagName(key), fmt.Sprintf("Sets %s (default %q)", key, s.Default), func(value string) error {
+			l.flags[key] = value
+			return nil
+		})
+	}
+	return l
+}
+
+// FlagName returns the flag that sets the setting named key
+func FlagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// IsSettingFlag reports whether RegisterFlags defines the flag name for a
+// setting, as opposed to -config or a command's own flags
+func IsSettingFlag(name string) bool {
+	for _, s := range settings() {
+		if FlagName(s.Key) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Load builds and validates the configuration
+func (l *Loader) Load() (*Config, error) {
+	return l.load(nil)
+}
+
+// load builds the configuration with overrides applied after every other layer
+func (l *Loader) load(overrides map[string]string) (*Config, error) {
+	var cfg Config
+	if err := env.ParseWithOptions(&cfg, env.Options{Environment: map[string]string{}}); err != nil {
+		return nil, fmt.Errorf("failed to apply config defaults: %w", err)
+	}
+
+	path := l.file
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := apply(&cfg, values); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	if err := apply(&cfg, environ()); err != nil {
+		return nil, fmt.Errorf("invalid config in environment: %w", err)
+	}
+	if err := apply(&cfg, l.flags); err != nil {
+		return nil, fmt.Errorf("invalid config flags: %w", err)
+	}
+	if err := apply(&cfg, overrides); err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+
+	// PORT used to default to ":8080", so deployments may still set it that way
+	cfg.Port = strings.TrimPrefix(cfg.Port, ":")
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// apply sets the settings named in values. The env package reads the process
+// environment when given no values, so an empty layer isn't parsed at all.
+func apply(cfg *Config, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return env.ParseWithOptions(cfg, env.Options{
+		Environment:         values,
+		DefaultValueTagName: layerDefaults,
+	})
+}
+
+func environ() map[string]string {
+	values := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// readFile reads a YAML or TOML file into settings keyed by environment
+// variable. Sections nest with the variable's prefix, so
+//
+//	db:
+//	  max_open_conns: 50
+//
+// sets DB_MAX_OPEN_CONNS. Keys that name no setting are an error, so typos
+// don't go unnoticed.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unknown format %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", doc, values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	known := make(map[string]bool)
+	for _, s := range settings() {
+		known[s.Key] = true
+	}
+	var errs []error
+	for key := range values {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("unknown setting %s", key))
+		}
+	}
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+		return nil, fmt.Errorf("%s: %w", path, errors.Join(errs...))
+	}
+	return values, nil
+}
+
+func flatten(prefix string, doc map[string]any, values map[string]string) error {
+	for key, value := range doc {
+		name := prefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(name+"_", v, values); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				s, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				items[i] = s
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			s, err := scalar(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			values[name] = s
+		}
+	}
+	return nil
+}
+
+func scalar(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// setting is one configurable field
+type setting struct {
+	Key     string // Environment variable
+	Section string // Environment prefix without its trailing underscore, empty at the top level
+	Name    string // Key within its section
+	Default string
+	Secret  bool
+	Reload  bool  // Takes effect without a restart
+	Index   []int // Field path from Config
+}
+
+// settings lists every field of Config in declaration order
+func settings() []setting {
+	var out []setting
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if prefix, ok := field.Tag.Lookup("envPrefix"); ok {
+			for j := range field.Type.NumField() {
+				inner := field.Type.Field(j)
+				out = append(out, newSetting(inner, strings.TrimSuffix(prefix, "_"), prefix, []int{i, j}))
+			}
+			continue
+		}
+		out = append(out, newSetting(field, "", "", []int{i}))
+	}
+	return out
+}
+
+func newSetting(field reflect.StructField, section, prefix string, index []int) setting {
+	name := field.Tag.Get("env")
+	return setting{
+		Key:     prefix + name,
+		Section: section,
+		Name:    name,
+		Default: field.Tag.Get("envDefault"),
+		Secret:  field.Tag.Get("secret") == "true",
+		Reload:  field.Tag.Get("reload") == "true",
+		Index:   index,
+	}
+}
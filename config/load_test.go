@@ -0,0 +1,170 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes a config file named name and returns its path
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// load builds a Config from args as a command line would give them
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return loader.Load()
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": "port: 7000\nlog:\n  level: debug\n  format: text\ndb:\n  host: file-db\n  max_open_conns: 50\n",
+		"config.toml": "port = 7000\n[log]\nlevel = \"debug\"\nformat = \"text\"\n[db]\nhost = \"file-db\"\nmax_open_conns = 50\n",
+	}
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(FileEnv, writeFile(t, name, contents))
+			t.Setenv("LOG_LEVEL", "warn")
+			t.Setenv("DB_HOST", "env-db")
+
+			cfg, err := load(t, "-db-host", "flag-db")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checks := []struct {
+				key, got, want string
+			}{
+				{"PORT", cfg.Port, "7000"},                      // File over default
+				{"LOG_FORMAT", cfg.LogConfig.Format, "text"},    // File over default
+				{"LOG_LEVEL", cfg.LogConfig.Level, "warn"},      // Env over file
+				{"DB_HOST", cfg.DatabaseConfig.Host, "flag-db"}, // Flag over env
+				{"DB_PORT", cfg.DatabaseConfig.Port, "5432"},    // Default
+				{"REDIS_ADDR", cfg.RedisConfig.RedisAddr, "localhost:6379"},
+			}
+			for _, c := range checks {
+				if c.got != c.want {
+					t.Errorf("%s = %q, want %q", c.key, c.got, c.want)
+				}
+			}
+			if cfg.DatabaseConfig.MaxOpenConns != 50 {
+				t.Errorf("DB_MAX_OPEN_CONNS = %d, want 50", cfg.DatabaseConfig.MaxOpenConns)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFlagOverridesEnv(t *testing.T) {
+	t.Setenv(FileEnv, writeFile(t, "env.yaml", "port: 7000\n"))
+	flagFile := writeFile(t, "flag.yaml", "port: 7001\n")
+
+	cfg, err := load(t, "-config", flagFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "7001" {
+		t.Errorf("PORT = %q, want the -config file's 7001", cfg.Port)
+	}
+}
+
+func TestLoadUnknownKeys(t *testing.T) {
+	t.Setenv(FileEnv, writeFile(t, "config.yaml", "prot: 7000\ndb:\n  max_open_connz: 5\n  host: db\n"))
+
+	_, err := load(t)
+	if err == nil {
+		t.Fatal("Load accepted unknown keys")
+	}
+	for _, want := range []string{"unknown setting DB_MAX_OPEN_CONNZ", "unknown setting PROT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name, file, contents, wantErr string
+	}{
+		{"unknown format", "config.json", `{"port": 7000}`, `unknown format ".json"`},
+		{"malformed YAML", "config.yaml", "port: [7000\n", "config.yaml"},
+		{"bad value", "config.yaml", "db:\n  max_open_conns: many\n", "invalid config file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(FileEnv, writeFile(t, tt.file, tt.contents))
+			if _, err := load(t); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadValidationErrorsAggregate(t *testing.T) {
+	t.Setenv(FileEnv, "")
+	t.Setenv("LOG_FORMAT", "xml")
+	t.Setenv("INGEST_WORKERS", "0")
+	t.Setenv("TRACING_SAMPLE_RATIO", "2")
+
+	_, err := load(t, "-drain-delay", "30s")
+	if err == nil {
+		t.Fatal("Load accepted invalid settings")
+	}
+	for _, want := range []string{
+		`LOG_FORMAT must be one of [json text], got "xml"`,
+		"INGEST_WORKERS must be positive, got 0",
+		"TRACING_SAMPLE_RATIO must be between 0 and 1, got 2",
+		"SHUTDOWN_TIMEOUT (25s) must exceed DRAIN_DELAY (30s)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error doesn't mention %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadPort(t *testing.T) {
+	tests := []struct {
+		port, want string
+		ok         bool
+	}{
+		{"", "8080", true},
+		{"9090", "9090", true},
+		{":8080", "8080", true}, // The old default's form
+		{"http", "", false},
+		{"70000", "", false},
+		{"localhost:8080", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.port, func(t *testing.T) {
+			t.Setenv(FileEnv, "")
+			var args []string
+			if tt.port != "" {
+				args = []string{"-port", tt.port}
+			}
+			cfg, err := load(t, args...)
+			if !tt.ok {
+				if err == nil || !strings.Contains(err.Error(), "PORT must be a port number") {
+					t.Errorf("Load = %v, want a PORT error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.want {
+				t.Errorf("PORT = %q, want %q", cfg.Port, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// redacted replaces the value of secret settings that are set
+const redacted = "REDACTED"
+
+// Print writes the configuration as YAML in the layout Load reads, with
+// secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := make(map[string]*yaml.Node)
+
+	v := reflect.ValueOf(c).Elem()
+	for _, s := range settings() {
+		parent := root
+		if s.Section != "" {
+			parent = sections[s.Section]
+			if parent == nil {
+				parent = &yaml.Node{Kind: yaml.MappingNode}
+				sections[s.Section] = parent
+				root.Content = append(root.Content, scalarNode("!!str", strings.ToLower(s.Section)), parent)
+			}
+		}
+
+		tag, value := format(v.FieldByIndex(s.Index))
+		if s.Secret && value != "" {
+			tag, value = "!!str", redacted
+		}
+		parent.Content = append(parent.Content, scalarNode("!!str", strings.ToLower(s.Name)), scalarNode(tag, value))
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	return enc.Close()
+}
+
+// Values returns every setting keyed by environment variable, with secrets
+// redacted as Print does
+func (c *Config) Values() map[string]string {
+	v := reflect.ValueOf(c).Elem()
+	values := make(map[string]string)
+	for _, s := range settings() {
+		_, value := format(v.FieldByIndex(s.Index))
+		if s.Secret && value != "" {
+			value = redacted
+		}
+		values[s.Key] = value
+	}
+	return values
+}
+
+func scalarNode(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+// format renders a setting as Load parses it, with its YAML tag
+func format(v reflect.Value) (tag, value string) {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return "!!str", d.String()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return "!!str", strings.Join(items, ",")
+	case reflect.Bool:
+		return "!!bool", strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int64:
+		return "!!int", strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64:
+		// Untagged, so whole numbers print plainly and read back as floats all the same
+		return "", strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	default:
+		return "!!str", fmt.Sprint(v.Interface())
+	}
+}
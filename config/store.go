@@ -0,0 +1,162 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"tx-processor/logger"
+)
+
+// Change reports what a reload did
+type Change struct {
+	Changed         []string // Settings that took new values
+	RestartRequired []string // Settings whose new values only apply after a restart
+}
+
+// Store holds the running configuration and swaps in new values for the
+// settings tagged reload, on SIGHUP or through the admin API. Components read
+// Current as they need a setting, or Subscribe to apply changes themselves.
+type Store struct {
+	loader  *Loader
+	logger  logger.Logger
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex // Serializes reloads and subscriber calls
+	overrides   map[string]string
+	subscribers []func(*Config)
+}
+
+// NewStore starts from cfg, which loader built, and reloads through loader
+func NewStore(loader *Loader, cfg *Config, logger logger.Logger) *Store {
+	s := &Store{loader: loader, logger: logger, overrides: make(map[string]string)}
+	s.current.Store(cfg)
+	return s
+}
+
+// Current returns the running configuration. Callers must not modify it.
+func (s *Store) Current() *Config {
+	return s.current.Load()
+}
+
+// Subscribe calls fn with the configuration after every reload that changes
+// a setting. Calls are made one at a time, in the order subscribed.
+func (s *Store) Subscribe(fn func(*Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Settings returns the current value of every reloadable setting
+func (s *Store) Settings() map[string]string {
+	v := reflect.ValueOf(s.Current()).Elem()
+	values := make(map[string]string)
+	for _, st := range settings() {
+		if st.Reload {
+			_, values[st.Key] = format(v.FieldByIndex(st.Index))
+		}
+	}
+	return values
+}
+
+// Reload re-reads the config file and environment, dropping values set
+// through Set
+func (s *Store) Reload() (Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.swap(make(map[string]string))
+}
+
+// Set overrides reloadable settings, keyed by environment variable, on top of
+// the file, environment and flags. Overrides last until the next Reload.
+func (s *Store) Set(values map[string]string) (Change, error) {
+	reloadable := make(map[string]bool)
+	for _, st := range settings() {
+		reloadable[st.Key] = st.Reload
+	}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		reload, ok := reloadable[key]
+		if !ok {
+			return Change{}, fmt.Errorf("unknown setting %s", key)
+		}
+		if !reload {
+			return Change{}, fmt.Errorf("%s only changes with a restart", key)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	overrides := maps.Clone(s.overrides)
+	maps.Copy(overrides, values)
+	return s.swap(overrides)
+}
+
+// swap loads the configuration with overrides and makes its reloadable
+// settings current. Callers hold s.mu.
+func (s *Store) swap(overrides map[string]string) (Change, error) {
+	loaded, err := s.loader.load(overrides)
+	if err != nil {
+		s.logger.Error("settings not reloaded", "error", err)
+		return Change{}, err
+	}
+
+	old := s.Current()
+	next := *old
+	var change Change
+	oldV, loadedV, nextV := reflect.ValueOf(old).Elem(), reflect.ValueOf(loaded).Elem(), reflect.ValueOf(&next).Elem()
+	for _, st := range settings() {
+		if reflect.DeepEqual(oldV.FieldByIndex(st.Index).Interface(), loadedV.FieldByIndex(st.Index).Interface()) {
+			continue
+		}
+		if !st.Reload {
+			change.RestartRequired = append(change.RestartRequired, st.Key)
+			continue
+		}
+		nextV.FieldByIndex(st.Index).Set(loadedV.FieldByIndex(st.Index))
+		change.Changed = append(change.Changed, st.Key)
+	}
+	// Reloadable settings are checked against the running ones they join
+	if err := next.Validate(); err != nil {
+		err = fmt.Errorf("invalid config: %w", err)
+		s.logger.Error("settings not reloaded", "error", err)
+		return Change{}, err
+	}
+
+	s.overrides = overrides
+	if len(change.RestartRequired) > 0 {
+		s.logger.Warn("settings changed that only apply after a restart", "settings", change.RestartRequired)
+	}
+	if len(change.Changed) == 0 {
+		return change, nil
+	}
+
+	s.current.Store(&next)
+	for _, fn := range s.subscribers {
+		fn(&next)
+	}
+	s.logger.Info("settings reloaded", "settings", change.Changed)
+	return change, nil
+}
+
+// ReloadOnSignal reloads whenever the process receives one of signals, until
+// ctx is done. Failed reloads are logged and leave the configuration as it was.
+func (s *Store) ReloadOnSignal(ctx context.Context, signals ...os.Signal) error {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig := <-received:
+			s.logger.Info("reloading settings", "signal", sig.String())
+			s.Reload()
+		}
+	}
+}
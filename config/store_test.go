@@ -0,0 +1,108 @@
+package config
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"slices"
+	"testing"
+	"tx-processor/logger"
+)
+
+// newStore loads a Store from the YAML file it returns the path of
+func newStore(t *testing.T, contents string) (*Store, string) {
+	t.Helper()
+	path := writeFile(t, "config.yaml", contents)
+	t.Setenv(FileEnv, path)
+
+	loader := RegisterFlags(flag.NewFlagSet("test", flag.ContinueOnError))
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(loader, cfg, logger.NewSlogAdapter(slog.New(slog.DiscardHandler))), path
+}
+
+func rewrite(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	store, path := newStore(t, "port: 7000\nlog:\n  level: info\n")
+	var notified []*Config
+	store.Subscribe(func(cfg *Config) { notified = append(notified, cfg) })
+
+	rewrite(t, path, "port: 7001\nlog:\n  level: debug\n")
+	change, err := store.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(change.Changed, []string{"LOG_LEVEL"}) || !slices.Equal(change.RestartRequired, []string{"PORT"}) {
+		t.Errorf("change = %+v, want LOG_LEVEL changed and PORT needing a restart", change)
+	}
+	cfg := store.Current()
+	if cfg.LogConfig.Level != "debug" || cfg.Port != "7000" {
+		t.Errorf("current LOG_LEVEL, PORT = %q, %q; want debug, 7000", cfg.LogConfig.Level, cfg.Port)
+	}
+	if len(notified) != 1 || notified[0] != cfg {
+		t.Fatalf("subscriber called %d times, want once with the current config", len(notified))
+	}
+
+	// A reload that changes nothing reloadable doesn't notify
+	if _, err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(notified) != 1 {
+		t.Errorf("subscriber called %d times after an idle reload, want 1", len(notified))
+	}
+
+	// Neither does a failed one, which leaves the config as it was
+	rewrite(t, path, "log:\n  level: loud\n")
+	if _, err := store.Reload(); err == nil {
+		t.Error("Reload accepted LOG_LEVEL=loud")
+	}
+	if len(notified) != 1 || store.Current() != cfg {
+		t.Error("failed reload changed the config")
+	}
+}
+
+func TestStoreSet(t *testing.T) {
+	store, _ := newStore(t, "log:\n  level: info\n")
+	var calls int
+	store.Subscribe(func(*Config) { calls++ })
+
+	change, err := store.Set(map[string]string{"LOG_LEVEL": "warn", "LOG_SAMPLE_BURST": "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(change.Changed, []string{"LOG_LEVEL", "LOG_SAMPLE_BURST"}) || calls != 1 {
+		t.Errorf("change = %+v after %d calls, want both settings in one call", change, calls)
+	}
+	if got := store.Settings()["LOG_SAMPLE_BURST"]; got != "5" {
+		t.Errorf("LOG_SAMPLE_BURST = %q, want 5", got)
+	}
+
+	for name, values := range map[string]map[string]string{
+		"unknown setting":  {"LOG_LEVLE": "warn"},
+		"restart required": {"PORT": "9000"},
+		"invalid value":    {"LOG_SAMPLE_BURST": "0"},
+	} {
+		if _, err := store.Set(values); err == nil {
+			t.Errorf("Set with %s succeeded", name)
+		}
+	}
+	if calls != 1 || store.Current().LogConfig.Level != "warn" {
+		t.Error("rejected Set changed the config")
+	}
+
+	// Reload drops what Set overrode
+	if _, err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if level := store.Current().LogConfig.Level; level != "info" || calls != 2 {
+		t.Errorf("LOG_LEVEL after Reload = %q with %d calls, want the file's info", level, calls)
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Validate reports every invalid setting at once, by environment variable
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(key string, d time.Duration) {
+		check(d > 0, "%s must be positive, got %s", key, d)
+	}
+	nonNegative := func(key string, d time.Duration) {
+		check(d >= 0, "%s must not be negative, got %s", key, d)
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		check(slices.Contains(allowed, value), "%s must be one of %v, got %q", key, allowed, value)
+	}
+
+	l := c.LogConfig
+	var level slog.Level
+	check(level.UnmarshalText([]byte(l.Level)) == nil, "LOG_LEVEL must be debug, info, warn or error, got %q", l.Level)
+	oneOf("LOG_FORMAT", l.Format, "json", "text")
+	nonNegative("LOG_SAMPLE_INTERVAL", l.SampleInterval)
+	check(l.SampleBurst > 0, "LOG_SAMPLE_BURST must be positive, got %d", l.SampleBurst)
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port >= 0 && port <= 65535, "PORT must be a port number, got %q", c.Port)
+	nonNegative("DRAIN_DELAY", c.DrainDelay)
+	check(c.ShutdownTimeout > c.DrainDelay, "SHUTDOWN_TIMEOUT (%s) must exceed DRAIN_DELAY (%s) to leave time for requests to finish", c.ShutdownTimeout, c.DrainDelay)
+	positive("HEALTH_TIMEOUT", c.HealthTimeout)
+
+	h := c.HTTPConfig
+	nonNegative("HTTP_READ_HEADER_TIMEOUT", h.ReadHeaderTimeout)
+	nonNegative("HTTP_READ_TIMEOUT", h.ReadTimeout)
+	nonNegative("HTTP_WRITE_TIMEOUT", h.WriteTimeout)
+	nonNegative("HTTP_IDLE_TIMEOUT", h.IdleTimeout)
+	nonNegative("HTTP_REQUEST_TIMEOUT", h.RequestTimeout)
+	nonNegative("HTTP_SCAN_TIMEOUT", h.ScanTimeout)
+	check(h.MaxHeaderBytes > 0, "HTTP_MAX_HEADER_BYTES must be positive, got %d", h.MaxHeaderBytes)
+	check(h.MaxBodyBytes > 0, "HTTP_MAX_BODY_BYTES must be positive, got %d", h.MaxBodyBytes)
+
+	r := c.RedisConfig
+	if r.RedisEnabled {
+		check(r.RedisAddr != "", "REDIS_ADDR is required when REDIS_ENABLED is set")
+	}
+	check(r.RedisDB >= 0, "REDIS_DB must not be negative, got %d", r.RedisDB)
+	positive("REDIS_CACHE_TTL", r.CacheTTL)
+	check(r.CachePrefix != "", "REDIS_CACHE_PREFIX must not be empty")
+
+	d := c.DatabaseConfig
+	check(d.Host != "", "DB_HOST must not be empty")
+	oneOf("DB_SSLMODE", d.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	check(d.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative, got %d", d.MaxOpenConns)
+	check(d.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative, got %d", d.MaxIdleConns)
+	nonNegative("DB_CONN_MAX_LIFETIME", d.ConnMaxLifetime)
+
+	a := c.AnomalyConfig
+	positive("ANOMALY_VELOCITY_WINDOW", a.VelocityWindow)
+	check(a.VelocityOrderThreshold >= 0, "ANOMALY_VELOCITY_ORDER_THRESHOLD must not be negative, got %d", a.VelocityOrderThreshold)
+	check(a.VelocitySpendThreshold >= 0, "ANOMALY_VELOCITY_SPEND_THRESHOLD must not be negative, got %g", a.VelocitySpendThreshold)
+
+	ca := c.CacheConfig
+	check(ca.LocalSize > 0, "CACHE_LOCAL_SIZE must be positive, got %d", ca.LocalSize)
+	nonNegative("CACHE_LOCAL_TTL", ca.LocalTTL)
+	nonNegative("CACHE_NEGATIVE_TTL", ca.NegativeTTL)
+	check(ca.FillWorkers >= 0, "CACHE_FILL_WORKERS must not be negative, got %d", ca.FillWorkers)
+	check(ca.FillQueue >= 0, "CACHE_FILL_QUEUE must not be negative, got %d", ca.FillQueue)
+
+	t := c.TracingConfig
+	oneOf("TRACING_EXPORTER", t.Exporter, "none", "stdout", "otlp")
+	check(t.SampleRatio >= 0 && t.SampleRatio <= 1, "TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", t.SampleRatio)
+
+	e := c.EventsConfig
+	check(e.Buffer > 0, "EVENTS_BUFFER must be positive, got %d", e.Buffer)
+	positive("EVENTS_HEARTBEAT", e.Heartbeat)
+	positive("EVENTS_WRITE_TIMEOUT", e.WriteTimeout)
+	check(e.LeaderboardSize > 0, "EVENTS_LEADERBOARD_SIZE must be positive, got %d", e.LeaderboardSize)
+	positive("EVENTS_LEADERBOARD_INTERVAL", e.LeaderboardInterval)
+
+	g := c.GRPCConfig
+	if g.Enabled {
+		check(g.Addr != "", "GRPC_ADDR is required when GRPC_ENABLED is set")
+	}
+	check(g.IngestBatchSize > 0, "GRPC_INGEST_BATCH_SIZE must be positive, got %d", g.IngestBatchSize)
+
+	au := c.AuthConfig
+	if au.Enabled {
+		check(au.KeysFile != "" || au.KeysPostgres || au.JWKSFile != "",
+			"AUTH_ENABLED needs AUTH_KEYS_FILE, AUTH_KEYS_POSTGRES or AUTH_JWKS_FILE")
+	}
+	nonNegative("AUTH_KEY_CACHE_TTL", au.KeyCacheTTL)
+	nonNegative("AUTH_JWT_LEEWAY", au.JWTLeeway)
+
+	rl := c.RateLimitConfig
+	if rl.Enabled {
+		oneOf("RATE_LIMIT_BACKEND", rl.Backend, "memory", "redis")
+		check(rl.Backend != "redis" || r.RedisEnabled, "RATE_LIMIT_BACKEND=redis needs REDIS_ENABLED")
+		check(rl.Rate > 0, "RATE_LIMIT_RATE must be positive, got %g", rl.Rate)
+		check(rl.Burst > 0, "RATE_LIMIT_BURST must be positive, got %d", rl.Burst)
+	}
+
+	in := c.IngestConfig
+	check(in.Workers > 0, "INGEST_WORKERS must be positive, got %d", in.Workers)
+	check(in.BatchSize > 0, "INGEST_BATCH_SIZE must be positive, got %d", in.BatchSize)
+	check(in.Buffer >= 0, "INGEST_BUFFER must not be negative, got %d", in.Buffer)
+
+	re := c.RetentionConfig
+	check(re.Activity >= a.VelocityWindow, "RETENTION_ACTIVITY (%s) must be at least ANOMALY_VELOCITY_WINDOW (%s)", re.Activity, a.VelocityWindow)
+
+	return errors.Join(errs...)
+}
@@ -1,63 +1,45 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"tx-processor/config"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
 	_ "github.com/lib/pq"
 )
 
+// NewPostgresDB connects and brings the schema up to date
 func NewPostgresDB(cfg *config.DatabaseConfig) (*sqlx.DB, error) {
-	db, err := sqlx.Connect("postgres", cfg.ConnectionString())
+	db, err := Open(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
 
-	if err := createSchema(db); err != nil {
+	if err := Migrate(context.Background(), db); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to create schema: %w", err)
 	}
 
-	// Optimize connection pool for high throughput
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-
 	return db, nil
 }
 
-func createSchema(db *sqlx.DB) error {
-	schema := `
-    -- Our main analytics table
-    CREATE TABLE IF NOT EXISTS user_analytics (
-        user_id VARCHAR(255) PRIMARY KEY,
-        total_orders INTEGER DEFAULT 0,
-        total_spent DECIMAL(15,2) DEFAULT 0.0,
-        last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-    );
-
-    -- These indexes are crucial for our API performance!
-    CREATE INDEX IF NOT EXISTS idx_user_analytics_orders 
-    ON user_analytics(total_orders DESC);
-    
-    CREATE INDEX IF NOT EXISTS idx_user_analytics_spent 
-    ON user_analytics(total_spent DESC);
-    
-    -- Automatic timestamp updates
-    CREATE OR REPLACE FUNCTION update_last_updated_column()
-    RETURNS TRIGGER AS $$
-    BEGIN
-        NEW.last_updated = CURRENT_TIMESTAMP;
-        RETURN NEW;
-    END;
-    $$ language 'plpgsql';
-
-    DROP TRIGGER IF EXISTS update_user_analytics_last_updated ON user_analytics;
-    CREATE TRIGGER update_user_analytics_last_updated
-        BEFORE UPDATE ON user_analytics
-        FOR EACH ROW
-        EXECUTE FUNCTION update_last_updated_column();
-    `
-
-	_, err := db.Exec(schema)
-	return err
+// Open connects without migrating, for tools that look at the schema before
+// changing it
+func Open(cfg *config.DatabaseConfig) (*sqlx.DB, error) {
+	db, err := sqlx.Connect("postgres", cfg.ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// Map columns onto the json tags our models already carry
+	db.Mapper = reflectx.NewMapperFunc("json", strings.ToLower)
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	return db, nil
 }
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migrations are applied in order; migration i brings the schema to version i+1.
+// Append only: never edit a migration that has shipped.
+var migrations = []string{
+	// 1: analytics table, ranking indexes and last_updated trigger
+	`
+    -- Our main analytics table
+    CREATE TABLE IF NOT EXISTS user_analytics (
+        user_id VARCHAR(255) PRIMARY KEY,
+        total_orders INTEGER DEFAULT 0,
+        total_spent DECIMAL(15,2) DEFAULT 0.0,
+        last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    -- These indexes are crucial for our API performance!
+    CREATE INDEX IF NOT EXISTS idx_user_analytics_orders 
+    ON user_analytics(total_orders DESC);
+    
+    CREATE INDEX IF NOT EXISTS idx_user_analytics_spent 
+    ON user_analytics(total_spent DESC);
+    
+    -- Automatic timestamp updates
+    CREATE OR REPLACE FUNCTION update_last_updated_column()
+    RETURNS TRIGGER AS $$
+    BEGIN
+        NEW.last_updated = CURRENT_TIMESTAMP;
+        RETURN NEW;
+    END;
+    $$ language 'plpgsql';
+
+    DROP TRIGGER IF EXISTS update_user_analytics_last_updated ON user_analytics;
+    CREATE TRIGGER update_user_analytics_last_updated
+        BEFORE UPDATE ON user_analytics
+        FOR EACH ROW
+        EXECUTE FUNCTION update_last_updated_column();
+    `,
+	// 2: per-minute activity buckets for velocity/burst detection
+	`
+    CREATE TABLE IF NOT EXISTS user_activity (
+        user_id VARCHAR(255) NOT NULL,
+        bucket_start TIMESTAMPTZ NOT NULL,
+        orders INTEGER NOT NULL DEFAULT 0,
+        spent DECIMAL(15,2) NOT NULL DEFAULT 0.0,
+        PRIMARY KEY (user_id, bucket_start)
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_user_activity_bucket
+    ON user_activity(bucket_start);
+    `,
+	// 3: keyset pagination indexes; NULLs would drop out of row comparisons
+	`
+    UPDATE user_analytics SET total_orders = 0 WHERE total_orders IS NULL;
+    UPDATE user_analytics SET total_spent = 0 WHERE total_spent IS NULL;
+    UPDATE user_analytics SET last_updated = CURRENT_TIMESTAMP WHERE last_updated IS NULL;
+
+    ALTER TABLE user_analytics
+        ALTER COLUMN total_orders SET NOT NULL,
+        ALTER COLUMN total_spent SET NOT NULL,
+        ALTER COLUMN last_updated SET NOT NULL;
+
+    -- Supersede the single-column ranking indexes with (score, user_id) keys
+    CREATE INDEX IF NOT EXISTS idx_user_analytics_orders_user
+    ON user_analytics(total_orders DESC, user_id DESC);
+
+    CREATE INDEX IF NOT EXISTS idx_user_analytics_spent_user
+    ON user_analytics(total_spent DESC, user_id DESC);
+
+    CREATE INDEX IF NOT EXISTS idx_user_analytics_updated_user
+    ON user_analytics(last_updated DESC, user_id DESC);
+
+    DROP INDEX IF EXISTS idx_user_analytics_orders;
+    DROP INDEX IF EXISTS idx_user_analytics_spent;
+    `,
+	// 4: first and last order times for user profiles
+	`
+    ALTER TABLE user_analytics
+        ADD COLUMN IF NOT EXISTS first_order_at TIMESTAMPTZ,
+        ADD COLUMN IF NOT EXISTS last_order_at TIMESTAMPTZ;
+
+    -- Backfill from activity buckets, accurate to the bucket. The backfill
+    -- isn't a change to the user's totals, so it leaves last_updated alone.
+    ALTER TABLE user_analytics DISABLE TRIGGER update_user_analytics_last_updated;
+
+    UPDATE user_analytics ua
+    SET first_order_at = a.first_bucket,
+        last_order_at = a.last_bucket
+    FROM (
+        SELECT user_id, MIN(bucket_start) AS first_bucket, MAX(bucket_start) AS last_bucket
+        FROM user_activity
+        GROUP BY user_id
+    ) a
+    WHERE ua.user_id = a.user_id;
+
+    ALTER TABLE user_analytics ENABLE TRIGGER update_user_analytics_last_updated;
+    `,
+	// 5: hashed API keys
+	`
+    CREATE TABLE IF NOT EXISTS api_keys (
+        id VARCHAR(255) PRIMARY KEY,
+        key_hash VARCHAR(100) NOT NULL UNIQUE,
+        scopes TEXT[] NOT NULL DEFAULT '{}',
+        created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        revoked_at TIMESTAMPTZ
+    );
+    `,
+}
+
+// SchemaVersion is the schema version this build expects
+var SchemaVersion = len(migrations)
+
+// migrationLockID serialises migrations across processes starting together
+const migrationLockID = 7_150_042
+
+// Migrate applies any migrations newer than the database's current version.
+// Each migration runs in its own transaction.
+func Migrate(ctx context.Context, db *sqlx.DB) error {
+	if _, err := db.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    )`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	for i, migration := range migrations {
+		if err := applyMigration(ctx, db, i+1, migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sqlx.DB, version int, migration string) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin migration %d: %w", version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("lock migration %d: %w", version, err)
+	}
+
+	var applied bool
+	if err := tx.GetContext(ctx, &applied, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version); err != nil {
+		return fmt.Errorf("check migration %d: %w", version, err)
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return fmt.Errorf("apply migration %d: %w", version, err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return fmt.Errorf("record migration %d: %w", version, err)
+	}
+
+	return tx.Commit()
+}
+
+// CurrentVersion returns the highest applied migration, or 0 for an empty database
+func CurrentVersion(ctx context.Context, db *sqlx.DB) (int, error) {
+	// A database never migrated has no schema_migrations table yet
+	var tracked bool
+	if err := db.GetContext(ctx, &tracked, "SELECT to_regclass('schema_migrations') IS NOT NULL"); err != nil {
+		return 0, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	if !tracked {
+		return 0, nil
+	}
+
+	var version int
+	if err := db.GetContext(ctx, &version, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations"); err != nil {
+		return 0, fmt.Errorf("select schema version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,225 @@
+// Package events fans live analytics updates out to subscribers such as SSE
+// streams. Publishing never blocks: a subscriber that falls behind loses
+// events rather than holding up ingestion.
+package events
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"tx-processor/models"
+)
+
+// Publisher accepts events for delivery
+type Publisher interface {
+	Publish(ctx context.Context, event models.Event) error
+}
+
+// Stats counts a broker's deliveries
+type Stats struct {
+	Subscribers int    `json:"subscribers"`
+	Published   uint64 `json:"published"`
+	Dropped     uint64 `json:"dropped"` // Deliveries skipped because a subscriber's buffer was full
+}
+
+type Broker struct {
+	mu     sync.Mutex
+	buffer int
+	nextID uint64
+	subs   map[*Subscription]struct{}
+	latest map[models.RankBy]models.Event // Replayed to new subscribers
+	closed bool
+
+	published atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// NewBroker returns a broker that buffers up to buffer events per subscriber
+func NewBroker(buffer int) *Broker {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	return &Broker{
+		buffer: buffer,
+		subs:   make(map[*Subscription]struct{}),
+		latest: make(map[models.RankBy]models.Event),
+	}
+}
+
+// Publish assigns the event an ID and offers it to every matching subscriber
+// without waiting for any of them.
+func (b *Broker) Publish(ctx context.Context, event models.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.nextID++
+	event.ID = b.nextID
+	b.published.Add(1)
+
+	if event.Type == models.EventLeaderboard && event.Leaderboard != nil {
+		b.latest[event.Leaderboard.By] = event
+	}
+	for sub := range b.subs {
+		b.offer(sub, event)
+	}
+	return nil
+}
+
+// offer delivers event to sub if it matches, dropping it if sub is full. Callers hold b.mu.
+func (b *Broker) offer(sub *Subscription, event models.Event) {
+	matched, ok := sub.match(event)
+	if !ok {
+		return
+	}
+	select {
+	case sub.events <- matched:
+		// Only what the subscriber received counts as seen, so a dropped
+		// leaderboard is sent again when it next appears
+		if matched.Type == models.EventLeaderboard {
+			sub.lastTop[matched.Leaderboard.By] = matched.Leaderboard.Users
+		}
+	default:
+		sub.dropped.Add(1)
+		b.dropped.Add(1)
+	}
+}
+
+// Subscribe starts delivering matching events, beginning with the latest
+// leaderboard of each ranking. The subscription must be closed.
+func (b *Broker) Subscribe(filter models.EventFilter) *Subscription {
+	sub := &Subscription{
+		broker:  b,
+		events:  make(chan models.Event, b.buffer),
+		filter:  filter,
+		userIDs: make(map[string]bool, len(filter.UserIDs)),
+		lastTop: make(map[models.RankBy][]models.UserSummary),
+	}
+	for _, userID := range filter.UserIDs {
+		sub.userIDs[userID] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	for _, by := range []models.RankBy{models.RankByOrders, models.RankBySpend} {
+		if event, ok := b.latest[by]; ok {
+			b.offer(sub, event)
+		}
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Stats returns delivery counts since the broker was created
+func (b *Broker) Stats() Stats {
+	b.mu.Lock()
+	subscribers := len(b.subs)
+	b.mu.Unlock()
+
+	return Stats{
+		Subscribers: subscribers,
+		Published:   b.published.Load(),
+		Dropped:     b.dropped.Load(),
+	}
+}
+
+// Close ends every subscription; later publishes are discarded
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Subscription receives a filtered view of a broker's events
+type Subscription struct {
+	broker  *Broker
+	events  chan models.Event
+	filter  models.EventFilter
+	userIDs map[string]bool
+	lastTop map[models.RankBy][]models.UserSummary // Last leaderboard delivered per ranking, for EventFilter.Top
+	dropped atomic.Uint64
+}
+
+// Events delivers matching events until the subscription or broker is closed
+func (s *Subscription) Events() <-chan models.Event {
+	return s.events
+}
+
+// TakeDropped returns how many events were dropped since the last call
+func (s *Subscription) TakeDropped() uint64 {
+	return s.dropped.Swap(0)
+}
+
+// Close stops delivery and releases the subscription
+func (s *Subscription) Close() {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.events)
+	}
+}
+
+// match returns the part of event the subscriber asked for, if any. Callers hold the broker's lock.
+func (s *Subscription) match(event models.Event) (models.Event, bool) {
+	if len(s.filter.Types) > 0 && !slices.Contains(s.filter.Types, event.Type) {
+		return models.Event{}, false
+	}
+
+	switch event.Type {
+	case models.EventAnalytics:
+		if len(s.userIDs) == 0 {
+			return event, true
+		}
+		var deltas []models.UserDelta
+		for _, delta := range event.Deltas {
+			if s.userIDs[delta.UserID] {
+				deltas = append(deltas, delta)
+			}
+		}
+		if len(deltas) == 0 {
+			return models.Event{}, false
+		}
+		event.Deltas = deltas
+		return event, true
+
+	case models.EventLeaderboard:
+		change := event.Leaderboard
+		if change == nil || (s.filter.By != "" && change.By != s.filter.By) {
+			return models.Event{}, false
+		}
+		users := change.Users
+		if s.filter.Top > 0 && len(users) > s.filter.Top {
+			users = users[:s.filter.Top]
+		}
+		if slices.EqualFunc(users, s.lastTop[change.By], sameStanding) {
+			return models.Event{}, false
+		}
+		event.Leaderboard = &models.LeaderboardChange{By: change.By, Users: users}
+		return event, true
+	}
+	return event, true
+}
+
+// sameStanding reports whether two leaderboard entries show the same user with the same totals
+func sameStanding(a, b models.UserSummary) bool {
+	return a.UserID == b.UserID && a.TotalOrders == b.TotalOrders && a.TotalSpent == b.TotalSpent
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"slices"
+	"time"
+	"tx-processor/logger"
+	"tx-processor/models"
+)
+
+// Ranker lists the top users of a ranking
+type Ranker interface {
+	GetTopUsers(ctx context.Context, query models.UserQuery) (*models.UserPage, error)
+}
+
+// WatchLeaderboard publishes a leaderboard event whenever the top size users
+// of a ranking change, until ctx is cancelled. Rankings are re-read at most
+// once per interval, and only after analytics events show something changed.
+func WatchLeaderboard(ctx context.Context, broker *Broker, ranker Ranker, size int, interval time.Duration, log logger.Logger) {
+	sub := broker.Subscribe(models.EventFilter{Types: []models.EventType{models.EventAnalytics}})
+	defer sub.Close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := make(map[models.RankBy][]models.UserSummary)
+	dirty := true // Publish the current standings on start
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			dirty = true
+		case <-ticker.C:
+			// A full buffer only means the watcher missed batches it would have coalesced anyway
+			sub.TakeDropped()
+			if !dirty {
+				continue
+			}
+			dirty = false
+
+			for _, by := range []models.RankBy{models.RankByOrders, models.RankBySpend} {
+				page, err := ranker.GetTopUsers(ctx, models.UserQuery{Sort: models.SortKey(by), Limit: size})
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Warn("failed to read leaderboard for events", "by", by, "error", err)
+					}
+					dirty = true // Try again next tick
+					continue
+				}
+				if slices.EqualFunc(page.Users, last[by], sameStanding) {
+					continue
+				}
+				last[by] = page.Users
+
+				broker.Publish(ctx, models.Event{
+					Type:        models.EventLeaderboard,
+					At:          time.Now().UTC(),
+					Leaderboard: &models.LeaderboardChange{By: by, Users: page.Users},
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"tx-processor/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultChannel is the Redis channel events are published on
+const DefaultChannel = "events:analytics"
+
+// RedisPublisher publishes events to Redis, for Relay to deliver to brokers in
+// other processes
+type RedisPublisher struct {
+	client  *redis.Client
+	Channel string
+}
+
+func NewRedisPublisher(client *redis.Client) *RedisPublisher {
+	return &RedisPublisher{client: client, Channel: DefaultChannel}
+}
+
+func (p *RedisPublisher) Publish(ctx context.Context, event models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if err := p.client.Publish(ctx, p.Channel, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// Relay delivers events published to channel into publisher until ctx is
+// cancelled. Events missed while disconnected are not replayed.
+func Relay(ctx context.Context, client *redis.Client, channel string, publisher Publisher) error {
+	sub := client.Subscribe(ctx, channel)
+	defer sub.Close()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var event models.Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil || !event.Type.Valid() {
+				continue // Not ours to handle
+			}
+			if err := publisher.Publish(ctx, event); err != nil {
+				return err
+			}
+		}
+	}
+}
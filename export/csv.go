@@ -0,0 +1,58 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+	"tx-processor/models"
+)
+
+// columns is the header of CSV exports and the field order of every format
+var columns = []string{
+	"user_id", "total_orders", "total_spent", "avg_order_value",
+	"first_order_at", "last_order_at", "last_updated",
+}
+
+type csvWriter struct {
+	buf    *bufio.Writer
+	csv    *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	buf := bufio.NewWriter(w)
+	c := &csvWriter{buf: buf, csv: csv.NewWriter(buf), record: make([]string, len(columns))}
+	if err := c.csv.Write(columns); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) Write(user models.UserSummary) error {
+	c.record[0] = user.UserID
+	c.record[1] = strconv.Itoa(user.TotalOrders)
+	c.record[2] = strconv.FormatFloat(user.TotalSpent, 'f', -1, 64)
+	c.record[3] = strconv.FormatFloat(user.AvgOrderValue, 'f', -1, 64)
+	c.record[4] = formatTime(user.FirstOrderAt)
+	c.record[5] = formatTime(user.LastOrderAt)
+	c.record[6] = formatTime(user.LastUpdated)
+	return c.csv.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.csv.Flush()
+	if err := c.csv.Error(); err != nil {
+		return err
+	}
+	return c.buf.Flush()
+}
+
+// formatTime renders t as RFC 3339, or empty if unknown
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
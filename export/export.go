@@ -0,0 +1,80 @@
+// Package export encodes user analytics for bulk download in CSV, NDJSON or
+// Parquet. Writers encode one user at a time, so memory stays flat however
+// many users are exported.
+package export
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"strings"
+	"tx-processor/models"
+)
+
+// Negotiate picks the format for an Accept header, preferring the client's
+// order and then models.ExportFormats. An empty header accepts anything; ok is false if
+// nothing listed is supported.
+func Negotiate(accept string) (format models.ExportFormat, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return models.ExportFormats[0], true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case "*/*", "application/*":
+			return models.ExportFormats[0], true
+		case "text/*":
+			return models.ExportCSV, true
+		}
+		for _, f := range models.ExportFormats {
+			if mediaType == f.ContentType() {
+				return f, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Writer encodes users in one format. Close completes the output and must be
+// called even when no users were written.
+type Writer interface {
+	Write(user models.UserSummary) error
+	Close() error
+}
+
+// NewWriter returns a Writer that encodes users to w in the given format
+func NewWriter(format models.ExportFormat, w io.Writer) (Writer, error) {
+	switch format {
+	case models.ExportCSV:
+		return newCSVWriter(w)
+	case models.ExportNDJSON:
+		return newNDJSONWriter(w), nil
+	case models.ExportParquet:
+		return newParquetWriter(w), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// Copy writes every user from users to w and closes it, returning how many
+// users were written. Output is incomplete if an error is returned.
+func Copy(w Writer, users iter.Seq2[models.UserSummary, error]) (int, error) {
+	count := 0
+	for user, err := range users {
+		if err != nil {
+			return count, err
+		}
+		if err := w.Write(user); err != nil {
+			return count, fmt.Errorf("write user %s: %w", user.UserID, err)
+		}
+		count++
+	}
+	if err := w.Close(); err != nil {
+		return count, fmt.Errorf("close export: %w", err)
+	}
+	return count, nil
+}
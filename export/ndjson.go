@@ -0,0 +1,27 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"tx-processor/models"
+)
+
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buf := bufio.NewWriter(w)
+	return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// Write encodes the user as one line; Encoder terminates each value with a newline
+func (n *ndjsonWriter) Write(user models.UserSummary) error {
+	return n.enc.Encode(user)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.buf.Flush()
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"io"
+	"time"
+	"tx-processor/models"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	// parquetBatch is how many rows are buffered before being handed to the encoder
+	parquetBatch = 1024
+	// parquetRowGroup bounds the rows held in memory before a row group is flushed
+	parquetRowGroup = 128 * 1024
+)
+
+// parquetRecord is the Parquet schema of an exported user
+type parquetRecord struct {
+	UserID        string  `parquet:"user_id"`
+	TotalOrders   int64   `parquet:"total_orders"`
+	TotalSpent    float64 `parquet:"total_spent"`
+	AvgOrderValue float64 `parquet:"avg_order_value"`
+	FirstOrderAt  int64   `parquet:"first_order_at,optional,timestamp(microsecond)"` // Microseconds since the epoch; zero is written as null
+	LastOrderAt   int64   `parquet:"last_order_at,optional,timestamp(microsecond)"`
+	LastUpdated   int64   `parquet:"last_updated,optional,timestamp(microsecond)"`
+}
+
+type parquetWriter struct {
+	w       *parquet.GenericWriter[parquetRecord]
+	batch   []parquetRecord
+	inGroup int
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{
+		w:     parquet.NewGenericWriter[parquetRecord](w, parquet.Compression(&parquet.Snappy)),
+		batch: make([]parquetRecord, 0, parquetBatch),
+	}
+}
+
+func (p *parquetWriter) Write(user models.UserSummary) error {
+	p.batch = append(p.batch, parquetRecord{
+		UserID:        user.UserID,
+		TotalOrders:   int64(user.TotalOrders),
+		TotalSpent:    user.TotalSpent,
+		AvgOrderValue: user.AvgOrderValue,
+		FirstOrderAt:  unixMicro(user.FirstOrderAt),
+		LastOrderAt:   unixMicro(user.LastOrderAt),
+		LastUpdated:   unixMicro(user.LastUpdated),
+	})
+	if len(p.batch) < parquetBatch {
+		return nil
+	}
+	return p.writeBatch()
+}
+
+func (p *parquetWriter) writeBatch() error {
+	if _, err := p.w.Write(p.batch); err != nil {
+		return err
+	}
+	p.inGroup += len(p.batch)
+	p.batch = p.batch[:0]
+
+	if p.inGroup >= parquetRowGroup {
+		p.inGroup = 0
+		return p.w.Flush()
+	}
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	if len(p.batch) > 0 {
+		if err := p.writeBatch(); err != nil {
+			return err
+		}
+	}
+	return p.w.Close()
+}
+
+func unixMicro(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.UnixMicro()
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"tx-processor/buildinfo"
+	"tx-processor/cache"
+	"tx-processor/cache/instrumented"
+	"tx-processor/db"
+	"tx-processor/logger"
+	"tx-processor/models"
+	"tx-processor/services"
+)
+
+// cacheStats is every cache tier's counters and the background fill counters
+type cacheStats struct {
+	Tiers []instrumented.Snapshot `json:"tiers"`
+	Fills services.FillStats      `json:"fills"`
+}
+
+func (h *Handler) snapshotCacheStats() cacheStats {
+	tiers := make([]instrumented.Snapshot, len(h.cacheStats))
+	for i, stats := range h.cacheStats {
+		tiers[i] = stats.Snapshot()
+	}
+	return cacheStats{Tiers: tiers, Fills: h.analyticsService.CacheFillStats()}
+}
+
+func (h *Handler) v1CacheStatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithTrace(r.Context(), h.logger)
+
+		if err := writeData(w, http.StatusOK, h.snapshotCacheStats(), ""); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) cacheStatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithTrace(r.Context(), h.logger)
+
+		if err := writeJSONResponse(w, http.StatusOK, h.snapshotCacheStats()); err != nil {
+			log.Error("failed to write response", "error", err)
+			writeErrorResponse(w, http.StatusInternalServerError, "failed to encode response")
+		}
+	}
+}
+
+// v1InvalidateUserCacheHandler drops one user's cached analytics, so the next
+// read goes to the database
+func (h *Handler) v1InvalidateUserCacheHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+		userID := r.PathValue("id")
+
+		err := h.analyticsService.InvalidateUserCache(ctx, userID)
+		switch {
+		case errors.Is(err, cache.ErrPublish):
+			log.Warn("user cache invalidated without telling other processes", "user_id", userID, "error", err)
+		case err != nil:
+			log.Error("failed to invalidate user cache", "user_id", userID, "error", err)
+			writeServiceError(w, ctx, "failed to invalidate user cache")
+			return
+		default:
+			log.Info("user cache invalidated", "user_id", userID)
+		}
+
+		result := models.CacheInvalidation{UserID: userID, Invalidated: 1}
+		if err := writeData(w, http.StatusOK, result, "Invalidated cached analytics for "+userID); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+// v1InvalidateCacheHandler drops cached analytics for users whose IDs start
+// with the prefix parameter, or for every user with all=true. One of the two
+// is required, so a bare DELETE can't empty the cache by accident.
+func (h *Handler) v1InvalidateCacheHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+		params := r.URL.Query()
+
+		prefix := params.Get("prefix")
+		all, err := strconv.ParseBool(cmp.Or(params.Get("all"), "false"))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "all must be true or false")
+			return
+		}
+		if (prefix == "") == !all {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "give either a non-empty prefix or all=true")
+			return
+		}
+
+		deleted, err := h.analyticsService.InvalidateCachePrefix(ctx, prefix)
+		switch {
+		case errors.Is(err, cache.ErrPublish):
+			log.Warn("cache invalidated without telling other processes", "prefix", prefix, "all", all, "invalidated", deleted, "error", err)
+		case err != nil:
+			log.Error("failed to invalidate cache", "prefix", prefix, "all", all, "invalidated", deleted, "error", err)
+			writeServiceError(w, ctx, "failed to invalidate cache")
+			return
+		default:
+			log.Info("cache invalidated", "prefix", prefix, "all", all, "invalidated", deleted)
+		}
+
+		result := models.CacheInvalidation{Prefix: prefix, All: all, Invalidated: deleted}
+		if err := writeData(w, http.StatusOK, result, fmt.Sprintf("Invalidated %d cached users", deleted)); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+// v1InfoHandler describes the running build and its configuration
+func (h *Handler) v1InfoHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithTrace(r.Context(), h.logger)
+
+		info := models.ServerInfo{
+			Build:         buildinfo.Get(),
+			StartedAt:     h.started.UTC(),
+			UptimeSeconds: time.Since(h.started).Seconds(),
+			SchemaVersion: db.SchemaVersion,
+			Config:        h.settings().Values(),
+		}
+		if err := writeData(w, http.StatusOK, info, ""); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
+	"tx-processor/logger"
+	"tx-processor/models"
 )
 
 func (h *Handler) totalOrdersHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
 
 		userID := r.URL.Query().Get("user_id")
 		if userID == "" {
@@ -18,7 +22,7 @@ func (h *Handler) totalOrdersHandler() http.HandlerFunc {
 
 		analytics, err := h.analyticsService.GetUserAnalytics(ctx, userID)
 		if err != nil {
-			h.logger.Error("failed to get user analytics", "user_id", userID, "error", err)
+			log.Error("failed to get user analytics", "user_id", userID, "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "failed to get user analytics")
 			return
 		}
@@ -34,7 +38,7 @@ func (h *Handler) totalOrdersHandler() http.HandlerFunc {
 		}
 
 		if err := writeJSONResponse(w, http.StatusOK, response); err != nil {
-			h.logger.Error("failed to write response", "error", err)
+			log.Error("failed to write response", "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "failed to encode response")
 		}
 	}
@@ -43,6 +47,7 @@ func (h *Handler) totalOrdersHandler() http.HandlerFunc {
 func (h *Handler) totalSpendingsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
 
 		userID := r.URL.Query().Get("user_id")
 		if userID == "" {
@@ -52,7 +57,7 @@ func (h *Handler) totalSpendingsHandler() http.HandlerFunc {
 
 		analytics, err := h.analyticsService.GetUserAnalytics(ctx, userID)
 		if err != nil {
-			h.logger.Error("failed to get user analytics", "user_id", userID, "error", err)
+			log.Error("failed to get user analytics", "user_id", userID, "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "failed to get user analytics")
 			return
 		}
@@ -68,7 +73,7 @@ func (h *Handler) totalSpendingsHandler() http.HandlerFunc {
 		}
 
 		if err := writeJSONResponse(w, http.StatusOK, response); err != nil {
-			h.logger.Error("failed to write response", "error", err)
+			log.Error("failed to write response", "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "failed to encode response")
 		}
 	}
@@ -77,46 +82,61 @@ func (h *Handler) totalSpendingsHandler() http.HandlerFunc {
 func (h *Handler) topUsersHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
 
-		limitStr := r.URL.Query().Get("limit")
-		limit := 10 // default limit
-		if limitStr != "" {
-			if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-				limit = parsedLimit
-			}
+		query, err := parseUserQuery(r, 1000)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
 		}
 
-		users, err := h.analyticsService.GetTopUsers(ctx, limit)
+		page, err := h.analyticsService.GetTopUsers(ctx, query)
 		if err != nil {
-			h.logger.Error("failed to get top users", "limit", limit, "error", err)
+			log.Error("failed to get top users", "limit", query.Limit, "sort", query.Sort, "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "failed to get top users")
 			return
 		}
+		data := topUsersData(query, page)
 
-		response := struct {
-			Users   []interface{} `json:"users"`
-			Count   int           `json:"count"`
-			Message string        `json:"message"`
-		}{
-			Users:   make([]interface{}, len(users)),
-			Count:   len(users),
-			Message: fmt.Sprintf("Retrieved top %d users by orders", len(users)),
+		// Optional rank lookup for a single user alongside the list
+		var userRank *models.UserRank
+		if userID := r.URL.Query().Get("user_id"); userID != "" {
+			if data.By == "" {
+				writeErrorResponse(w, http.StatusBadRequest, "user_id needs sort orders or spend")
+				return
+			}
+			userRank, err = h.analyticsService.GetUserRank(ctx, data.By, userID)
+			if err != nil {
+				log.Error("failed to get user rank", "user_id", userID, "by", data.By, "error", err)
+				writeErrorResponse(w, http.StatusInternalServerError, "failed to get user rank")
+				return
+			}
+			if userRank == nil {
+				writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("user %s is not ranked", userID))
+				return
+			}
 		}
 
-		for i, user := range users {
-			response.Users[i] = struct {
-				UserID      string  `json:"user_id"`
-				TotalOrders int     `json:"total_orders"`
-				TotalSpent  float64 `json:"total_spent"`
-			}{
-				UserID:      user.UserID,
-				TotalOrders: user.TotalOrders,
-				TotalSpent:  user.TotalSpent,
-			}
+		response := struct {
+			Users      []models.UserSummary `json:"users"`
+			Count      int                  `json:"count"`
+			By         models.RankBy        `json:"by,omitempty"`
+			Sort       models.SortKey       `json:"sort"`
+			NextCursor string               `json:"next_cursor,omitempty"`
+			UserRank   *models.UserRank     `json:"user_rank,omitempty"`
+			Message    string               `json:"message"`
+		}{
+			Users:      data.Users,
+			Count:      data.Count,
+			By:         data.By,
+			Sort:       data.Sort,
+			NextCursor: data.NextCursor,
+			UserRank:   userRank,
+			Message:    fmt.Sprintf("Retrieved top %d users by %s", data.Count, query.Sort),
 		}
 
 		if err := writeJSONResponse(w, http.StatusOK, response); err != nil {
-			h.logger.Error("failed to write response", "error", err)
+			log.Error("failed to write response", "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "failed to encode response")
 		}
 	}
@@ -125,10 +145,11 @@ func (h *Handler) topUsersHandler() http.HandlerFunc {
 func (h *Handler) anomaliesHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
 
 		anomalies, err := h.analyticsService.DetectAnomalies(ctx)
 		if err != nil {
-			h.logger.Error("failed to detect anomalies", "error", err)
+			log.Error("failed to detect anomalies", "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "failed to detect anomalies")
 			return
 		}
@@ -160,7 +181,131 @@ func (h *Handler) anomaliesHandler() http.HandlerFunc {
 		}
 
 		if err := writeJSONResponse(w, http.StatusOK, response); err != nil {
-			h.logger.Error("failed to write response", "error", err)
+			log.Error("failed to write response", "error", err)
+			writeErrorResponse(w, http.StatusInternalServerError, "failed to encode response")
+		}
+	}
+}
+
+func (h *Handler) velocityHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+
+		userID := r.URL.Query().Get("user_id")
+		if userID == "" {
+			writeErrorResponse(w, http.StatusBadRequest, "user_id parameter is required")
+			return
+		}
+
+		window, err := parseWindow(r, h.settings().AnomalyConfig.VelocityWindow)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		anchor, err := parseAnchor(r)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		velocity, err := h.analyticsService.GetUserVelocity(ctx, userID, window, anchor)
+		if err != nil {
+			log.Error("failed to get user velocity", "user_id", userID, "window", window, "anchor", anchor, "error", err)
+			writeErrorResponse(w, http.StatusInternalServerError, "failed to get user velocity")
+			return
+		}
+
+		response := struct {
+			UserID    string                `json:"user_id"`
+			Window    string                `json:"window"`
+			Anchor    models.VelocityAnchor `json:"anchor"`
+			WindowEnd *time.Time            `json:"window_end"`
+			Orders    int                   `json:"orders"`
+			Spent     float64               `json:"spent"`
+			Message   string                `json:"message"`
+		}{
+			UserID:    userID,
+			Window:    window.String(),
+			Anchor:    anchor,
+			WindowEnd: velocity.WindowEnd,
+			Orders:    velocity.Orders,
+			Spent:     velocity.Spent,
+			Message:   fmt.Sprintf("User %s placed %d orders worth $%.2f within %s", userID, velocity.Orders, velocity.Spent, window),
+		}
+
+		if err := writeJSONResponse(w, http.StatusOK, response); err != nil {
+			log.Error("failed to write response", "error", err)
+			writeErrorResponse(w, http.StatusInternalServerError, "failed to encode response")
+		}
+	}
+}
+
+func (h *Handler) velocityAnomaliesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+		query := r.URL.Query()
+
+		rule := h.settings().AnomalyConfig.VelocityRule()
+		window, err := parseWindow(r, rule.Window)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule.Window = window
+		if err := parseLookback(r, &rule); err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if ordersStr := query.Get("min_orders"); ordersStr != "" {
+			parsed, err := strconv.Atoi(ordersStr)
+			if err != nil || parsed < 0 {
+				writeErrorResponse(w, http.StatusBadRequest, "min_orders must be a non-negative integer")
+				return
+			}
+			rule.OrderThreshold = parsed
+		}
+		if spentStr := query.Get("min_spent"); spentStr != "" {
+			parsed, err := strconv.ParseFloat(spentStr, 64)
+			if err != nil || parsed < 0 {
+				writeErrorResponse(w, http.StatusBadRequest, "min_spent must be a non-negative number")
+				return
+			}
+			rule.SpendThreshold = parsed
+		}
+		if rule.OrderThreshold == 0 && rule.SpendThreshold == 0 {
+			writeErrorResponse(w, http.StatusBadRequest, "min_orders or min_spent must be positive")
+			return
+		}
+
+		anomalies, err := h.analyticsService.DetectVelocityAnomalies(ctx, rule)
+		if err != nil {
+			log.Error("failed to detect velocity anomalies", "window", rule.Window, "error", err)
+			writeErrorResponse(w, http.StatusInternalServerError, "failed to detect velocity anomalies")
+			return
+		}
+
+		response := struct {
+			Window    string                   `json:"window"`
+			Lookback  string                   `json:"lookback"`
+			MinOrders int                      `json:"min_orders"`
+			MinSpent  float64                  `json:"min_spent"`
+			Anomalies []models.VelocityAnomaly `json:"anomalies"`
+			Count     int                      `json:"count"`
+			Message   string                   `json:"message"`
+		}{
+			Window:    rule.Window.String(),
+			Lookback:  rule.ScanPeriod().String(),
+			MinOrders: rule.OrderThreshold,
+			MinSpent:  rule.SpendThreshold,
+			Anomalies: anomalies,
+			Count:     len(anomalies),
+			Message:   fmt.Sprintf("Detected %d users bursting within %s", len(anomalies), rule.Window),
+		}
+
+		if err := writeJSONResponse(w, http.StatusOK, response); err != nil {
+			log.Error("failed to write response", "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "failed to encode response")
 		}
 	}
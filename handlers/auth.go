@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"tx-processor/auth"
+	"tx-processor/models"
+)
+
+// require lets next serve only callers holding scope. The caller is
+// identified by auth.Middleware; with authentication disabled every request
+// is let through.
+func (h *Handler) require(scope auth.Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.cfg.AuthConfig.Enabled {
+			next(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		id := auth.FromContext(ctx)
+		if id == nil {
+			failure := auth.FailureFromContext(ctx)
+			if failure != nil && !errors.Is(failure, auth.ErrInvalidCredentials) {
+				writeAPIError(w, http.StatusServiceUnavailable, models.ErrCodeUnavailable, "authentication is unavailable")
+				return
+			}
+
+			challenge := `Bearer realm="tx-processor"`
+			message := "credentials are required"
+			if failure != nil {
+				challenge += `, error="invalid_token"`
+				message = "credentials were rejected"
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			writeAPIError(w, http.StatusUnauthorized, models.ErrCodeUnauthenticated, message)
+			return
+		}
+
+		if !id.Has(scope) {
+			writeAPIError(w, http.StatusForbidden, models.ErrCodePermissionDenied, fmt.Sprintf("scope %s is required", scope))
+			return
+		}
+		next(w, r)
+	}
+}
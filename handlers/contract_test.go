@@ -0,0 +1,575 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"maps"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tx-processor/api"
+	"tx-processor/cache/instrumented"
+	"tx-processor/cache/memory"
+	"tx-processor/config"
+	"tx-processor/events"
+	"tx-processor/handlers"
+	"tx-processor/health"
+	"tx-processor/jobs"
+	"tx-processor/logger"
+	"tx-processor/metrics"
+	"tx-processor/models"
+	"tx-processor/services"
+)
+
+// contractCase is one call to a documented operation
+type contractCase struct {
+	method string
+	route  string // Path as the spec documents it
+	target string // URL requested, relative to the server
+	body   string
+	status int
+}
+
+// TestContract serves RegisterRoutes as server.New does and checks that every
+// route is documented in api/openapi.json, every documented operation is
+// served, and each response matches the spec in status, content type and body
+func TestContract(t *testing.T) {
+	spec := loadSpec(t)
+	mux, manager := newContractMux(t)
+
+	t.Run("routes", func(t *testing.T) {
+		for _, pattern := range mux.patterns {
+			method, path := splitPattern(pattern)
+			ops, ok := spec.paths[path]
+			switch {
+			case !ok:
+				t.Errorf("route %q is not in the spec", pattern)
+			case method != "" && ops[strings.ToLower(method)] == nil:
+				t.Errorf("route %q: the spec has no %s operation for %s", pattern, method, path)
+			}
+		}
+		for path, ops := range spec.paths {
+			for method := range operations(ops) {
+				if !slices.ContainsFunc(mux.patterns, func(pattern string) bool {
+					m, p := splitPattern(pattern)
+					return p == path && (m == "" || strings.EqualFold(m, method))
+				}) {
+					t.Errorf("%s %s is in the spec but no route serves it", strings.ToUpper(method), path)
+				}
+			}
+		}
+	})
+
+	// Held open so the jobs endpoints have a running job to show and cancel
+	running, err := manager.Start(models.JobIngest, nil, func(ctx context.Context, progress *atomic.Int64) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("start job: %v", err)
+	}
+
+	cases := []contractCase{
+		{"GET", "/v1/users/{id}/analytics", "/v1/users/u1/analytics", "", http.StatusOK},
+		{"GET", "/v1/users/{id}/velocity", "/v1/users/u1/velocity?window=10m", "", http.StatusOK},
+		{"GET", "/v1/users/{id}/velocity", "/v1/users/u1/velocity?window=soon", "", http.StatusBadRequest},
+		{"GET", "/v1/users/{id}/rank", "/v1/users/u1/rank?by=spend", "", http.StatusOK},
+		{"GET", "/v1/users/{id}/rank", "/v1/users/u2/rank", "", http.StatusNotFound},
+		{"GET", "/v1/users/{id}/profile", "/v1/users/u1/profile", "", http.StatusOK},
+		{"GET", "/v1/users/{id}/profile", "/v1/users/u2/profile", "", http.StatusNotFound},
+		{"POST", "/v1/users/profiles", "/v1/users/profiles", `{"user_ids":["u1","u2"]}`, http.StatusOK},
+		{"POST", "/v1/users/profiles", "/v1/users/profiles", `{}`, http.StatusBadRequest},
+		{"GET", "/v1/leaderboard", "/v1/leaderboard?sort=spend&limit=5", "", http.StatusOK},
+		{"GET", "/v1/leaderboard", "/v1/leaderboard?limit=0", "", http.StatusBadRequest},
+		{"GET", "/v1/anomalies", "/v1/anomalies", "", http.StatusOK},
+		{"GET", "/v1/anomalies/velocity", "/v1/anomalies/velocity", "", http.StatusOK},
+		{"GET", "/v1/export/users", "/v1/export/users?format=ndjson", "", http.StatusOK},
+		{"GET", "/v1/export/users", "/v1/export/users?format=csv", "", http.StatusOK},
+		{"GET", "/v1/export/users", "/v1/export/users?format=parquet", "", http.StatusOK},
+		{"GET", "/v1/export/users", "/v1/export/users?format=xml", "", http.StatusBadRequest},
+		{"GET", "/v1/events", "/v1/events?types=leaderboard", "", http.StatusOK},
+		{"GET", "/total_orders", "/total_orders?user_id=u1", "", http.StatusOK},
+		{"GET", "/total_orders", "/total_orders", "", http.StatusBadRequest},
+		{"GET", "/total_spendings", "/total_spendings?user_id=u1", "", http.StatusOK},
+		{"GET", "/top_users", "/top_users?limit=5", "", http.StatusOK},
+		{"GET", "/anomalies", "/anomalies", "", http.StatusOK},
+		{"GET", "/velocity", "/velocity?user_id=u1", "", http.StatusOK},
+		{"GET", "/velocity_anomalies", "/velocity_anomalies", "", http.StatusOK},
+		{"GET", "/healthz", "/healthz", "", http.StatusOK},
+		{"GET", "/readyz", "/readyz", "", http.StatusOK},
+		{"GET", "/health", "/health", "", http.StatusOK},
+		{"GET", "/metrics", "/metrics", "", http.StatusOK},
+		{"GET", "/admin/cache/stats", "/admin/cache/stats", "", http.StatusOK},
+		{"GET", "/v1/admin/cache/stats", "/v1/admin/cache/stats", "", http.StatusOK},
+		{"DELETE", "/v1/admin/cache/users/{id}", "/v1/admin/cache/users/u1", "", http.StatusOK},
+		{"DELETE", "/v1/admin/cache/users", "/v1/admin/cache/users?prefix=u", "", http.StatusOK},
+		{"DELETE", "/v1/admin/cache/users", "/v1/admin/cache/users", "", http.StatusBadRequest},
+		{"GET", "/v1/admin/jobs", "/v1/admin/jobs", "", http.StatusOK},
+		{"GET", "/v1/admin/jobs/{id}", "/v1/admin/jobs/" + running.ID, "", http.StatusOK},
+		{"GET", "/v1/admin/jobs/{id}", "/v1/admin/jobs/missing", "", http.StatusNotFound},
+		{"POST", "/v1/admin/jobs/{id}/cancel", "/v1/admin/jobs/" + running.ID + "/cancel", "", http.StatusOK},
+		{"POST", "/v1/admin/jobs/{id}/cancel", "/v1/admin/jobs/" + running.ID + "/cancel", "", http.StatusConflict},
+		{"POST", "/v1/admin/leaderboard/rebuild", "/v1/admin/leaderboard/rebuild", "", http.StatusAccepted},
+		{"POST", "/v1/admin/retention/prune", "/v1/admin/retention/prune?older_than=720h", "", http.StatusAccepted},
+		{"POST", "/v1/admin/ingest", "/v1/admin/ingest", `{"file":"transactions.jsonl"}`, http.StatusAccepted},
+		{"POST", "/v1/admin/ingest", "/v1/admin/ingest", `{"file":"missing.jsonl"}`, http.StatusNotFound},
+		{"GET", "/v1/admin/info", "/v1/admin/info", "", http.StatusOK},
+		{"GET", "/v1/admin/settings", "/v1/admin/settings", "", http.StatusOK},
+		{"PATCH", "/v1/admin/settings", "/v1/admin/settings", `{"settings":{"LOG_LEVEL":"debug"}}`, http.StatusOK},
+		{"POST", "/v1/admin/settings/reload", "/v1/admin/settings/reload", "", http.StatusOK},
+		{"GET", "/openapi.json", "/openapi.json", "", http.StatusOK},
+	}
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	called := make(map[string]bool)
+	for _, tc := range cases {
+		called[tc.method+" "+tc.route] = true
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			op := spec.paths[tc.route][strings.ToLower(tc.method)]
+			if op == nil {
+				t.Fatalf("the spec has no %s %s", tc.method, tc.route)
+			}
+			spec.check(t, srv, op.(map[string]any), tc)
+		})
+	}
+
+	for path, ops := range spec.paths {
+		for method := range operations(ops) {
+			if key := strings.ToUpper(method) + " " + path; !called[key] {
+				t.Errorf("%s is documented but not called", key)
+			}
+		}
+	}
+}
+
+// contractMux records the patterns RegisterRoutes adds
+type contractMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *contractMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.HandleFunc(pattern, handler)
+}
+
+// newContractMux registers every route, with each optional dependency present
+func newContractMux(t *testing.T) (*contractMux, *jobs.Manager) {
+	t.Helper()
+	log := logger.NewSlogAdapter(slog.New(slog.DiscardHandler))
+
+	loader := config.RegisterFlags(flag.NewFlagSet("contract", flag.ContinueOnError))
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.IngestConfig.Dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(cfg.IngestConfig.Dir, "transactions.jsonl"), []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := services.NewAnalyticsService(fakeAnalytics{}, memory.NewMemoryAnalyticsCache(100, time.Minute))
+	t.Cleanup(service.Close)
+
+	registry := metrics.NewRegistry()
+	registryHealth := health.NewRegistry(time.Second)
+	registryHealth.Register("fake", health.CheckerFunc(func(context.Context) error { return nil }))
+
+	broker := events.NewBroker(8)
+	t.Cleanup(broker.Close)
+	broker.Publish(context.Background(), models.Event{
+		Type:        models.EventLeaderboard,
+		At:          time.Now(),
+		Leaderboard: &models.LeaderboardChange{By: models.RankByOrders, Users: []models.UserSummary{fakeSummary}},
+	})
+
+	manager := jobs.NewManager(log)
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+
+	handler := handlers.NewHandler(service, cfg, log,
+		handlers.WithCacheStats(instrumented.NewMetrics(registry).Tier("memory")),
+		handlers.WithHealth(registryHealth),
+		handlers.WithEvents(broker),
+		handlers.WithSettings(config.NewStore(loader, cfg, log)),
+		handlers.WithJobs(manager),
+		handlers.WithIngester(fakeIngester{}),
+	)
+
+	mux := &contractMux{ServeMux: http.NewServeMux()}
+	handler.RegisterRoutes(mux)
+	// Mounted beside the API routes, as server.New does
+	mux.HandleFunc("/metrics", metrics.Handler(registry).ServeHTTP)
+	return mux, manager
+}
+
+// splitPattern separates a ServeMux pattern into its method, if any, and path
+func splitPattern(pattern string) (method, path string) {
+	if method, path, ok := strings.Cut(pattern, " "); ok {
+		return method, path
+	}
+	return "", pattern
+}
+
+// operations yields the methods a spec path documents
+func operations(ops map[string]any) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for method := range ops {
+			if method == "parameters" || strings.HasPrefix(method, "x-") {
+				continue
+			}
+			if !yield(method) {
+				return
+			}
+		}
+	}
+}
+
+// spec is the decoded OpenAPI document
+type spec struct {
+	doc   map[string]any
+	paths map[string]map[string]any
+}
+
+func loadSpec(t *testing.T) spec {
+	t.Helper()
+	var s spec
+	if err := json.Unmarshal(api.Spec, &s.doc); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	s.paths = make(map[string]map[string]any)
+	for path, ops := range s.doc["paths"].(map[string]any) {
+		s.paths[path] = ops.(map[string]any)
+	}
+	return s
+}
+
+// check calls tc's operation and compares the response with what op documents
+func (s spec) check(t *testing.T, srv *httptest.Server, op map[string]any, tc contractCase) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var body io.Reader
+	if tc.body != "" {
+		body = strings.NewReader(tc.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, tc.method, srv.URL+tc.target, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != tc.status {
+		data, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status %d, want %d: %s", resp.StatusCode, tc.status, data)
+	}
+	documented, ok := op["responses"].(map[string]any)[strconv.Itoa(resp.StatusCode)]
+	if !ok {
+		t.Fatalf("status %d is not documented", resp.StatusCode)
+	}
+	content, _ := s.resolve(documented.(map[string]any))["content"].(map[string]any)
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("content type %q: %v", resp.Header.Get("Content-Type"), err)
+	}
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		t.Fatalf("content type %s is not documented for %d; want one of %v", mediaType, resp.StatusCode, slices.Sorted(maps.Keys(content)))
+	}
+	schema, _ := media["schema"].(map[string]any)
+
+	switch mediaType {
+	case "text/event-stream":
+		// The stream stays open; its headers are the contract
+		return
+	case "application/json":
+		var value any
+		if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		for _, problem := range s.validate(schema, value, "body") {
+			t.Error(problem)
+		}
+	case "application/x-ndjson":
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var value any
+			if err := json.Unmarshal([]byte(line), &value); err != nil {
+				t.Fatalf("decode line %d: %v", i+1, err)
+			}
+			for _, problem := range s.validate(schema, value, fmt.Sprintf("line %d", i+1)) {
+				t.Error(problem)
+			}
+		}
+	default:
+		if data, err := io.ReadAll(resp.Body); err != nil || len(data) == 0 {
+			t.Errorf("empty %s body: %v", mediaType, err)
+		}
+	}
+}
+
+// resolve follows node's $ref, if it has one, within the document
+func (s spec) resolve(node map[string]any) map[string]any {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		var target any = s.doc
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			target = target.(map[string]any)[part]
+		}
+		node = target.(map[string]any)
+	}
+}
+
+// flatten resolves schema and merges the members of any allOf into it
+func (s spec) flatten(schema map[string]any) map[string]any {
+	schema = s.resolve(schema)
+	all, ok := schema["allOf"].([]any)
+	if !ok {
+		return schema
+	}
+	flat := make(map[string]any)
+	properties := make(map[string]any)
+	var required []any
+	merge := func(part map[string]any) {
+		for key, value := range part {
+			switch key {
+			case "allOf":
+			case "properties":
+				maps.Copy(properties, value.(map[string]any))
+			case "required":
+				required = append(required, value.([]any)...)
+			default:
+				flat[key] = value
+			}
+		}
+	}
+	for _, member := range all {
+		merge(s.flatten(member.(map[string]any)))
+	}
+	merge(schema)
+	if len(properties) > 0 {
+		flat["properties"] = properties
+	}
+	if len(required) > 0 {
+		flat["required"] = required
+	}
+	return flat
+}
+
+// validate reports where value departs from schema. Objects that list their
+// properties may only hold those, so fields added without a spec change are caught.
+func (s spec) validate(schema map[string]any, value any, at string) []string {
+	if schema == nil {
+		return nil
+	}
+	schema = s.flatten(schema)
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{at + ": null is not allowed"}
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		return []string{fmt.Sprintf("%s: %v is not one of %v", at, value, enum)}
+	}
+
+	kind, _ := schema["type"].(string)
+	if kind == "" && schema["properties"] != nil {
+		kind = "object"
+	}
+	var problems []string
+	switch kind {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not an object", at, value)}
+		}
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: %s is required", at, name))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range slices.Sorted(maps.Keys(obj)) {
+			if property, ok := properties[name]; ok {
+				problems = append(problems, s.validate(property.(map[string]any), obj[name], at+"."+name)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case map[string]any:
+				problems = append(problems, s.validate(extra, obj[name], at+"."+name)...)
+			case bool:
+				if !extra {
+					problems = append(problems, fmt.Sprintf("%s: %s is not allowed", at, name))
+				}
+			default:
+				// Objects that list no properties are free-form
+				if properties != nil {
+					problems = append(problems, fmt.Sprintf("%s: %s is not documented", at, name))
+				}
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not an array", at, value)}
+		}
+		itemSchema, _ := schema["items"].(map[string]any)
+		for i, item := range items {
+			problems = append(problems, s.validate(itemSchema, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not a string", at, value)}
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", at, str))
+			}
+		}
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not a number", at, value)}
+		}
+		if kind == "integer" && num != math.Trunc(num) {
+			problems = append(problems, fmt.Sprintf("%s: %v is not an integer", at, num))
+		}
+		if minimum, ok := schema["minimum"].(float64); ok && num < minimum {
+			problems = append(problems, fmt.Sprintf("%s: %v is below the minimum %v", at, num, minimum))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: %T is not a boolean", at, value)}
+		}
+	}
+	return problems
+}
+
+var (
+	fakeFirstOrder = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	fakeLastOrder  = time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	fakeUser = models.UserAnalytics{
+		UserID:       "u1",
+		TotalOrders:  4,
+		TotalSpent:   100,
+		FirstOrderAt: &fakeFirstOrder,
+		LastOrderAt:  &fakeLastOrder,
+	}
+	fakeSummary = models.UserSummary{UserAnalytics: fakeUser, AvgOrderValue: 25, LastUpdated: &fakeLastOrder}
+)
+
+// fakeAnalytics stands in for the database, knowing only user u1
+type fakeAnalytics struct{}
+
+func (fakeAnalytics) UpdateAnalytics(ctx context.Context, updates map[string]*models.UserAnalytics, activity []models.ActivityBucket) error {
+	return nil
+}
+
+func (fakeAnalytics) UserAnalytics(ctx context.Context, userID string) (*models.UserAnalytics, error) {
+	if userID != fakeUser.UserID {
+		return nil, models.ErrUserNotFound
+	}
+	user := fakeUser
+	return &user, nil
+}
+
+func (fakeAnalytics) TopUsers(ctx context.Context, query models.UserQuery) (*models.UserPage, error) {
+	return &models.UserPage{Users: []models.UserSummary{fakeSummary}}, nil
+}
+
+func (fakeAnalytics) UserRank(ctx context.Context, by models.RankBy, userID string) (*models.UserRank, error) {
+	if userID != fakeUser.UserID {
+		return nil, nil
+	}
+	return &models.UserRank{UserID: userID, By: by, Rank: 1, Score: fakeUser.TotalSpent}, nil
+}
+
+func (fakeAnalytics) UserProfiles(ctx context.Context, userIDs []string) ([]models.UserProfile, error) {
+	var profiles []models.UserProfile
+	if slices.Contains(userIDs, fakeUser.UserID) {
+		profiles = append(profiles, models.UserProfile{
+			UserID:        fakeUser.UserID,
+			TotalOrders:   fakeUser.TotalOrders,
+			TotalSpent:    fakeUser.TotalSpent,
+			AvgOrderValue: fakeSummary.AvgOrderValue,
+			FirstOrderAt:  fakeUser.FirstOrderAt,
+			LastOrderAt:   fakeUser.LastOrderAt,
+			LastUpdated:   fakeLastOrder,
+			Rank:          models.ProfileRanks{Orders: 1, Spend: 1},
+			Percentile:    models.ProfilePercentiles{Orders: 100, Spend: 100},
+			Population:    1,
+		})
+	}
+	return profiles, nil
+}
+
+func (fakeAnalytics) AllUsers(ctx context.Context) iter.Seq2[models.UserAnalytics, error] {
+	return func(yield func(models.UserAnalytics, error) bool) {
+		yield(fakeUser, nil)
+	}
+}
+
+func (fakeAnalytics) ExportUsers(ctx context.Context, filter models.UserFilter) iter.Seq2[models.UserSummary, error] {
+	return func(yield func(models.UserSummary, error) bool) {
+		yield(fakeSummary, nil)
+	}
+}
+
+func (fakeAnalytics) UserAnomalies(ctx context.Context) ([]models.AnomalyUser, error) {
+	return []models.AnomalyUser{{UserID: fakeUser.UserID, TotalOrders: fakeUser.TotalOrders, TotalSpent: fakeUser.TotalSpent, SpendingAnomaly: true}}, nil
+}
+
+func (fakeAnalytics) UserVelocity(ctx context.Context, userID string, window time.Duration, anchor models.VelocityAnchor) (*models.UserVelocity, error) {
+	return &models.UserVelocity{UserID: userID, Window: models.Duration(window), Anchor: anchor, WindowEnd: &fakeLastOrder, Orders: 2, Spent: 50}, nil
+}
+
+func (fakeAnalytics) VelocityAnomalies(ctx context.Context, rule models.VelocityRule) ([]models.VelocityAnomaly, error) {
+	return []models.VelocityAnomaly{{UserID: fakeUser.UserID, WindowEnd: fakeLastOrder, Orders: 30, Spent: 50, OrderAnomaly: true}}, nil
+}
+
+func (fakeAnalytics) PruneActivity(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// fakeIngester reads the file it is given and processes nothing
+type fakeIngester struct{}
+
+func (fakeIngester) Ingest(ctx, commitCtx context.Context, r io.Reader, workers, buffer int, read *atomic.Int64) (int, error) {
+	n, err := io.Copy(io.Discard, r)
+	read.Store(n)
+	return 0, err
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tx-processor/logger"
+	"tx-processor/models"
+)
+
+// maxEventUsers bounds the user IDs one stream may filter on
+const maxEventUsers = 1000
+
+// parseEventFilter reads the type, user_id, by and top query parameters of an event stream
+func parseEventFilter(r *http.Request) (models.EventFilter, error) {
+	params := r.URL.Query()
+	var filter models.EventFilter
+
+	for _, value := range params["type"] {
+		for _, name := range strings.Split(value, ",") {
+			eventType := models.EventType(strings.TrimSpace(name))
+			if !eventType.Valid() {
+				return models.EventFilter{}, fmt.Errorf("type must be one of: analytics, leaderboard")
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	for _, value := range params["user_id"] {
+		for _, userID := range strings.Split(value, ",") {
+			if userID = strings.TrimSpace(userID); userID != "" {
+				filter.UserIDs = append(filter.UserIDs, userID)
+			}
+		}
+	}
+	if len(filter.UserIDs) > maxEventUsers {
+		return models.EventFilter{}, fmt.Errorf("user_id may list at most %d users", maxEventUsers)
+	}
+
+	if byStr := params.Get("by"); byStr != "" {
+		by := models.RankBy(byStr)
+		if !by.Valid() {
+			return models.EventFilter{}, fmt.Errorf("by must be one of: orders, spend")
+		}
+		filter.By = by
+	}
+
+	if topStr := params.Get("top"); topStr != "" {
+		top, err := strconv.Atoi(topStr)
+		if err != nil || top <= 0 {
+			return models.EventFilter{}, fmt.Errorf("top must be a positive integer")
+		}
+		filter.Top = top
+	}
+
+	return filter, nil
+}
+
+// v1EventsHandler streams live updates as Server-Sent Events. A client that
+// reads too slowly loses events rather than holding up the broker, and is told
+// how many with a dropped event, at the point in the stream where they were
+// lost, so it can refetch current state.
+func (h *Handler) v1EventsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+
+		if h.events == nil {
+			writeAPIError(w, http.StatusServiceUnavailable, models.ErrCodeUnavailable, "live updates are not enabled")
+			return
+		}
+		filter, err := parseEventFilter(r)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+
+		sub := h.events.Subscribe(filter)
+		defer sub.Close()
+
+		cfg := h.cfg.EventsConfig
+		rc := http.NewResponseController(w)
+		// Each write gets its own deadline in place of any server-wide one
+		write := func(format string, args ...any) error {
+			if err := rc.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout)); err != nil && err != http.ErrNotSupported {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, format, args...); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // Stop nginx buffering the stream
+		w.WriteHeader(http.StatusOK)
+		if err := write("retry: 3000\n\n"); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(cfg.Heartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				if err := write(": heartbeat\n\n"); err != nil {
+					return
+				}
+			case event, ok := <-sub.Events():
+				if !ok {
+					return // Server is shutting down
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					log.Error("failed to encode event", "event_id", event.ID, "error", err)
+					continue
+				}
+				if err := write("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+					log.Info("event stream closed", "error", err)
+					return
+				}
+				// Drops only happen while the buffer is full, so the lost events
+				// all follow the ones already queued; report them once it drains
+				if len(sub.Events()) == 0 {
+					if dropped := sub.TakeDropped(); dropped > 0 {
+						if err := write("event: dropped\ndata: {\"dropped\":%d}\n\n", dropped); err != nil {
+							return
+						}
+					}
+				}
+			}
+		}
+	}
+}
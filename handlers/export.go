@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"tx-processor/export"
+	"tx-processor/logger"
+	"tx-processor/models"
+)
+
+// exportFormat picks the format from the format query parameter, falling back
+// to the Accept header
+func exportFormat(r *http.Request) (models.ExportFormat, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		format := models.ExportFormat(name)
+		if !format.Valid() {
+			return "", fmt.Errorf("format must be one of: %s", strings.Join(formatNames(), ", "))
+		}
+		return format, nil
+	}
+
+	format, ok := export.Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		return "", fmt.Errorf("accept must allow one of: text/csv, application/x-ndjson, application/vnd.apache.parquet")
+	}
+	return format, nil
+}
+
+func formatNames() []string {
+	names := make([]string, len(models.ExportFormats))
+	for i, format := range models.ExportFormats {
+		names[i] = string(format)
+	}
+	return names
+}
+
+// v1ExportUsersHandler streams every matching user. Once the body has started
+// a failure can't change the status, so the connection is aborted instead to
+// make sure a truncated export never looks complete.
+func (h *Handler) v1ExportUsersHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+
+		format, err := exportFormat(r)
+		if err != nil {
+			status := http.StatusBadRequest
+			if r.URL.Query().Get("format") == "" {
+				status = http.StatusNotAcceptable
+			}
+			writeAPIError(w, status, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+		filter, err := parseUserFilter(r.URL.Query())
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+
+		// Exports outlast any server-wide write timeout
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+			log.Warn("failed to clear write deadline", "error", err)
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"users.%s\"", format))
+		w.WriteHeader(http.StatusOK)
+
+		writer, err := export.NewWriter(format, w)
+		if err != nil {
+			log.Error("failed to start export", "format", format, "error", err)
+			panic(http.ErrAbortHandler)
+		}
+
+		start := time.Now()
+		count, err := export.Copy(writer, h.analyticsService.ExportUsers(ctx, filter))
+		if err != nil {
+			log.Error("export failed", "format", format, "users", count, "error", err)
+			panic(http.ErrAbortHandler)
+		}
+
+		log.Info("export complete", "format", format, "users", count, "elapsed_sec", time.Since(start).Seconds())
+	}
+}
@@ -1,33 +1,178 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
+	"tx-processor/auth"
+	"tx-processor/cache/instrumented"
 	"tx-processor/config"
+	"tx-processor/events"
+	"tx-processor/health"
+	"tx-processor/jobs"
 	"tx-processor/logger"
+	"tx-processor/middleware"
+	"tx-processor/models"
+	"tx-processor/ratelimit"
 	"tx-processor/services"
 )
 
 type Handler struct {
 	analyticsService *services.AnalyticsService
 	cfg              *config.Config
+	store            *config.Store
 	logger           logger.Logger
+	cacheStats       []*instrumented.Stats
+	health           *health.Registry
+	events           *events.Broker
+	limiter          ratelimit.Limiter
+	jobs             *jobs.Manager
+	ingester         Ingester
+	started          time.Time
 }
 
-func NewHandler(analyticsService *services.AnalyticsService, cfg *config.Config, logger logger.Logger) *Handler {
-	return &Handler{
+// Option configures optional Handler dependencies
+type Option func(*Handler)
+
+// WithCacheStats exposes the given cache tiers on the admin stats endpoint
+func WithCacheStats(stats ...*instrumented.Stats) Option {
+	return func(h *Handler) {
+		h.cacheStats = append(h.cacheStats, stats...)
+	}
+}
+
+// WithHealth serves readiness from the given dependency checks
+func WithHealth(registry *health.Registry) Option {
+	return func(h *Handler) {
+		h.health = registry
+	}
+}
+
+// WithEvents streams live updates from the given broker
+func WithEvents(broker *events.Broker) Option {
+	return func(h *Handler) {
+		h.events = broker
+	}
+}
+
+// WithRateLimiter meters callers with limiter; without it nothing is limited
+func WithRateLimiter(limiter ratelimit.Limiter) Option {
+	return func(h *Handler) {
+		h.limiter = limiter
+	}
+}
+
+// WithSettings reads reloadable settings, such as the default velocity rule,
+// from store rather than the configuration the handler was built with, and
+// serves the admin settings endpoints
+func WithSettings(store *config.Store) Option {
+	return func(h *Handler) {
+		h.store = store
+	}
+}
+
+// WithJobs runs the admin API's long operations, such as leaderboard rebuilds
+// and retention pruning, as jobs on manager
+func WithJobs(manager *jobs.Manager) Option {
+	return func(h *Handler) {
+		h.jobs = manager
+	}
+}
+
+// WithIngester lets the admin API ingest files from INGEST_DIR through
+// ingester; it needs WithJobs too
+func WithIngester(ingester Ingester) Option {
+	return func(h *Handler) {
+		h.ingester = ingester
+	}
+}
+
+func NewHandler(analyticsService *services.AnalyticsService, cfg *config.Config, logger logger.Logger, opts ...Option) *Handler {
+	h := &Handler{
 		analyticsService: analyticsService,
 		cfg:              cfg,
 		logger:           logger,
+		started:          time.Now(),
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
-func (h *Handler) RegisterRoutes(r *http.ServeMux) {
-	r.HandleFunc("/total_orders", h.totalOrdersHandler())
-	r.HandleFunc("/total_spendings", h.totalSpendingsHandler())
-	r.HandleFunc("/top_users", h.topUsersHandler())
-	r.HandleFunc("/anomalies", h.anomaliesHandler())
+// Router is where RegisterRoutes adds the API's routes, such as an *http.ServeMux
+type Router interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+func (h *Handler) RegisterRoutes(r Router) {
+	read, anomalies, admin := auth.ScopeReadAnalytics, auth.ScopeReadAnomalies, auth.ScopeAdmin
+	// Streams end when the client leaves or the server shuts down
+	quick, scan, stream := h.cfg.HTTPConfig.RequestTimeout, h.cfg.HTTPConfig.ScanTimeout, time.Duration(0)
+
+	r.HandleFunc("GET /v1/users/{id}/analytics", h.guard(read, costDefault, quick, h.v1UserAnalyticsHandler()))
+	r.HandleFunc("GET /v1/users/{id}/velocity", h.guard(read, costDefault, quick, h.v1UserVelocityHandler()))
+	r.HandleFunc("GET /v1/users/{id}/rank", h.guard(read, costDefault, quick, h.v1UserRankHandler()))
+	r.HandleFunc("GET /v1/users/{id}/profile", h.guard(read, costDefault, quick, h.v1UserProfileHandler()))
+	r.HandleFunc("POST /v1/users/profiles", h.guard(read, costProfiles, scan, h.v1UserProfilesHandler()))
+	r.HandleFunc("GET /v1/leaderboard", h.guard(read, costDefault, quick, h.v1LeaderboardHandler()))
+	r.HandleFunc("GET /v1/anomalies", h.guard(anomalies, costAnomalies, scan, h.v1AnomaliesHandler()))
+	r.HandleFunc("GET /v1/anomalies/velocity", h.guard(anomalies, costVelocityAnomalies, scan, h.v1VelocityAnomaliesHandler()))
+	r.HandleFunc("GET /v1/export/users", h.guard(read, costExport, stream, h.v1ExportUsersHandler()))
+	r.HandleFunc("GET /v1/events", h.guard(read, costDefault, stream, h.v1EventsHandler()))
+
+	// Legacy flat routes, kept for existing clients
+	r.HandleFunc("/total_orders", deprecated("/v1/users/{id}/analytics", h.guard(read, costDefault, quick, h.totalOrdersHandler())))
+	r.HandleFunc("/total_spendings", deprecated("/v1/users/{id}/analytics", h.guard(read, costDefault, quick, h.totalSpendingsHandler())))
+	r.HandleFunc("/top_users", deprecated("/v1/leaderboard", h.guard(read, costDefault, quick, h.topUsersHandler())))
+	r.HandleFunc("/anomalies", deprecated("/v1/anomalies", h.guard(anomalies, costAnomalies, scan, h.anomaliesHandler())))
+	r.HandleFunc("/velocity", deprecated("/v1/users/{id}/velocity", h.guard(read, costDefault, quick, h.velocityHandler())))
+	r.HandleFunc("/velocity_anomalies", deprecated("/v1/anomalies/velocity", h.guard(anomalies, costVelocityAnomalies, scan, h.velocityAnomaliesHandler())))
+
+	r.HandleFunc("GET /admin/cache/stats", deprecated("/v1/admin/cache/stats", h.guard(admin, costDefault, quick, h.cacheStatsHandler())))
+
+	r.HandleFunc("GET /v1/admin/cache/stats", h.guard(admin, costDefault, quick, h.v1CacheStatsHandler()))
+	if h.store != nil {
+		r.HandleFunc("GET /v1/admin/settings", h.guard(admin, costDefault, quick, h.v1SettingsHandler()))
+		r.HandleFunc("PATCH /v1/admin/settings", h.guard(admin, costDefault, quick, h.v1UpdateSettingsHandler()))
+		r.HandleFunc("POST /v1/admin/settings/reload", h.guard(admin, costDefault, quick, h.v1ReloadSettingsHandler()))
+	}
+	r.HandleFunc("DELETE /v1/admin/cache/users/{id}", h.guard(admin, costDefault, quick, h.v1InvalidateUserCacheHandler()))
+	r.HandleFunc("DELETE /v1/admin/cache/users", h.guard(admin, costDefault, scan, h.v1InvalidateCacheHandler()))
+	r.HandleFunc("GET /v1/admin/info", h.guard(admin, costDefault, quick, h.v1InfoHandler()))
+	if h.jobs != nil {
+		r.HandleFunc("POST /v1/admin/leaderboard/rebuild", h.guard(admin, costDefault, quick, h.v1RebuildLeaderboardHandler()))
+		r.HandleFunc("POST /v1/admin/retention/prune", h.guard(admin, costDefault, quick, h.v1PruneRetentionHandler()))
+		if h.ingester != nil && h.cfg.IngestConfig.Dir != "" {
+			r.HandleFunc("POST /v1/admin/ingest", h.guard(admin, costDefault, quick, h.v1IngestHandler()))
+		}
+		r.HandleFunc("GET /v1/admin/jobs", h.guard(admin, costDefault, quick, h.v1JobsHandler()))
+		r.HandleFunc("GET /v1/admin/jobs/{id}", h.guard(admin, costDefault, quick, h.v1JobHandler()))
+		r.HandleFunc("POST /v1/admin/jobs/{id}/cancel", h.guard(admin, costDefault, quick, h.v1CancelJobHandler()))
+	}
+
+	// Public: probes and the API description
+	r.HandleFunc("/healthz", h.livenessHandler())
+	r.HandleFunc("/readyz", h.readinessHandler())
+	r.HandleFunc("/health", h.readinessHandler())
+	r.HandleFunc("GET /openapi.json", h.openAPIHandler())
+}
+
+// settings returns the configuration in effect for a request
+func (h *Handler) settings() *config.Config {
+	if h.store != nil {
+		return h.store.Current()
+	}
+	return h.cfg
+}
+
+// guard serves next to callers holding scope, charging them cost tokens and
+// giving next timeout to finish; a zero timeout leaves it unbounded
+func (h *Handler) guard(scope auth.Scope, cost int, timeout time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return h.limit(cost, h.require(scope, middleware.Timeout(timeout)(next).ServeHTTP))
 }
 
 func writeJSONResponse[T any](w http.ResponseWriter, status int, data T) error {
@@ -40,9 +185,6 @@ func writeJSONResponse[T any](w http.ResponseWriter, status int, data T) error {
 }
 
 func writeErrorResponse(w http.ResponseWriter, status int, message string) error {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-
 	errResp := struct {
 		Error string `json:"error"`
 	}{
@@ -50,5 +192,34 @@ func writeErrorResponse(w http.ResponseWriter, status int, message string) error
 	}
 
 	return writeJSONResponse(w, status, errResp)
+}
+
+// writeData writes data in the standard v1 response envelope
+func writeData[T any](w http.ResponseWriter, status int, data T, message string) error {
+	return writeJSONResponse(w, status, models.Response[T]{Data: &data, Message: message})
+}
 
+// writeAPIError writes a v1 error envelope
+func writeAPIError(w http.ResponseWriter, status int, code, message string) error {
+	return writeJSONResponse(w, status, models.Response[struct{}]{
+		Error: &models.APIError{Code: code, Message: message},
+	})
+}
+
+// writeServiceError reports a failed service call: 504 when the route's
+// deadline cut it short, 500 otherwise
+func writeServiceError(w http.ResponseWriter, ctx context.Context, message string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return writeAPIError(w, http.StatusGatewayTimeout, models.ErrCodeDeadlineExceeded, message+": deadline exceeded")
+	}
+	return writeAPIError(w, http.StatusInternalServerError, models.ErrCodeInternal, message)
+}
+
+// deprecated marks a legacy route's responses with its v1 successor
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		next(w, r)
+	}
 }
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"tx-processor/health"
+	"tx-processor/logger"
+)
+
+// livenessHandler only reports that the process can serve HTTP; dependency
+// failures must not get a healthy pod restarted.
+func (h *Handler) livenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := struct {
+			Status string `json:"status"`
+		}{
+			Status: health.StatusOK,
+		}
+
+		if err := writeJSONResponse(w, http.StatusOK, response); err != nil {
+			logger.WithTrace(r.Context(), h.logger).Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) readinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+
+		report := health.Report{Status: health.StatusOK, Checks: map[string]health.Result{}}
+		if h.health != nil {
+			report = h.health.Run(ctx)
+		}
+
+		status := http.StatusOK
+		if report.Status != health.StatusOK {
+			status = http.StatusServiceUnavailable
+			log.Warn("readiness check failed", "status", report.Status)
+		}
+
+		if err := writeJSONResponse(w, status, report); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+	"tx-processor/jobs"
+	"tx-processor/logger"
+	"tx-processor/models"
+)
+
+// Ingester reads transaction files; *processor.Processor implements it
+type Ingester interface {
+	Ingest(ctx, commitCtx context.Context, r io.Reader, workers, buffer int, read *atomic.Int64) (int, error)
+}
+
+// v1RebuildLeaderboardHandler repopulates the leaderboard from the database
+// in the background
+func (h *Handler) v1RebuildLeaderboardHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.startJob(w, r, models.JobLeaderboardRebuild, nil, func(ctx context.Context, progress *atomic.Int64) error {
+			count, err := h.analyticsService.RebuildLeaderboard(ctx)
+			progress.Store(int64(count))
+			return err
+		})
+	}
+}
+
+// v1PruneRetentionHandler deletes activity older than the older_than
+// parameter, or the configured retention, in the background
+func (h *Handler) v1PruneRetentionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := h.settings()
+		retention := cfg.RetentionConfig.Activity
+		if olderThan := r.URL.Query().Get("older_than"); olderThan != "" {
+			d, err := time.ParseDuration(olderThan)
+			// Pruning inside the window would hide bursts from velocity checks
+			if err != nil || d < cfg.AnomalyConfig.VelocityWindow {
+				writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument,
+					fmt.Sprintf("older_than must be a duration of at least the velocity window, %s", cfg.AnomalyConfig.VelocityWindow))
+				return
+			}
+			retention = d
+		}
+
+		params := map[string]string{"older_than": retention.String()}
+		h.startJob(w, r, models.JobRetentionPrune, params, func(ctx context.Context, progress *atomic.Int64) error {
+			deleted, err := h.analyticsService.PruneActivity(ctx, retention)
+			progress.Store(deleted)
+			return err
+		})
+	}
+}
+
+// v1IngestHandler processes a transaction file from INGEST_DIR in the
+// background. Cancelling the job stops reading; lines already read are
+// still committed.
+func (h *Handler) v1IngestHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithTrace(r.Context(), h.logger)
+		cfg := h.settings()
+
+		var request struct {
+			File string `json:"file"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.File == "" {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeAPIError(w, http.StatusRequestEntityTooLarge, models.ErrCodePayloadTooLarge, fmt.Sprintf("body must be at most %d bytes", tooLarge.Limit))
+				return
+			}
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "body must be a JSON object naming a file")
+			return
+		}
+
+		// The root keeps callers from reaching outside the ingest directory
+		root, err := os.OpenRoot(cfg.IngestConfig.Dir)
+		if err != nil {
+			log.Error("failed to open ingest directory", "dir", cfg.IngestConfig.Dir, "error", err)
+			writeAPIError(w, http.StatusInternalServerError, models.ErrCodeInternal, "failed to open ingest directory")
+			return
+		}
+		defer root.Close()
+		file, err := root.Open(request.File)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				writeAPIError(w, http.StatusNotFound, models.ErrCodeNotFound, "no file "+request.File+" in the ingest directory")
+				return
+			}
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "cannot open "+request.File+": "+errors.Unwrap(err).Error())
+			return
+		}
+		if info, err := file.Stat(); err != nil || !info.Mode().IsRegular() {
+			file.Close()
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, request.File+" is not a regular file")
+			return
+		}
+
+		params := map[string]string{"file": request.File}
+		started := h.startJob(w, r, models.JobIngest, params, func(ctx context.Context, progress *atomic.Int64) error {
+			defer file.Close()
+			// Batches commit even once the job is cancelled, so none are half-applied
+			_, err := h.ingester.Ingest(ctx, context.WithoutCancel(ctx), file, cfg.IngestConfig.Workers, cfg.IngestConfig.Buffer, progress)
+			return err
+		})
+		if !started {
+			file.Close()
+		}
+	}
+}
+
+func (h *Handler) v1JobsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithTrace(r.Context(), h.logger)
+
+		list := h.jobs.List()
+		if err := writeData(w, http.StatusOK, list, fmt.Sprintf("Listed %d jobs", len(list))); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) v1JobHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithTrace(r.Context(), h.logger)
+		id := r.PathValue("id")
+
+		job, err := h.jobs.Get(id)
+		if err != nil {
+			writeAPIError(w, http.StatusNotFound, models.ErrCodeNotFound, "no job "+id)
+			return
+		}
+		if err := writeData(w, http.StatusOK, job, ""); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+// v1CancelJobHandler stops a running job, answering once it has stopped or
+// the route's deadline passes, whichever is first
+func (h *Handler) v1CancelJobHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+		id := r.PathValue("id")
+
+		job, err := h.jobs.Cancel(ctx, id)
+		switch {
+		case errors.Is(err, jobs.ErrNotFound):
+			writeAPIError(w, http.StatusNotFound, models.ErrCodeNotFound, "no job "+id)
+			return
+		case errors.Is(err, jobs.ErrFinished):
+			writeAPIError(w, http.StatusConflict, models.ErrCodeConflict, fmt.Sprintf("job %s already %s", id, job.Status))
+			return
+		case err != nil:
+			log.Error("failed to cancel job", "job_id", id, "error", err)
+			writeServiceError(w, ctx, "failed to cancel job")
+			return
+		}
+		log.Info("job cancelled", "job_id", id, "status", job.Status)
+
+		if err := writeData(w, http.StatusOK, job, ""); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+// startJob runs fn as a background job and answers 202 with it, reporting
+// whether it started
+func (h *Handler) startJob(w http.ResponseWriter, r *http.Request, kind models.JobKind, params map[string]string, fn jobs.Func) bool {
+	log := logger.WithTrace(r.Context(), h.logger)
+
+	job, err := h.jobs.Start(kind, params, fn)
+	if errors.Is(err, jobs.ErrBusy) {
+		writeAPIError(w, http.StatusConflict, models.ErrCodeConflict, err.Error())
+		return false
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, models.ErrCodeUnavailable, err.Error())
+		return false
+	}
+
+	w.Header().Set("Location", "/v1/admin/jobs/"+job.ID)
+	if err := writeData(w, http.StatusAccepted, job, fmt.Sprintf("Started %s job %s", kind, job.ID)); err != nil {
+		log.Error("failed to write response", "error", err)
+	}
+	return true
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+	"tx-processor/api"
+	"tx-processor/logger"
+)
+
+func (h *Handler) openAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(api.Spec); err != nil {
+			logger.WithTrace(r.Context(), h.logger).Error("failed to write response", "error", err)
+		}
+	}
+}
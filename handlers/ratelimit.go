@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"tx-processor/auth"
+	"tx-processor/logger"
+	"tx-processor/models"
+	"tx-processor/ratelimit"
+)
+
+// Route costs in tokens, roughly in proportion to the database work behind
+// them. Anomaly scans aggregate every user, so they cost the most after
+// exports.
+const (
+	costDefault           = 1
+	costProfiles          = 5
+	costVelocityAnomalies = 5
+	costAnomalies         = 10
+	costExport            = 20
+)
+
+// limit charges the caller cost tokens before next runs, answering 429 once
+// their bucket is empty. Authenticated callers are metered by identity, so
+// limit must run after auth.Middleware; others are metered by client IP.
+func (h *Handler) limit(cost int, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.limiter == nil {
+			next(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		ip := ratelimit.ClientIP(r.RemoteAddr, r.Header.Get("X-Forwarded-For"), h.settings().RateLimitConfig.TrustForwarded)
+		key := ratelimit.Key(auth.FromContext(ctx), ip)
+
+		res, err := h.limiter.Take(ctx, key, cost)
+		if err != nil {
+			// Failing open keeps the API up when the limiter's backend is not
+			logger.WithTrace(ctx, h.logger).Error("rate limiter unavailable", "key", key, "error", err)
+			next(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", res.Limit, ceilSeconds(res.Window)))
+		if !res.Allowed {
+			header.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			writeAPIError(w, http.StatusTooManyRequests, models.ErrCodeRateLimited, "rate limit exceeded")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ceilSeconds formats d in whole seconds, rounding up so clients that wait
+// that long are never early
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
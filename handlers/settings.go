@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"tx-processor/config"
+	"tx-processor/logger"
+	"tx-processor/models"
+)
+
+func (h *Handler) v1SettingsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithTrace(r.Context(), h.logger)
+
+		if err := writeData(w, http.StatusOK, h.runtimeSettings(config.Change{}), ""); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+// v1UpdateSettingsHandler overrides reloadable settings until the next reload
+func (h *Handler) v1UpdateSettingsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+
+		var request struct {
+			Settings map[string]string `json:"settings"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeAPIError(w, http.StatusRequestEntityTooLarge, models.ErrCodePayloadTooLarge, fmt.Sprintf("body must be at most %d bytes", tooLarge.Limit))
+				return
+			}
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "body must be a JSON object with a settings object of strings")
+			return
+		}
+		if len(request.Settings) == 0 {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "settings must name at least one setting")
+			return
+		}
+
+		change, err := h.store.Set(request.Settings)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+		log.Info("settings updated", "settings", change.Changed)
+
+		message := fmt.Sprintf("Changed %d settings", len(change.Changed))
+		if err := writeData(w, http.StatusOK, h.runtimeSettings(change), message); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+// v1ReloadSettingsHandler re-reads the config file and environment, as SIGHUP does
+func (h *Handler) v1ReloadSettingsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithTrace(r.Context(), h.logger)
+
+		change, err := h.store.Reload()
+		if err != nil {
+			// The running settings stay in effect
+			writeAPIError(w, http.StatusInternalServerError, models.ErrCodeInternal, "settings not reloaded: "+err.Error())
+			return
+		}
+
+		message := fmt.Sprintf("Changed %d settings", len(change.Changed))
+		if err := writeData(w, http.StatusOK, h.runtimeSettings(change), message); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) runtimeSettings(change config.Change) models.RuntimeSettings {
+	return models.RuntimeSettings{
+		Settings:        h.store.Settings(),
+		Changed:         change.Changed,
+		RestartRequired: change.RestartRequired,
+	}
+}
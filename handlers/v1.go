@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"tx-processor/logger"
+	"tx-processor/models"
+	"tx-processor/services"
+)
+
+// parseWindow reads the window query parameter, falling back to def
+func parseWindow(r *http.Request, def time.Duration) (time.Duration, error) {
+	windowStr := r.URL.Query().Get("window")
+	if windowStr == "" {
+		return def, nil
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window < models.ActivityBucketSize {
+		return 0, fmt.Errorf("window must be a duration of at least %s", models.ActivityBucketSize)
+	}
+	return window, nil
+}
+
+// parseAnchor reads the anchor query parameter, defaulting to now
+func parseAnchor(r *http.Request) (models.VelocityAnchor, error) {
+	anchor := models.VelocityAnchor(r.URL.Query().Get("anchor"))
+	if anchor == "" {
+		return models.AnchorNow, nil
+	}
+	if !anchor.Valid() {
+		return "", fmt.Errorf("anchor must be one of: now, latest")
+	}
+	return anchor, nil
+}
+
+// parseLookback reads the lookback query parameter into rule, which must
+// cover at least one window
+func parseLookback(r *http.Request, rule *models.VelocityRule) error {
+	lookbackStr := r.URL.Query().Get("lookback")
+	if lookbackStr == "" {
+		return nil
+	}
+	lookback, err := time.ParseDuration(lookbackStr)
+	if err != nil || lookback < rule.Window {
+		return fmt.Errorf("lookback must be a duration of at least the window, %s", rule.Window)
+	}
+	rule.Lookback = lookback
+	return nil
+}
+
+// parseRankBy reads the by query parameter, defaulting to orders
+func parseRankBy(r *http.Request) (models.RankBy, error) {
+	by := models.RankBy(r.URL.Query().Get("by"))
+	if by == "" {
+		return models.RankByOrders, nil
+	}
+	if !by.Valid() {
+		return "", fmt.Errorf("by must be one of: orders, spend")
+	}
+	return by, nil
+}
+
+// parseLimit reads the limit query parameter, rejecting anything outside 1..max
+func parseLimit(r *http.Request, def, max int) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 || limit > max {
+		return 0, fmt.Errorf("limit must be an integer between 1 and %d", max)
+	}
+	return limit, nil
+}
+
+// parseUserQuery reads the sort, cursor and filter parameters of a user listing.
+// by is accepted in place of sort for existing clients.
+func parseUserQuery(r *http.Request, maxLimit int) (models.UserQuery, error) {
+	params := r.URL.Query()
+
+	limit, err := parseLimit(r, 10, maxLimit)
+	if err != nil {
+		return models.UserQuery{}, err
+	}
+
+	sort := models.SortKey(params.Get("sort"))
+	if sort == "" {
+		sort = models.SortKey(params.Get("by"))
+	}
+	if sort == "" {
+		sort = models.SortByOrders
+	}
+	if !sort.Valid() {
+		return models.UserQuery{}, fmt.Errorf("sort must be one of: orders, spend, avg_order_value, last_updated")
+	}
+	query := models.UserQuery{Sort: sort, Limit: limit}
+
+	if token := params.Get("cursor"); token != "" {
+		cursor, err := models.ParseCursor(token)
+		if err != nil {
+			return models.UserQuery{}, fmt.Errorf("cursor is malformed")
+		}
+		if cursor.Sort != sort {
+			return models.UserQuery{}, fmt.Errorf("cursor was issued for sort %s, not %s", cursor.Sort, sort)
+		}
+		query.After = cursor
+	}
+
+	if query.Filter, err = parseUserFilter(params); err != nil {
+		return models.UserQuery{}, err
+	}
+
+	return query, nil
+}
+
+// parseUserFilter reads the bounds that narrow a user listing or export
+func parseUserFilter(params url.Values) (models.UserFilter, error) {
+	var (
+		filter models.UserFilter
+		err    error
+	)
+	if filter.MinOrders, err = parseOptionalInt(params, "min_orders"); err != nil {
+		return models.UserFilter{}, err
+	}
+	if filter.MaxOrders, err = parseOptionalInt(params, "max_orders"); err != nil {
+		return models.UserFilter{}, err
+	}
+	if filter.MinSpent, err = parseOptionalFloat(params, "min_spent"); err != nil {
+		return models.UserFilter{}, err
+	}
+	if filter.MaxSpent, err = parseOptionalFloat(params, "max_spent"); err != nil {
+		return models.UserFilter{}, err
+	}
+	if filter.MinOrders != nil && filter.MaxOrders != nil && *filter.MinOrders > *filter.MaxOrders {
+		return models.UserFilter{}, fmt.Errorf("min_orders must not exceed max_orders")
+	}
+	if filter.MinSpent != nil && filter.MaxSpent != nil && *filter.MinSpent > *filter.MaxSpent {
+		return models.UserFilter{}, fmt.Errorf("min_spent must not exceed max_spent")
+	}
+
+	if sinceStr := params.Get("updated_since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			return models.UserFilter{}, fmt.Errorf("updated_since must be an RFC 3339 timestamp")
+		}
+		filter.UpdatedSince = &since
+	}
+
+	return filter, nil
+}
+
+// parseOptionalInt reads a non-negative integer query parameter, nil if absent
+func parseOptionalInt(params url.Values, name string) (*int, error) {
+	str := params.Get(name)
+	if str == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(str)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return &value, nil
+}
+
+// parseOptionalFloat reads a non-negative number query parameter, nil if absent
+func parseOptionalFloat(params url.Values, name string) (*float64, error) {
+	str := params.Get(name)
+	if str == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("%s must be a non-negative number", name)
+	}
+	return &value, nil
+}
+
+// topUsersData wraps a page of users for the response
+func topUsersData(query models.UserQuery, page *models.UserPage) models.TopUsers {
+	data := models.TopUsers{Sort: query.Sort, Users: page.Users, Count: len(page.Users)}
+	if data.Users == nil {
+		data.Users = []models.UserSummary{}
+	}
+	if by, ok := query.Sort.RankBy(); ok {
+		data.By = by
+	}
+	if page.Next != nil {
+		data.NextCursor = page.Next.Encode()
+	}
+	return data
+}
+
+func (h *Handler) v1UserAnalyticsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+		userID := r.PathValue("id")
+
+		analytics, err := h.analyticsService.GetUserAnalytics(ctx, userID)
+		if err != nil {
+			log.Error("failed to get user analytics", "user_id", userID, "error", err)
+			writeServiceError(w, ctx, "failed to get user analytics")
+			return
+		}
+
+		if err := writeData(w, http.StatusOK, *analytics, ""); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) v1UserVelocityHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+		userID := r.PathValue("id")
+
+		window, err := parseWindow(r, h.settings().AnomalyConfig.VelocityWindow)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+		anchor, err := parseAnchor(r)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+
+		velocity, err := h.analyticsService.GetUserVelocity(ctx, userID, window, anchor)
+		if err != nil {
+			log.Error("failed to get user velocity", "user_id", userID, "window", window, "anchor", anchor, "error", err)
+			writeServiceError(w, ctx, "failed to get user velocity")
+			return
+		}
+
+		if err := writeData(w, http.StatusOK, *velocity, ""); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) v1UserRankHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+		userID := r.PathValue("id")
+
+		by, err := parseRankBy(r)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+
+		rank, err := h.analyticsService.GetUserRank(ctx, by, userID)
+		if err != nil {
+			log.Error("failed to get user rank", "user_id", userID, "by", by, "error", err)
+			writeServiceError(w, ctx, "failed to get user rank")
+			return
+		}
+		if rank == nil {
+			writeAPIError(w, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("user %s is not ranked", userID))
+			return
+		}
+
+		if err := writeData(w, http.StatusOK, *rank, ""); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) v1UserProfileHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+		userID := r.PathValue("id")
+
+		profile, err := h.analyticsService.GetUserProfile(ctx, userID)
+		if errors.Is(err, services.ErrUserNotFound) {
+			writeAPIError(w, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("user %s has no analytics", userID))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get user profile", "user_id", userID, "error", err)
+			writeServiceError(w, ctx, "failed to get user profile")
+			return
+		}
+
+		if err := writeData(w, http.StatusOK, *profile, ""); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+// maxProfileBatch bounds the users in one batch profile request
+const maxProfileBatch = 100
+
+func (h *Handler) v1UserProfilesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+
+		var request struct {
+			UserIDs []string `json:"user_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeAPIError(w, http.StatusRequestEntityTooLarge, models.ErrCodePayloadTooLarge, fmt.Sprintf("body must be at most %d bytes", tooLarge.Limit))
+				return
+			}
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "body must be a JSON object with a user_ids array")
+			return
+		}
+		if len(request.UserIDs) == 0 || len(request.UserIDs) > maxProfileBatch {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, fmt.Sprintf("user_ids must list between 1 and %d users", maxProfileBatch))
+			return
+		}
+		for _, userID := range request.UserIDs {
+			if userID == "" {
+				writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "user_ids must not contain empty IDs")
+				return
+			}
+		}
+
+		profiles, err := h.analyticsService.GetUserProfiles(ctx, request.UserIDs)
+		if err != nil {
+			log.Error("failed to get user profiles", "users", len(request.UserIDs), "error", err)
+			writeServiceError(w, ctx, "failed to get user profiles")
+			return
+		}
+
+		message := fmt.Sprintf("Found %d of %d users", len(profiles.Profiles), len(profiles.Profiles)+len(profiles.Missing))
+		if err := writeData(w, http.StatusOK, *profiles, message); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) v1LeaderboardHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+
+		query, err := parseUserQuery(r, 1000)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+
+		page, err := h.analyticsService.GetTopUsers(ctx, query)
+		if err != nil {
+			log.Error("failed to get top users", "limit", query.Limit, "sort", query.Sort, "error", err)
+			writeServiceError(w, ctx, "failed to get top users")
+			return
+		}
+
+		data := topUsersData(query, page)
+		if err := writeData(w, http.StatusOK, data, fmt.Sprintf("Retrieved top %d users by %s", data.Count, query.Sort)); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) v1AnomaliesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+
+		anomalies, err := h.analyticsService.DetectAnomalies(ctx)
+		if err != nil {
+			log.Error("failed to detect anomalies", "error", err)
+			writeServiceError(w, ctx, "failed to detect anomalies")
+			return
+		}
+		if anomalies == nil {
+			anomalies = []models.AnomalyUser{}
+		}
+
+		if err := writeData(w, http.StatusOK, anomalies, fmt.Sprintf("Detected %d anomalous users", len(anomalies))); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
+
+func (h *Handler) v1VelocityAnomaliesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logger.WithTrace(ctx, h.logger)
+		query := r.URL.Query()
+
+		rule := h.settings().AnomalyConfig.VelocityRule()
+		window, err := parseWindow(r, rule.Window)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+		rule.Window = window
+		if err := parseLookback(r, &rule); err != nil {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, err.Error())
+			return
+		}
+
+		if ordersStr := query.Get("min_orders"); ordersStr != "" {
+			parsed, err := strconv.Atoi(ordersStr)
+			if err != nil || parsed < 0 {
+				writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "min_orders must be a non-negative integer")
+				return
+			}
+			rule.OrderThreshold = parsed
+		}
+		if spentStr := query.Get("min_spent"); spentStr != "" {
+			parsed, err := strconv.ParseFloat(spentStr, 64)
+			if err != nil || parsed < 0 {
+				writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "min_spent must be a non-negative number")
+				return
+			}
+			rule.SpendThreshold = parsed
+		}
+		if rule.OrderThreshold == 0 && rule.SpendThreshold == 0 {
+			writeAPIError(w, http.StatusBadRequest, models.ErrCodeInvalidArgument, "min_orders or min_spent must be positive")
+			return
+		}
+
+		anomalies, err := h.analyticsService.DetectVelocityAnomalies(ctx, rule)
+		if err != nil {
+			log.Error("failed to detect velocity anomalies", "window", rule.Window, "error", err)
+			writeServiceError(w, ctx, "failed to detect velocity anomalies")
+			return
+		}
+		if anomalies == nil {
+			anomalies = []models.VelocityAnomaly{}
+		}
+
+		message := fmt.Sprintf("Detected %d users bursting within %s", len(anomalies), rule.Window)
+		if err := writeData(w, http.StatusOK, anomalies, message); err != nil {
+			log.Error("failed to write response", "error", err)
+		}
+	}
+}
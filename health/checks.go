@@ -0,0 +1,38 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"tx-processor/db"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// Postgres checks connectivity and that migrations have reached the version this build expects
+func Postgres(database *sqlx.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if err := database.PingContext(ctx); err != nil {
+			return fmt.Errorf("ping: %w", err)
+		}
+
+		version, err := db.CurrentVersion(ctx, database)
+		if err != nil {
+			return err
+		}
+		if version < db.SchemaVersion {
+			return fmt.Errorf("schema version %d, want %d", version, db.SchemaVersion)
+		}
+		return nil
+	})
+}
+
+// Redis checks the server answers PING
+func Redis(client *redis.Client) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if err := client.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("ping: %w", err)
+		}
+		return nil
+	})
+}
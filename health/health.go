@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDraining = "draining"
+)
+
+// Checker reports whether a dependency is usable
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of one dependency check
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of all dependency checks
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Registry runs registered dependency checks for readiness and tracks whether
+// the process is draining for shutdown.
+type Registry struct {
+	mu       sync.RWMutex
+	checks   []namedChecker
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a check that must pass for the process to be ready
+func (r *Registry) Register(name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, namedChecker{name: name, checker: checker})
+}
+
+// SetDraining marks the process as shutting down so readiness fails while
+// in-flight requests finish.
+func (r *Registry) SetDraining(draining bool) {
+	r.draining.Store(draining)
+}
+
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Run executes every check concurrently, each bounded by the registry timeout
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]namedChecker(nil), r.checks...)
+	r.mu.RUnlock()
+
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]Result, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := r.runCheck(ctx, c.checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Draining wins so load balancers stop routing here even if dependencies are fine
+	if r.Draining() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (r *Registry) runCheck(ctx context.Context, checker Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checker.Check(ctx)
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
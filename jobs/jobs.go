@@ -0,0 +1,217 @@
+// Package jobs runs the admin API's long operations, such as ingesting a file
+// or rebuilding the leaderboard, in the background. Callers get a job to poll
+// and may cancel it; at most one job of each kind runs at a time, so two
+// rebuilds can't race each other.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+	"tx-processor/logger"
+	"tx-processor/models"
+)
+
+var (
+	// ErrNotFound is returned for job IDs the manager doesn't know
+	ErrNotFound = errors.New("job not found")
+	// ErrBusy is returned when a job of the same kind is already running
+	ErrBusy = errors.New("a job of this kind is already running")
+	// ErrFinished is returned when cancelling a job that has already ended
+	ErrFinished = errors.New("job has already finished")
+)
+
+// retainFinished is how many finished jobs are kept for inspection
+const retainFinished = 100
+
+// Func does a job's work until ctx is cancelled, adding to progress as it goes
+type Func func(ctx context.Context, progress *atomic.Int64) error
+
+type job struct {
+	models.Job
+	progress atomic.Int64
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+type Manager struct {
+	logger logger.Logger
+
+	mu       sync.Mutex
+	jobs     map[string]*job
+	order    []string // Job IDs, oldest first
+	stopping bool
+	wg       sync.WaitGroup
+}
+
+func NewManager(logger logger.Logger) *Manager {
+	return &Manager{
+		logger: logger,
+		jobs:   make(map[string]*job),
+	}
+}
+
+// Start runs fn in the background as a job of kind, described by params
+func (m *Manager) Start(kind models.JobKind, params map[string]string, fn Func) (models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopping {
+		return models.Job{}, fmt.Errorf("jobs: shutting down")
+	}
+	for _, j := range m.jobs {
+		if j.Kind == kind && j.Status == models.JobRunning {
+			return models.Job{}, fmt.Errorf("%w: %s", ErrBusy, j.ID)
+		}
+	}
+
+	// Jobs outlive the request that starts them
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		Job: models.Job{
+			ID:        rand.Text(),
+			Kind:      kind,
+			Status:    models.JobRunning,
+			Params:    maps.Clone(params),
+			StartedAt: time.Now().UTC(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	m.jobs[j.ID] = j
+	m.order = append(m.order, j.ID)
+	m.prune()
+
+	log := m.logger.With("job_id", j.ID, "kind", kind)
+	log.Info("job started", "params", params)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(j.done)
+		defer cancel()
+
+		err := fn(ctx, &j.progress)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		finished := time.Now().UTC()
+		j.FinishedAt = &finished
+		switch {
+		case ctx.Err() != nil:
+			j.Status = models.JobCancelled
+		case err != nil:
+			j.Status = models.JobFailed
+			j.Error = err.Error()
+		default:
+			j.Status = models.JobSucceeded
+		}
+		log.Info("job finished", "status", j.Status, "progress", j.progress.Load(), "error", err,
+			"elapsed_sec", finished.Sub(j.StartedAt).Seconds())
+	}()
+
+	return m.snapshot(j), nil
+}
+
+// Get returns the job with id
+func (m *Manager) Get(id string) (models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return models.Job{}, ErrNotFound
+	}
+	return m.snapshot(j), nil
+}
+
+// List returns running jobs and recently finished ones, newest first
+func (m *Manager) List() []models.Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]models.Job, 0, len(m.order))
+	for _, id := range slices.Backward(m.order) {
+		list = append(list, m.snapshot(m.jobs[id]))
+	}
+	return list
+}
+
+// Cancel stops a running job and waits for it to wind down, as long as ctx allows
+func (m *Manager) Cancel(ctx context.Context, id string) (models.Job, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return models.Job{}, ErrNotFound
+	}
+	if j.Status != models.JobRunning {
+		finished := m.snapshot(j)
+		m.mu.Unlock()
+		return finished, ErrFinished
+	}
+	m.mu.Unlock()
+
+	j.cancel()
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+	}
+	return m.Get(id)
+}
+
+// Shutdown cancels running jobs and waits for them to stop until ctx expires
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopping = true
+	for _, j := range m.jobs {
+		j.cancel()
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// snapshot copies a job for callers. Callers hold m.mu.
+func (m *Manager) snapshot(j *job) models.Job {
+	out := j.Job
+	out.Params = maps.Clone(j.Params)
+	out.Progress = j.progress.Load()
+	return out
+}
+
+// prune forgets the oldest finished jobs past retainFinished. Callers hold m.mu.
+func (m *Manager) prune() {
+	finished := 0
+	for _, id := range m.order {
+		if m.jobs[id].Status != models.JobRunning {
+			finished++
+		}
+	}
+	kept := m.order[:0]
+	for _, id := range m.order {
+		if finished > retainFinished && m.jobs[id].Status != models.JobRunning {
+			delete(m.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	m.order = kept
+}
@@ -0,0 +1,139 @@
+// Package lifecycle starts a process's components in order and, once the
+// root context ends, stops them in reverse: servers drain before the caches,
+// brokers and pools they depend on are closed. Every stop shares one
+// deadline, so a stuck component can't hold up the exit indefinitely.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"tx-processor/logger"
+)
+
+type component struct {
+	name  string
+	start func(context.Context) error
+	stop  func(context.Context) error
+}
+
+type Manager struct {
+	logger      logger.Logger
+	stopTimeout time.Duration
+	components  []component
+
+	failOnce sync.Once
+	failed   chan error
+}
+
+// NewManager returns a manager that allows stopTimeout for all components to
+// stop once shutdown begins
+func NewManager(logger logger.Logger, stopTimeout time.Duration) *Manager {
+	return &Manager{
+		logger:      logger,
+		stopTimeout: stopTimeout,
+		failed:      make(chan error, 1),
+	}
+}
+
+// Add registers a component. start runs in the order components were added
+// and must return once the component is up; stop runs in reverse order and
+// must return by the time its context expires. Either may be nil.
+func (m *Manager) Add(name string, start, stop func(context.Context) error) {
+	m.components = append(m.components, component{name: name, start: start, stop: stop})
+}
+
+// AddCloser registers a resource that only needs closing, such as a
+// connection pool
+func (m *Manager) AddCloser(name string, close func() error) {
+	m.Add(name, nil, func(context.Context) error { return close() })
+}
+
+// Go registers a background loop. fn runs from start until the component's
+// turn to stop, when its context is cancelled and stop waits for it to
+// return. fn returning early with an error shuts the process down.
+func (m *Manager) Go(name string, fn func(ctx context.Context) error) {
+	var (
+		cancel context.CancelFunc
+		done   chan struct{}
+	)
+	start := func(ctx context.Context) error {
+		// Detached from the root context, so the loop runs until its turn to stop
+		ctx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		done = make(chan struct{})
+		go func() {
+			defer close(done)
+			if err := fn(ctx); err != nil && ctx.Err() == nil {
+				m.Fail(fmt.Errorf("%s: %w", name, err))
+			}
+		}()
+		return nil
+	}
+	stop := func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	m.Add(name, start, stop)
+}
+
+// Fail begins shutdown because a component stopped working, such as a server
+// whose listener failed. Run returns the first error passed to Fail.
+func (m *Manager) Fail(err error) {
+	m.failOnce.Do(func() {
+		m.failed <- err
+	})
+}
+
+// Run starts every component, waits until ctx is done or a component fails,
+// then stops them all. It returns the error that caused the shutdown, if it
+// was not ctx ending, joined with any errors from stopping.
+func (m *Manager) Run(ctx context.Context) error {
+	started := 0
+	var cause error
+	for _, c := range m.components {
+		if c.start != nil {
+			if err := c.start(ctx); err != nil {
+				cause = fmt.Errorf("start %s: %w", c.name, err)
+				break
+			}
+		}
+		started++
+	}
+
+	if cause == nil {
+		m.logger.Info("started", "components", started)
+		select {
+		case <-ctx.Done():
+			m.logger.Info("shutting down", "timeout", m.stopTimeout)
+		case cause = <-m.failed:
+			m.logger.Error("shutting down after a component failed", "error", cause, "timeout", m.stopTimeout)
+		}
+	}
+
+	// ctx is usually cancelled by now; stopping gets its own deadline
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.stopTimeout)
+	defer cancel()
+
+	errs := []error{cause}
+	for i := started - 1; i >= 0; i-- {
+		c := m.components[i]
+		if c.stop == nil {
+			continue
+		}
+		start := time.Now()
+		if err := c.stop(stopCtx); err != nil {
+			m.logger.Error("failed to stop component", "component", c.name, "error", err)
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+			continue
+		}
+		m.logger.Info("stopped component", "component", c.name, "elapsed_sec", time.Since(start).Seconds())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// sampler caps how often each warning or error message is written. A message
+// is its level and text, not its attributes, so a bad file's per-line
+// warnings count as one. Info and debug lines, such as access logs, are
+// never sampled.
+type sampler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	windows  map[sampleKey]*sampleWindow
+	now      func() time.Time
+}
+
+type sampleKey struct {
+	level slog.Level
+	msg   string
+}
+
+type sampleWindow struct {
+	start   time.Time
+	written int
+	dropped int // Lines dropped since the message was last written
+}
+
+func newSampler(interval time.Duration, burst int) *sampler {
+	s := &sampler{windows: make(map[sampleKey]*sampleWindow), now: time.Now}
+	s.set(interval, burst)
+	return s
+}
+
+func (s *sampler) set(interval time.Duration, burst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = interval
+	s.burst = max(burst, 1)
+}
+
+// allow reports whether a line of the message may be written and, if so, how
+// many lines of it were dropped before this one
+func (s *sampler) allow(level slog.Level, msg string) (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.interval <= 0 {
+		return true, 0
+	}
+	now := s.now()
+	key := sampleKey{level: level, msg: msg}
+	w, ok := s.windows[key]
+	if !ok || now.Sub(w.start) >= s.interval {
+		if !ok {
+			w = &sampleWindow{}
+			s.windows[key] = w
+		}
+		w.start, w.written = now, 0
+	}
+	if w.written >= s.burst {
+		w.dropped++
+		return false, 0
+	}
+	w.written++
+	dropped := w.dropped
+	w.dropped = 0
+	return true, dropped
+}
+
+// samplingHandler drops lines past the sampler's burst, noting on the next
+// line written how many were dropped
+type samplingHandler struct {
+	next    slog.Handler
+	sampler *sampler
+}
+
+func (h *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn {
+		return h.next.Handle(ctx, r)
+	}
+	ok, dropped := h.sampler.allow(r.Level, r.Message)
+	if !ok {
+		return nil
+	}
+	if dropped > 0 {
+		r = r.Clone()
+		r.AddAttrs(slog.Int("sampled_dropped", dropped))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{next: h.next.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{next: h.next.WithGroup(name), sampler: h.sampler}
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// redacted replaces the value of sensitive attributes
+const redacted = "REDACTED"
+
+// Options shapes the root logger
+type Options struct {
+	Level          slog.Level
+	Format         string        // json or text
+	Redact         []string      // Attribute keys whose values are never written, matched ignoring case
+	SampleInterval time.Duration // Zero writes every warning and error
+	SampleBurst    int           // Lines of one warning or error message written per interval
+}
+
+// Root is the process's root logger. Its level and sampling can change while
+// it runs; loggers derived from it with With follow along.
+type Root struct {
+	*SlogAdapter
+	level   *slog.LevelVar
+	sampler *sampler
+}
+
+// New returns a root logger writing to w
+func New(w io.Writer, opts Options) (*Root, error) {
+	level := new(slog.LevelVar)
+	level.Set(opts.Level)
+
+	redact := make(map[string]bool, len(opts.Redact))
+	for _, key := range opts.Redact {
+		if key = strings.TrimSpace(key); key != "" {
+			redact[strings.ToLower(key)] = true
+		}
+	}
+	handlerOpts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if redact[strings.ToLower(a.Key)] {
+				return slog.String(a.Key, redacted)
+			}
+			return a
+		},
+	}
+
+	var handler slog.Handler
+	switch opts.Format {
+	case "json", "":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	case "text":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	s := newSampler(opts.SampleInterval, opts.SampleBurst)
+	return &Root{
+		SlogAdapter: &SlogAdapter{logger: slog.New(&samplingHandler{next: handler, sampler: s})},
+		level:       level,
+		sampler:     s,
+	}, nil
+}
+
+// Reconfigure applies the level and sampling in opts. Format and redaction
+// are fixed when the logger is created.
+func (r *Root) Reconfigure(opts Options) {
+	r.level.Set(opts.Level)
+	r.sampler.set(opts.SampleInterval, opts.SampleBurst)
+}
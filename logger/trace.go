@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type attrsKey struct{}
+
+// ContextWith returns ctx carrying key-value pairs that WithTrace adds to
+// loggers built from it, such as the caller's identity
+func ContextWith(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]any)
+	return context.WithValue(ctx, attrsKey{}, append(prev[:len(prev):len(prev)], args...))
+}
+
+// WithTrace returns l annotated with the trace and span IDs active in ctx and
+// any pairs attached with ContextWith, or l unchanged when ctx carries neither.
+func WithTrace(ctx context.Context, l Logger) Logger {
+	if attrs, _ := ctx.Value(attrsKey{}).([]any); len(attrs) > 0 {
+		l = l.With(attrs...)
+	}
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return l
+	}
+	return l.With("trace_id", spanCtx.TraceID().String(), "span_id", spanCtx.SpanID().String())
+}
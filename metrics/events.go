@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"tx-processor/events"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// eventsCollector exposes a live update broker's delivery counts
+type eventsCollector struct {
+	broker      *events.Broker
+	subscribers *prometheus.Desc
+	published   *prometheus.Desc
+	dropped     *prometheus.Desc
+}
+
+// NewEventsCollector reports live update subscribers and how many events were published and dropped
+func NewEventsCollector(broker *events.Broker) prometheus.Collector {
+	return &eventsCollector{
+		broker:      broker,
+		subscribers: prometheus.NewDesc("events_subscribers", "Open live update subscriptions.", nil, nil),
+		published:   prometheus.NewDesc("events_published_total", "Live update events published to the broker.", nil, nil),
+		dropped:     prometheus.NewDesc("events_dropped_total", "Live update deliveries dropped because a subscriber fell behind.", nil, nil),
+	}
+}
+
+func (c *eventsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.subscribers
+	ch <- c.published
+	ch <- c.dropped
+}
+
+func (c *eventsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.broker.Stats()
+	ch <- prometheus.MustNewConstMetric(c.subscribers, prometheus.GaugeValue, float64(stats.Subscribers))
+	ch <- prometheus.MustNewConstMetric(c.published, prometheus.CounterValue, float64(stats.Published))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(stats.Dropped))
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IngestMetrics records transaction ingestion progress. A nil *IngestMetrics
+// is valid and records nothing.
+type IngestMetrics struct {
+	linesRead     prometheus.Counter
+	linesParsed   prometheus.Counter
+	linesRejected prometheus.Counter
+	batches       prometheus.Counter
+	batchFailures prometheus.Counter
+	batchDuration prometheus.Histogram
+	batchSize     prometheus.Histogram
+	commitRetries prometheus.Counter
+}
+
+func NewIngestMetrics(reg prometheus.Registerer) *IngestMetrics {
+	m := &IngestMetrics{
+		linesRead: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ingest_lines_read_total",
+			Help: "Input lines read and queued for processing.",
+		}),
+		linesParsed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ingest_lines_parsed_total",
+			Help: "Input lines parsed into transactions.",
+		}),
+		linesRejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ingest_lines_rejected_total",
+			Help: "Input lines skipped because they were not valid transactions.",
+		}),
+		batches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ingest_batches_committed_total",
+			Help: "Transaction batches committed to the database.",
+		}),
+		batchFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ingest_batch_failures_total",
+			Help: "Transaction batches that failed to commit after retries.",
+		}),
+		batchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "ingest_batch_duration_seconds",
+			Help:    "Time to aggregate and commit one batch, including retries.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+		}),
+		batchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "ingest_batch_transactions",
+			Help:    "Transactions per committed batch.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+		}),
+		commitRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ingest_db_retries_total",
+			Help: "Batch commits retried after a database error.",
+		}),
+	}
+	reg.MustRegister(m.linesRead, m.linesParsed, m.linesRejected, m.batches,
+		m.batchFailures, m.batchDuration, m.batchSize, m.commitRetries)
+	return m
+}
+
+// RegisterQueueDepth reports the current depth of the ingestion queue
+func RegisterQueueDepth(reg prometheus.Registerer, depth func() int) {
+	reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "ingest_queue_depth",
+		Help: "Lines waiting in the ingestion queue.",
+	}, func() float64 {
+		return float64(depth())
+	}))
+}
+
+func (m *IngestMetrics) LineRead() {
+	if m != nil {
+		m.linesRead.Inc()
+	}
+}
+
+func (m *IngestMetrics) LineParsed() {
+	if m != nil {
+		m.linesParsed.Inc()
+	}
+}
+
+func (m *IngestMetrics) LineRejected() {
+	if m != nil {
+		m.linesRejected.Inc()
+	}
+}
+
+func (m *IngestMetrics) CommitRetried() {
+	if m != nil {
+		m.commitRetries.Inc()
+	}
+}
+
+// BatchDone records a batch outcome and how long it took
+func (m *IngestMetrics) BatchDone(transactions int, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.batchDuration.Observe(elapsed.Seconds())
+	if err != nil {
+		m.batchFailures.Inc()
+		return
+	}
+	m.batches.Inc()
+	m.batchSize.Observe(float64(transactions))
+}
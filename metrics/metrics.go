@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http"
+	"tx-processor/services"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewRegistry returns a registry preloaded with Go runtime and process metrics
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
+
+// fillCollector exposes AnalyticsService's background cache fill counters
+type fillCollector struct {
+	service *services.AnalyticsService
+	queued  *prometheus.Desc
+	dropped *prometheus.Desc
+	failed  *prometheus.Desc
+}
+
+// NewCacheFillCollector reports how many cache fills were queued, dropped and failed
+func NewCacheFillCollector(service *services.AnalyticsService) prometheus.Collector {
+	return &fillCollector{
+		service: service,
+		queued:  prometheus.NewDesc("analytics_cache_fills_queued_total", "Background cache fills queued after a database load.", nil, nil),
+		dropped: prometheus.NewDesc("analytics_cache_fills_dropped_total", "Background cache fills dropped because the queue was full.", nil, nil),
+		failed:  prometheus.NewDesc("analytics_cache_fills_failed_total", "Background cache fills the cache rejected.", nil, nil),
+	}
+}
+
+func (c *fillCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queued
+	ch <- c.dropped
+	ch <- c.failed
+}
+
+func (c *fillCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.service.CacheFillStats()
+	ch <- prometheus.MustNewConstMetric(c.queued, prometheus.CounterValue, float64(stats.Queued))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(stats.Dropped))
+	ch <- prometheus.MustNewConstMetric(c.failed, prometheus.CounterValue, float64(stats.Failed))
+}
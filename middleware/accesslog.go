@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+	"tx-processor/logger"
+	"tx-processor/models"
+)
+
+// AccessLog logs one line per request once it completes. Server errors and
+// aborted responses are logged at Error, everything else at Info.
+func AccessLog(log logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r, route := WithRoute(r)
+			rec := NewRecorder(w)
+
+			defer func() {
+				p := recover()
+				status := rec.Status()
+				args := []any{
+					"method", r.Method,
+					"path", r.URL.Path,
+					"route", route(),
+					"status", status,
+					"bytes", rec.Bytes(),
+					"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+					"remote_addr", r.RemoteAddr,
+					"user_agent", r.UserAgent(),
+				}
+				l := logger.WithTrace(r.Context(), log)
+				switch {
+				case p != nil:
+					// Only http.ErrAbortHandler gets this far past Recover
+					l.Error("request aborted", args...)
+					panic(p)
+				case status >= http.StatusInternalServerError:
+					l.Error("request served", args...)
+				default:
+					l.Info("request served", args...)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// Recover turns a panicking handler into a JSON 500, logging the panic with
+// its stack. http.ErrAbortHandler is passed on, as handlers use it to cut a
+// response off deliberately.
+func Recover(log logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := NewRecorder(w)
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				logger.WithTrace(r.Context(), log).Error("handler panicked",
+					"method", r.Method, "path", r.URL.Path, "panic", p, "stack", string(debug.Stack()))
+				if rec.Started() {
+					// Part of the response is out; aborting is the only way to
+					// tell the client it is incomplete
+					panic(http.ErrAbortHandler)
+				}
+				writeError(w, http.StatusInternalServerError, models.ErrCodeInternal, "internal error")
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
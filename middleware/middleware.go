@@ -0,0 +1,135 @@
+// Package middleware holds the HTTP middleware every route is served through:
+// request IDs, access logs, panic recovery, deadlines, body limits and
+// response compression. Each is a func(http.Handler) http.Handler, so they
+// compose with Chain and with the tracing, metrics and auth middleware.
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+	"tx-processor/models"
+)
+
+// Chain wraps h in mws, the first outermost
+func Chain(h http.Handler, mws ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Timeout bounds each request's context to d, so database calls behind a
+// route give up once it passes. A zero d leaves requests unbounded, for
+// streaming routes that manage their own lifetime.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// MaxBodySize caps request bodies at n bytes; reads past it fail with an
+// *http.MaxBytesError
+func MaxBodySize(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type routeKey struct{}
+
+// Routed records the pattern the mux matched in the slot WithRoute added, for
+// middleware further out whose copy of the request never sees it. It wraps
+// the mux itself.
+func Routed(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			// Deferred, so panicking routes are still named
+			defer func() { *route = r.Pattern }()
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// WithRoute returns r with a slot that Routed fills in, and a func that reads
+// the matched pattern once r has been served; it is empty if no route
+// matched. Middleware share the slot added furthest out.
+func WithRoute(r *http.Request) (*http.Request, func() string) {
+	route, ok := r.Context().Value(routeKey{}).(*string)
+	if !ok {
+		route = new(string)
+		r = r.WithContext(context.WithValue(r.Context(), routeKey{}, route))
+	}
+	return r, func() string { return *route }
+}
+
+// writeError answers with the v1 error envelope
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.Response[struct{}]{Error: &models.APIError{Code: code, Message: message}})
+}
+
+// Recorder notes the status and size of a response, for middleware that
+// report on it
+type Recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w}
+}
+
+// Status returns the status sent, which is 200 if the handler wrote nothing
+func (r *Recorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Started reports whether any of the response has been sent
+func (r *Recorder) Started() bool {
+	return r.status != 0
+}
+
+// Bytes returns how much of the body has been written
+func (r *Recorder) Bytes() int64 {
+	return r.bytes
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the connection to flush and set deadlines
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -1,6 +1,12 @@
 package models
 
-import "time"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
 
 // Transaction represents a single e-commerce order
 type Transaction struct {
@@ -17,6 +23,97 @@ type UserAnalytics struct {
 	UserID      string  `json:"user_id"`
 	TotalOrders int     `json:"total_orders"` // Count of transactions
 	TotalSpent  float64 `json:"total_spent"`  // Sum of (price * quantity)
+
+	FirstOrderAt *time.Time `json:"first_order_at,omitempty"` // Earliest timestamped order, if known
+	LastOrderAt  *time.Time `json:"last_order_at,omitempty"`  // Latest timestamped order, if known
+}
+
+// ErrUserNotFound is returned for users with no analytics
+var ErrUserNotFound = errors.New("user not found")
+
+// ActivityBucketSize is the granularity of per-user activity buckets
+const ActivityBucketSize = time.Minute
+
+// ActivityBucket holds a user's order activity within one time bucket
+type ActivityBucket struct {
+	UserID      string    `json:"user_id"`
+	BucketStart time.Time `json:"bucket_start"` // Truncated to ActivityBucketSize
+	Orders      int       `json:"orders"`
+	Spent       float64   `json:"spent"`
+}
+
+// Duration is a time.Duration that encodes to JSON as a string like "5m0s"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// VelocityAnchor picks where a user's velocity window ends
+type VelocityAnchor string
+
+const (
+	AnchorNow    VelocityAnchor = "now"    // The window ends with the current activity bucket
+	AnchorLatest VelocityAnchor = "latest" // The window ends with the user's latest activity, for historical loads
+)
+
+// Valid reports whether a is a known anchor
+func (a VelocityAnchor) Valid() bool {
+	return a == AnchorNow || a == AnchorLatest
+}
+
+// UserVelocity holds a user's activity within a trailing time window
+type UserVelocity struct {
+	UserID    string         `json:"user_id"`
+	Window    Duration       `json:"window"`
+	Anchor    VelocityAnchor `json:"anchor"`
+	WindowEnd *time.Time     `json:"window_end"` // Nil when anchored on the latest activity of a user with none
+	Orders    int            `json:"orders"`
+	Spent     float64        `json:"spent"`
+}
+
+// VelocityLookbackWindows is how many windows back a VelocityRule with no
+// Lookback scans
+const VelocityLookbackWindows = 12
+
+// VelocityRule flags users whose activity within Window reaches a threshold,
+// in windows ending within Lookback of now. A zero threshold disables that check.
+type VelocityRule struct {
+	Window         time.Duration
+	OrderThreshold int
+	SpendThreshold float64
+	Lookback       time.Duration // Zero means VelocityLookbackWindows windows
+}
+
+// ScanPeriod returns how far back the rule looks for bursts
+func (r VelocityRule) ScanPeriod() time.Duration {
+	if r.Lookback > 0 {
+		return r.Lookback
+	}
+	return VelocityLookbackWindows * r.Window
+}
+
+// VelocityAnomaly represents a user whose busiest recent window broke a velocity rule
+type VelocityAnomaly struct {
+	UserID          string    `json:"user_id"`
+	WindowEnd       time.Time `json:"window_end"`
+	Orders          int       `json:"orders"`
+	Spent           float64   `json:"spent"`
+	OrderAnomaly    bool      `json:"order_anomaly"`
+	SpendingAnomaly bool      `json:"spending_anomaly"`
 }
 
 // AnomalyUser represents a user with anomalous behavior
@@ -28,8 +125,323 @@ type AnomalyUser struct {
 	SpendingAnomaly bool    `json:"spending_anomaly"`
 }
 
+// RankBy selects the score users are ranked by
+type RankBy string
+
+const (
+	RankByOrders RankBy = "orders"
+	RankBySpend  RankBy = "spend"
+)
+
+// Valid reports whether r is a known ranking
+func (r RankBy) Valid() bool {
+	return r == RankByOrders || r == RankBySpend
+}
+
+// UserRank is a user's 1-based position on a leaderboard
+type UserRank struct {
+	UserID string  `json:"user_id"`
+	By     RankBy  `json:"by"`
+	Rank   int     `json:"rank"`
+	Score  float64 `json:"score"`
+}
+
+// SortKey orders a user listing, highest first
+type SortKey string
+
+const (
+	SortByOrders        SortKey = "orders"
+	SortBySpend         SortKey = "spend"
+	SortByAvgOrderValue SortKey = "avg_order_value"
+	SortByLastUpdated   SortKey = "last_updated"
+)
+
+// Valid reports whether k is a known sort key
+func (k SortKey) Valid() bool {
+	switch k {
+	case SortByOrders, SortBySpend, SortByAvgOrderValue, SortByLastUpdated:
+		return true
+	}
+	return false
+}
+
+// RankBy returns the leaderboard ranking that orders users the same way as k, if any
+func (k SortKey) RankBy() (RankBy, bool) {
+	by := RankBy(k)
+	return by, by.Valid()
+}
+
+// UserFilter narrows a user listing. Nil bounds are unset; set bounds are inclusive.
+type UserFilter struct {
+	MinOrders    *int
+	MaxOrders    *int
+	MinSpent     *float64
+	MaxSpent     *float64
+	UpdatedSince *time.Time
+}
+
+// IsZero reports whether the filter matches every user
+func (f UserFilter) IsZero() bool {
+	return f.MinOrders == nil && f.MaxOrders == nil && f.MinSpent == nil && f.MaxSpent == nil && f.UpdatedSince == nil
+}
+
+// UserQuery selects one page of a sorted, filtered user listing
+type UserQuery struct {
+	Sort   SortKey
+	Limit  int
+	After  *Cursor // Position to resume from, nil for the first page
+	Filter UserFilter
+}
+
+// Cursor is the keyset position of the last user on a page. Paging by
+// position rather than offset means concurrent updates can't shift users
+// that haven't changed onto a page already read or past one not yet read.
+type Cursor struct {
+	Sort   SortKey `json:"s"`
+	Value  string  `json:"v"` // Sort value of the last user, as text the database can compare exactly
+	UserID string  `json:"u"` // Tie-breaker between users with equal sort values
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a token produced by Cursor.Encode
+func ParseCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || !c.Sort.Valid() || c.Value == "" || c.UserID == "" {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	return &c, nil
+}
+
+// UserSummary is a user's analytics as listed on a sorted page
+type UserSummary struct {
+	UserAnalytics
+	AvgOrderValue float64    `json:"avg_order_value"`
+	LastUpdated   *time.Time `json:"last_updated,omitempty"` // Omitted when served from the leaderboard
+}
+
+// UserPage is one page of a user listing
+type UserPage struct {
+	Users []UserSummary
+	Next  *Cursor // Nil on the last page
+}
+
+// TopUsers is a sorted page of users
+type TopUsers struct {
+	By         RankBy        `json:"by,omitempty"` // Set when the sort key is also a leaderboard ranking
+	Sort       SortKey       `json:"sort"`
+	Users      []UserSummary `json:"users"`
+	Count      int           `json:"count"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// UserProfile is everything known about a single user
+type UserProfile struct {
+	UserID        string     `json:"user_id"`
+	TotalOrders   int        `json:"total_orders"`
+	TotalSpent    float64    `json:"total_spent"`
+	AvgOrderValue float64    `json:"avg_order_value"`
+	FirstOrderAt  *time.Time `json:"first_order_at"`
+	LastOrderAt   *time.Time `json:"last_order_at"`
+	LastUpdated   time.Time  `json:"last_updated"`
+
+	Rank       ProfileRanks       `json:"rank"`       // 1-based; users with equal scores share a rank
+	Percentile ProfilePercentiles `json:"percentile"` // Share of users scoring at or below this user, 0-100
+	Population int                `json:"population"` // Users the rank and percentile are taken over
+
+	OrderAnomaly    bool `json:"order_anomaly"`
+	SpendingAnomaly bool `json:"spending_anomaly"`
+}
+
+// ProfileRanks holds a user's position on each leaderboard
+type ProfileRanks struct {
+	Orders int `json:"orders"`
+	Spend  int `json:"spend"`
+}
+
+// ProfilePercentiles holds a user's percentile for each ranking
+type ProfilePercentiles struct {
+	Orders float64 `json:"orders"`
+	Spend  float64 `json:"spend"`
+}
+
+// UserProfiles is the result of a batch profile lookup
+type UserProfiles struct {
+	Profiles []UserProfile `json:"profiles"`
+	Missing  []string      `json:"missing"` // Requested users with no analytics
+}
+
+// ExportFormat is a bulk export file format
+type ExportFormat string
+
+const (
+	ExportCSV     ExportFormat = "csv"
+	ExportNDJSON  ExportFormat = "ndjson"
+	ExportParquet ExportFormat = "parquet"
+)
+
+// ExportFormats lists the supported formats, preferred first
+var ExportFormats = []ExportFormat{ExportNDJSON, ExportCSV, ExportParquet}
+
+var exportContentTypes = map[ExportFormat]string{
+	ExportCSV:     "text/csv",
+	ExportNDJSON:  "application/x-ndjson",
+	ExportParquet: "application/vnd.apache.parquet",
+}
+
+// Valid reports whether f is a supported format
+func (f ExportFormat) Valid() bool {
+	_, ok := exportContentTypes[f]
+	return ok
+}
+
+// ContentType returns the media type of files in format f
+func (f ExportFormat) ContentType() string {
+	return exportContentTypes[f]
+}
+
+// EventType distinguishes live update events
+type EventType string
+
+const (
+	// EventAnalytics carries per-user deltas from one committed batch
+	EventAnalytics EventType = "analytics"
+	// EventLeaderboard carries a ranking whose top users changed
+	EventLeaderboard EventType = "leaderboard"
+)
+
+// Valid reports whether t is a known event type
+func (t EventType) Valid() bool {
+	return t == EventAnalytics || t == EventLeaderboard
+}
+
+// EventFilter selects which live updates a subscriber receives
+type EventFilter struct {
+	Types   []EventType // Empty receives every type
+	UserIDs []string    // Restricts analytics deltas to these users; empty receives all
+	By      RankBy      // Restricts leaderboard events to one ranking; empty receives both
+	Top     int         // Only leaderboard changes within the first Top users, truncated to them; 0 receives every change
+}
+
+// Event is a live update published as batches are committed
+type Event struct {
+	ID          uint64             `json:"id"` // Assigned by the broker that delivers it, increasing
+	Type        EventType          `json:"type"`
+	At          time.Time          `json:"at"`
+	Deltas      []UserDelta        `json:"deltas,omitempty"`      // Set for EventAnalytics
+	Leaderboard *LeaderboardChange `json:"leaderboard,omitempty"` // Set for EventLeaderboard
+}
+
+// UserDelta is how much a user's totals grew in one batch
+type UserDelta struct {
+	UserID string  `json:"user_id"`
+	Orders int     `json:"orders"`
+	Spent  float64 `json:"spent"`
+}
+
+// LeaderboardChange is the new top of a ranking
+type LeaderboardChange struct {
+	By    RankBy        `json:"by"`
+	Users []UserSummary `json:"users"`
+}
+
+// RuntimeSettings holds the settings that change without a restart, keyed by
+// environment variable, and what the update that returned them changed
+type RuntimeSettings struct {
+	Settings        map[string]string `json:"settings"`
+	Changed         []string          `json:"changed,omitempty"`
+	RestartRequired []string          `json:"restart_required,omitempty"` // Changed in the file or environment, applied on restart
+}
+
+// CacheInvalidation reports what an admin cache invalidation removed
+type CacheInvalidation struct {
+	UserID      string `json:"user_id,omitempty"` // Set when one user was invalidated
+	Prefix      string `json:"prefix,omitempty"`  // Set when users were invalidated by ID prefix
+	All         bool   `json:"all,omitempty"`
+	Invalidated int    `json:"invalidated"` // Entries removed from the shared cache tier, or the local one without Redis
+}
+
+// JobStatus is where a background admin job is in its life
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// JobKind names what a background admin job does
+type JobKind string
+
+const (
+	JobIngest             JobKind = "ingest"              // Reads a transaction file
+	JobLeaderboardRebuild JobKind = "leaderboard_rebuild" // Repopulates the leaderboard from the database
+	JobRetentionPrune     JobKind = "retention_prune"     // Deletes old activity buckets
+)
+
+// Job is a background admin job. Progress counts lines read for ingestion,
+// users loaded for a leaderboard rebuild and buckets deleted for pruning.
+type Job struct {
+	ID         string            `json:"id"`
+	Kind       JobKind           `json:"kind"`
+	Status     JobStatus         `json:"status"`
+	Params     map[string]string `json:"params,omitempty"`
+	Progress   int64             `json:"progress"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// BuildInfo identifies the running binary
+type BuildInfo struct {
+	Version    string `json:"version"`
+	Revision   string `json:"revision,omitempty"`    // VCS commit the binary was built from
+	CommitTime string `json:"commit_time,omitempty"` // RFC 3339
+	Modified   bool   `json:"modified,omitempty"`    // Built from a tree with uncommitted changes
+	GoVersion  string `json:"go_version"`
+}
+
+// ServerInfo describes the running server for operators
+type ServerInfo struct {
+	Build         BuildInfo         `json:"build"`
+	StartedAt     time.Time         `json:"started_at"`
+	UptimeSeconds float64           `json:"uptime_seconds"`
+	SchemaVersion int               `json:"schema_version"` // Database schema version this build migrates to
+	Config        map[string]string `json:"config"`         // Every setting by environment variable, secrets redacted
+}
+
+// Error codes carried by APIError
+const (
+	ErrCodeInvalidArgument  = "invalid_argument"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeInternal         = "internal"
+	ErrCodeUnavailable      = "unavailable"
+	ErrCodeUnauthenticated  = "unauthenticated"
+	ErrCodePermissionDenied = "permission_denied"
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeDeadlineExceeded = "deadline_exceeded"
+	ErrCodePayloadTooLarge  = "payload_too_large"
+	ErrCodeConflict         = "conflict"
+)
+
+// APIError describes why a request failed
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 // Response is a generic API response wrapper
 type Response[T any] struct {
-	Data    *T     `json:"data,omitempty"`
-	Message string `json:"message,omitempty"`
+	Data    *T        `json:"data,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Error   *APIError `json:"error,omitempty"`
 }
@@ -1,48 +1,141 @@
 package processor
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log/slog"
+	"io"
 	"sync"
+	"sync/atomic"
+	"time"
+	"tx-processor/cache"
 	"tx-processor/config"
+	"tx-processor/events"
+	"tx-processor/logger"
+	"tx-processor/metrics"
 	"tx-processor/models"
 	"tx-processor/services"
+	"tx-processor/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = otel.Tracer("tx-processor/processor")
+
+const (
+	// maxCommitRetries is how many times a failed batch commit is retried
+	maxCommitRetries = 3
+	// commitRetryBackoff is the first retry delay, doubled on each attempt
+	commitRetryBackoff = 100 * time.Millisecond
+	// publishTimeout bounds publishing a batch's events, so a slow broker can't stall ingestion
+	publishTimeout = time.Second
+	// failureHold is how long a failed batch reports the pipeline unhealthy
+	// when no later batch succeeds, so an idle process doesn't stay unready
+	failureHold = time.Minute
 )
 
 type Processor struct {
-	cfg            *config.Config
-	logger         *slog.Logger
-	repo           services.Analytics
-	analyticsCache sync.Map // Thread-safe map for real-time data
-	userMu         sync.Map // Per-user locks to prevent races
+	cfg         *config.Config
+	logger      logger.Logger
+	repo        services.Analytics
+	leaderboard cache.Leaderboard
+	invalidator Invalidator
+	metrics     *metrics.IngestMetrics
+	publisher   events.Publisher
+	lastBatch   atomic.Pointer[batchResult]
+	batchSize   atomic.Int64
 
+	snapshotMu sync.Mutex
+	snapshot   map[string]*models.UserAnalytics // Committed totals per user, nil unless WithSnapshot
+
+	queuesMu sync.Mutex
+	queues   map[chan string]struct{} // Line queues of running Ingest calls
 }
 
-func NewProcessor(cfg *config.Config, logger *slog.Logger, repo services.Analytics) *Processor {
-	return &Processor{
-		cfg:            cfg,
-		logger:         logger,
-		repo:           repo,
-		analyticsCache: sync.Map{},
-		userMu:         sync.Map{},
+// Option configures optional Processor dependencies
+type Option func(*Processor)
+
+// WithLeaderboard keeps a leaderboard in step with each committed batch
+func WithLeaderboard(leaderboard cache.Leaderboard) Option {
+	return func(p *Processor) {
+		p.leaderboard = leaderboard
 	}
 }
 
-func (p *Processor) ProcessStream(ctx context.Context, lines <-chan string, batchSize int) error {
+// Invalidator evicts users' cached analytics; *services.AnalyticsService
+// implements it
+type Invalidator interface {
+	InvalidateUsers(ctx context.Context, userIDs []string) error
+}
+
+// WithInvalidator invalidates cached analytics for users touched by each committed batch
+func WithInvalidator(invalidator Invalidator) Option {
+	return func(p *Processor) {
+		p.invalidator = invalidator
+	}
+}
+
+// WithSnapshot keeps the totals of every user the processor commits in
+// memory, for Snapshot. It suits one-shot runs; a long-lived process would
+// hold an entry for every user it ever saw.
+func WithSnapshot() Option {
+	return func(p *Processor) {
+		p.snapshot = make(map[string]*models.UserAnalytics)
+	}
+}
+
+// WithMetrics records parse, batch and retry metrics
+func WithMetrics(m *metrics.IngestMetrics) Option {
+	return func(p *Processor) {
+		p.metrics = m
+	}
+}
+
+// WithPublisher publishes each committed batch's per-user deltas as a live update
+func WithPublisher(publisher events.Publisher) Option {
+	return func(p *Processor) {
+		p.publisher = publisher
+	}
+}
+
+func NewProcessor(cfg *config.Config, logger logger.Logger, repo services.Analytics, opts ...Option) *Processor {
+	p := &Processor{
+		cfg:    cfg,
+		logger: logger,
+		repo:   repo,
+		queues: make(map[chan string]struct{}),
+	}
+	p.SetBatchSize(cfg.IngestConfig.BatchSize)
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// SetBatchSize changes how many transactions ProcessStream commits together,
+// from each stream's next batch on
+func (p *Processor) SetBatchSize(n int) {
+	p.batchSize.Store(int64(max(n, 1)))
+}
+
+func (p *Processor) ProcessStream(ctx context.Context, lines <-chan string) error {
 	var batch []models.Transaction
 
 	for line := range lines {
 		var transaction models.Transaction
 		if err := json.Unmarshal([]byte(line), &transaction); err != nil {
-			p.logger.Warn("Skipping invalid JSON", "error", err)
+			logger.WithTrace(ctx, p.logger).Warn("Skipping invalid JSON", "error", err)
+			p.metrics.LineRejected()
 			continue
 		}
+		p.metrics.LineParsed()
 
 		batch = append(batch, transaction)
 
-		if len(batch) >= batchSize {
+		if len(batch) >= int(p.batchSize.Load()) {
 			if err := p.applyTransactions(ctx, batch); err != nil {
 				return fmt.Errorf("processing batch: %w", err)
 			}
@@ -57,58 +150,279 @@ func (p *Processor) ProcessStream(ctx context.Context, lines <-chan string, batc
 	return nil
 }
 
-func (p *Processor) applyTransactions(ctx context.Context, txs []models.Transaction) error {
-	localUpdates := make(map[string]*models.UserAnalytics)
+// Ingest reads JSON lines from r and processes them on workers streams,
+// returning how many lines it read. Reading stops when ctx is done or a
+// worker fails; lines already read are committed under commitCtx, so
+// stopping doesn't lose them. read, if not nil, counts lines as they are read.
+func (p *Processor) Ingest(ctx, commitCtx context.Context, r io.Reader, workers, buffer int, read *atomic.Int64) (int, error) {
+	readCtx, stopReading := context.WithCancelCause(ctx)
+	defer stopReading(nil)
 
-	for _, tx := range txs {
-		userID := tx.UserID
+	lines := make(chan string, buffer)
+	p.queuesMu.Lock()
+	p.queues[lines] = struct{}{}
+	p.queuesMu.Unlock()
+	defer func() {
+		p.queuesMu.Lock()
+		delete(p.queues, lines)
+		p.queuesMu.Unlock()
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if err := p.ProcessStream(commitCtx, lines); err != nil {
+				logger.WithTrace(ctx, p.logger).Error("worker failed", "id", id, "error", err)
+				stopReading(err)
+				// Keep draining so the reader never blocks on a full queue
+				for range lines {
+				}
+			}
+		}(i)
+	}
+
+	scanner := bufio.NewScanner(r)
+	// Go’s default scanner buffer is 64KB per line, which may fail for large JSON lines.
+	buf := make([]byte, 0, 1024*1024) // 1MB buffer
+	scanner.Buffer(buf, 10*1024*1024) // Max token size 10MB
+
+	total := 0
+	for readCtx.Err() == nil && scanner.Scan() {
+		lines <- scanner.Text()
+		total++
+		p.metrics.LineRead()
+		if read != nil {
+			read.Add(1)
+		}
+	}
+	close(lines)
+	wg.Wait()
+
+	if err := context.Cause(readCtx); err != nil {
+		return total, err
+	}
+	if err := scanner.Err(); err != nil {
+		return total, fmt.Errorf("read input: %w", err)
+	}
+	return total, nil
+}
+
+// QueueDepth returns how many lines are waiting for workers across running
+// Ingest calls
+func (p *Processor) QueueDepth() int {
+	p.queuesMu.Lock()
+	defer p.queuesMu.Unlock()
+
+	depth := 0
+	for lines := range p.queues {
+		depth += len(lines)
+	}
+	return depth
+}
+
+// ProcessBatch applies already-decoded transactions as a single batch, for
+// callers that receive transactions other than as JSON lines
+func (p *Processor) ProcessBatch(ctx context.Context, txs []models.Transaction) error {
+	return p.applyTransactions(ctx, txs)
+}
+
+// batchResult records how the most recent batch went, for health checks
+type batchResult struct {
+	err error
+	at  time.Time
+}
+
+// Check reports the pipeline unhealthy while its most recent batch has
+// failed, until a later batch succeeds or failureHold passes
+func (p *Processor) Check(ctx context.Context) error {
+	last := p.lastBatch.Load()
+	if last != nil && last.err != nil && time.Since(last.at) < failureHold {
+		return fmt.Errorf("last batch failed at %s: %w", last.at.Format(time.RFC3339), last.err)
+	}
+	return nil
+}
 
-		// Per-user locking for thread safety without global contention
-		lockIface, _ := p.userMu.LoadOrStore(userID, &sync.Mutex{})
-		lock := lockIface.(*sync.Mutex)
+type activityKey struct {
+	userID      string
+	bucketStart time.Time
+}
 
-		lock.Lock()
+func (p *Processor) applyTransactions(ctx context.Context, txs []models.Transaction) (err error) {
+	ctx, span := tracer.Start(ctx, "Processor.applyTransactions")
+	start := time.Now()
+	defer func() {
+		p.metrics.BatchDone(len(txs), time.Since(start), err)
+		p.lastBatch.Store(&batchResult{err: err, at: time.Now()})
+		tracing.Error(span, err)
+		span.End()
+	}()
 
-		dataIface, _ := p.analyticsCache.LoadOrStore(userID, &models.UserAnalytics{UserID: userID})
-		userData := dataIface.(*models.UserAnalytics)
+	localUpdates := make(map[string]*models.UserAnalytics)
+	localActivity := make(map[activityKey]*models.ActivityBucket)
 
+	for _, tx := range txs {
+		userID := tx.UserID
 		value := tx.Price * float64(tx.Quantity)
-		userData.TotalOrders++
-		userData.TotalSpent += value
 
-		if existing, ok := localUpdates[userID]; ok {
-			existing.TotalOrders++
-			existing.TotalSpent += value
+		update, ok := localUpdates[userID]
+		if !ok {
+			update = &models.UserAnalytics{UserID: userID}
+			localUpdates[userID] = update
+		}
+		update.TotalOrders++
+		update.TotalSpent += value
+
+		// Transactions without a timestamp can't be placed in a velocity window
+		if tx.Timestamp.IsZero() {
+			continue
+		}
+		observeOrderTime(update, tx.Timestamp)
+
+		key := activityKey{userID: userID, bucketStart: tx.Timestamp.UTC().Truncate(models.ActivityBucketSize)}
+		if bucket, ok := localActivity[key]; ok {
+			bucket.Orders++
+			bucket.Spent += value
 		} else {
-			localUpdates[userID] = &models.UserAnalytics{
+			localActivity[key] = &models.ActivityBucket{
 				UserID:      userID,
-				TotalOrders: 1,
-				TotalSpent:  value,
+				BucketStart: key.bucketStart,
+				Orders:      1,
+				Spent:       value,
 			}
 		}
+	}
 
-		lock.Unlock()
+	activity := make([]models.ActivityBucket, 0, len(localActivity))
+	for _, bucket := range localActivity {
+		activity = append(activity, *bucket)
 	}
 
-	if err := p.repo.UpdateAnalytics(ctx, localUpdates); err != nil {
+	span.SetAttributes(
+		attribute.Int("batch.transactions", len(txs)),
+		attribute.Int("batch.users", len(localUpdates)),
+	)
+
+	if err := p.commit(ctx, localUpdates, activity); err != nil {
 		return err
 	}
+	p.addToSnapshot(localUpdates)
+
+	// Cached entries only hold totals, so evicting is safer than patching them
+	if p.invalidator != nil {
+		userIDs := make([]string, 0, len(localUpdates))
+		for userID := range localUpdates {
+			userIDs = append(userIDs, userID)
+		}
+		if err := p.invalidator.InvalidateUsers(ctx, userIDs); errors.Is(err, cache.ErrPublish) {
+			logger.WithTrace(ctx, p.logger).Warn("invalidated cached analytics without telling other processes", "users_affected", len(userIDs), "error", err)
+		} else if err != nil {
+			logger.WithTrace(ctx, p.logger).Warn("failed to invalidate cached analytics", "users_affected", len(userIDs), "error", err)
+		}
+	}
+
+	// The database is the source of truth; a rebuild repairs any drift
+	if p.leaderboard != nil {
+		if err := p.leaderboard.Increment(ctx, localUpdates); err != nil {
+			logger.WithTrace(ctx, p.logger).Warn("failed to update leaderboard", "users_affected", len(localUpdates), "error", err)
+		}
+	}
+
+	if p.publisher != nil {
+		p.publish(ctx, localUpdates)
+	}
 
-	p.logger.Info("batch processed",
+	logger.WithTrace(ctx, p.logger).Info("batch processed",
 		"transactions", len(txs),
 		"users_affected", len(localUpdates))
 
 	return nil
 }
 
-// Snapshot returns a copy of the current in-memory analytics
+// publish announces a committed batch's deltas. Failures only cost live
+// viewers an update, so they are logged rather than failing the batch.
+func (p *Processor) publish(ctx context.Context, updates map[string]*models.UserAnalytics) {
+	deltas := make([]models.UserDelta, 0, len(updates))
+	for userID, update := range updates {
+		deltas = append(deltas, models.UserDelta{UserID: userID, Orders: update.TotalOrders, Spent: update.TotalSpent})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	event := models.Event{Type: models.EventAnalytics, At: time.Now().UTC(), Deltas: deltas}
+	if err := p.publisher.Publish(ctx, event); err != nil {
+		logger.WithTrace(ctx, p.logger).Warn("failed to publish batch event", "users_affected", len(deltas), "error", err)
+	}
+}
+
+// observeOrderTime widens the analytics' first/last order times to include at.
+// The times are replaced rather than modified, as copies may share them.
+func observeOrderTime(analytics *models.UserAnalytics, at time.Time) {
+	at = at.UTC()
+	if analytics.FirstOrderAt == nil || at.Before(*analytics.FirstOrderAt) {
+		analytics.FirstOrderAt = &at
+	}
+	if analytics.LastOrderAt == nil || at.After(*analytics.LastOrderAt) {
+		analytics.LastOrderAt = &at
+	}
+}
+
+// commit writes a batch, retrying with backoff since each attempt is a single
+// database transaction and safe to repeat.
+func (p *Processor) commit(ctx context.Context, updates map[string]*models.UserAnalytics, activity []models.ActivityBucket) error {
+	backoff := commitRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := p.repo.UpdateAnalytics(ctx, updates, activity)
+		if err == nil || attempt >= maxCommitRetries || ctx.Err() != nil {
+			return err
+		}
+
+		p.metrics.CommitRetried()
+		logger.WithTrace(ctx, p.logger).Warn("retrying batch commit", "attempt", attempt+1, "backoff", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// addToSnapshot adds a committed batch's deltas to the in-memory totals, if kept
+func (p *Processor) addToSnapshot(updates map[string]*models.UserAnalytics) {
+	if p.snapshot == nil {
+		return
+	}
+	p.snapshotMu.Lock()
+	defer p.snapshotMu.Unlock()
+
+	for userID, update := range updates {
+		total, ok := p.snapshot[userID]
+		if !ok {
+			total = &models.UserAnalytics{UserID: userID}
+			p.snapshot[userID] = total
+		}
+		total.TotalOrders += update.TotalOrders
+		total.TotalSpent += update.TotalSpent
+		if update.FirstOrderAt != nil {
+			observeOrderTime(total, *update.FirstOrderAt)
+			observeOrderTime(total, *update.LastOrderAt)
+		}
+	}
+}
+
+// Snapshot returns a copy of the totals committed by this processor, which
+// is empty unless it was created WithSnapshot
 func (p *Processor) Snapshot() map[string]models.UserAnalytics {
-	stats := make(map[string]models.UserAnalytics)
-	p.analyticsCache.Range(func(key, value any) bool {
-		userID := key.(string)
-		analytics := value.(*models.UserAnalytics)
+	p.snapshotMu.Lock()
+	defer p.snapshotMu.Unlock()
+
+	stats := make(map[string]models.UserAnalytics, len(p.snapshot))
+	for userID, analytics := range p.snapshot {
 		stats[userID] = *analytics
-		return true
-	})
+	}
 	return stats
 }
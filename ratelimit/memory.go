@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled are dropped
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryLimiter keeps buckets in process, for a single instance
+type MemoryLimiter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	policy    Policy
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter(policy Policy) *MemoryLimiter {
+	return &MemoryLimiter{
+		policy:  policy,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (l *MemoryLimiter) Take(ctx context.Context, key string, cost int) (Result, error) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cost = l.policy.cost(cost)
+	burst := float64(l.policy.Burst)
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*l.policy.Rate)
+	b.last = now
+
+	allowed := b.tokens >= float64(cost)
+	if allowed {
+		b.tokens -= float64(cost)
+	}
+	return l.policy.result(allowed, b.tokens, cost), nil
+}
+
+func (l *MemoryLimiter) SetPolicy(policy Policy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policy = policy
+}
+
+// sweep drops buckets that would be full by now, as a new bucket starts full
+// anyway. Callers hold l.mu.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	window := l.policy.Window()
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= window {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,121 @@
+// Package ratelimit meters callers with token buckets. Each caller's bucket
+// holds up to Burst tokens and refills at Rate tokens per second; a request
+// spends its route's cost, so expensive routes drain a bucket faster.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+	"tx-processor/auth"
+	"tx-processor/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limiter spends tokens from per-key buckets
+type Limiter interface {
+	// Take spends cost tokens from key's bucket if it holds enough. Costs
+	// above the burst size are capped at it, so no request is impossible.
+	Take(ctx context.Context, key string, cost int) (Result, error)
+	// SetPolicy resizes every bucket from the next Take on
+	SetPolicy(policy Policy)
+}
+
+// Result describes a bucket after a Take
+type Result struct {
+	Allowed    bool
+	Limit      int           // Bucket capacity
+	Window     time.Duration // Time an empty bucket takes to refill
+	Remaining  int           // Whole tokens left
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until the denied request would be allowed; zero when allowed
+}
+
+// Policy sizes every caller's bucket
+type Policy struct {
+	Rate  float64 // Tokens added per second
+	Burst int     // Bucket capacity
+}
+
+// NewPolicy returns the policy cfg configures
+func NewPolicy(cfg *config.RateLimitConfig) Policy {
+	return Policy{Rate: cfg.Rate, Burst: cfg.Burst}
+}
+
+// Window is how long an empty bucket takes to refill
+func (p Policy) Window() time.Duration {
+	return seconds(float64(p.Burst) / p.Rate)
+}
+
+// result reports a bucket left holding tokens after a request costing cost
+func (p Policy) result(allowed bool, tokens float64, cost int) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     p.Burst,
+		Window:    p.Window(),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(p.Burst) - tokens) / p.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((float64(cost) - tokens) / p.Rate)
+	}
+	return res
+}
+
+func (p Policy) cost(cost int) int {
+	return max(1, min(cost, p.Burst))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
+
+// Key names the bucket for a caller: authenticated callers share one bucket
+// wherever they connect from, anonymous ones are metered per IP
+func Key(id *auth.Identity, ip string) string {
+	if id != nil {
+		return "caller:" + string(id.Method) + ":" + id.Subject
+	}
+	return "ip:" + ip
+}
+
+// ClientIP returns the address a request came from. With trustForwarded, the
+// last X-Forwarded-For entry is used, as that is the one our proxy appended;
+// earlier entries are client-supplied.
+func ClientIP(remoteAddr, forwardedFor string, trustForwarded bool) string {
+	if trustForwarded && forwardedFor != "" {
+		entries := strings.Split(forwardedFor, ",")
+		if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// Setup builds the configured limiter. The redis backend needs client.
+func Setup(cfg *config.RateLimitConfig, client *redis.Client) (Limiter, error) {
+	if cfg.Rate <= 0 || cfg.Burst < 1 {
+		return nil, fmt.Errorf("ratelimit: rate must be positive and burst at least 1, got %g and %d", cfg.Rate, cfg.Burst)
+	}
+	policy := NewPolicy(cfg)
+
+	switch cfg.Backend {
+	case "memory":
+		return NewMemoryLimiter(policy), nil
+	case "redis":
+		if client == nil {
+			return nil, fmt.Errorf("ratelimit: the redis backend needs REDIS_ENABLED")
+		}
+		return NewRedisLimiter(client, policy), nil
+	default:
+		return nil, fmt.Errorf("ratelimit: unknown backend %q", cfg.Backend)
+	}
+}
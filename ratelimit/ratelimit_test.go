@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testPolicy refills a token a second into buckets of three
+var testPolicy = Policy{Rate: 1, Burst: 3}
+
+// take is one Take, made at elapsed into the test
+type take struct {
+	elapsed time.Duration
+	key     string
+	cost    int
+	want    Result
+}
+
+// backend builds a limiter whose clock the test sets
+type backend func(t *testing.T, policy Policy) (Limiter, func(time.Time))
+
+var backends = map[string]backend{
+	"memory": func(t *testing.T, policy Policy) (Limiter, func(time.Time)) {
+		l := NewMemoryLimiter(policy)
+		var now time.Time
+		l.now = func() time.Time { return now }
+		return l, func(t time.Time) { now = t }
+	},
+	"redis": func(t *testing.T, policy Policy) (Limiter, func(time.Time)) {
+		m := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return NewRedisLimiter(client, policy), m.SetTime
+	},
+}
+
+// allowed and denied describe testPolicy's bucket after a Take
+func allowed(tokens float64) Result {
+	return testPolicy.result(true, tokens, 0)
+}
+
+func denied(tokens float64, cost int) Result {
+	return testPolicy.result(false, tokens, cost)
+}
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{
+			name: "burst",
+			takes: []take{
+				{key: "a", cost: 1, want: allowed(2)},
+				{key: "a", cost: 1, want: allowed(1)},
+				{key: "a", cost: 1, want: allowed(0)},
+				{key: "a", cost: 1, want: denied(0, 1)},
+				{key: "b", cost: 1, want: allowed(2)},
+			},
+		},
+		{
+			name: "refill",
+			takes: []take{
+				{key: "a", cost: 3, want: allowed(0)},
+				{elapsed: 1500 * time.Millisecond, key: "a", cost: 1, want: allowed(0.5)},
+				{elapsed: 1500 * time.Millisecond, key: "a", cost: 1, want: denied(0.5, 1)},
+				{elapsed: 2 * time.Second, key: "a", cost: 2, want: denied(1, 2)},
+				{elapsed: 10 * time.Second, key: "a", cost: 1, want: allowed(2)},
+			},
+		},
+		{
+			name: "cost above capacity",
+			takes: []take{
+				{key: "a", cost: 10, want: allowed(0)},
+				{key: "a", cost: 10, want: denied(0, 3)},
+				{elapsed: 3 * time.Second, key: "a", cost: 10, want: allowed(0)},
+			},
+		},
+		{
+			name: "cost below one",
+			takes: []take{
+				{key: "a", cost: 0, want: allowed(2)},
+				{key: "a", cost: -5, want: allowed(1)},
+			},
+		},
+	}
+
+	for name, newLimiter := range backends {
+		t.Run(name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					l, setTime := newLimiter(t, testPolicy)
+					start := time.Unix(1_700_000_000, 0)
+
+					for i, tk := range tt.takes {
+						setTime(start.Add(tk.elapsed))
+						got, err := l.Take(context.Background(), tk.key, tk.cost)
+						if err != nil {
+							t.Fatalf("take %d: %v", i, err)
+						}
+						if got != tk.want {
+							t.Errorf("take %d at %v of %d from %q = %+v, want %+v", i, tk.elapsed, tk.cost, tk.key, got, tk.want)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	for name, newLimiter := range backends {
+		t.Run(name, func(t *testing.T) {
+			l, setTime := newLimiter(t, testPolicy)
+			setTime(time.Unix(1_700_000_000, 0))
+
+			if _, err := l.Take(context.Background(), "a", 3); err != nil {
+				t.Fatal(err)
+			}
+			l.SetPolicy(Policy{Rate: 1, Burst: 1})
+
+			got, err := l.Take(context.Background(), "a", 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (Policy{Rate: 1, Burst: 1}).result(false, 0, 1); got != want {
+				t.Errorf("Take after SetPolicy = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestRedisBucketExpiresOnceFull(t *testing.T) {
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	l := NewRedisLimiter(client, testPolicy)
+
+	if _, err := l.Take(context.Background(), "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	// One token to refill at a second each, plus a second of slack
+	if got, want := m.TTL("ratelimit:a"), 2*time.Second; got != want {
+		t.Errorf("TTL = %v, want %v", got, want)
+	}
+	if got := m.HGet("ratelimit:a", "tokens"); got != "2" {
+		t.Errorf("tokens = %q, want 2", got)
+	}
+
+	m.FastForward(2 * time.Second)
+	if m.Exists("ratelimit:a") {
+		t.Error("bucket outlived its TTL")
+	}
+}
+
+func TestMemoryLimiterSweepsFullBuckets(t *testing.T) {
+	l := NewMemoryLimiter(testPolicy)
+	now := time.Unix(1_700_000_000, 0)
+	l.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := l.Take(context.Background(), key, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.Add(sweepInterval)
+	if _, err := l.Take(context.Background(), "c", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 1 {
+		t.Errorf("buckets after sweep = %v, want only c", l.buckets)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   string
+		trustForwarded bool
+		want           string
+	}{
+		{"remote address", "10.0.0.1:5000", "", false, "10.0.0.1"},
+		{"forwarded ignored when untrusted", "10.0.0.1:5000", "1.2.3.4", false, "10.0.0.1"},
+		{"last forwarded entry", "10.0.0.1:5000", "6.6.6.6, 1.2.3.4", true, "1.2.3.4"},
+		{"empty last entry", "10.0.0.1:5000", "1.2.3.4, ", true, "10.0.0.1"},
+		{"no port", "10.0.0.1", "", false, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClientIP(tt.remoteAddr, tt.forwardedFor, tt.trustForwarded); got != tt.want {
+				t.Errorf("ClientIP(%q, %q, %v) = %q, want %q", tt.remoteAddr, tt.forwardedFor, tt.trustForwarded, got, tt.want)
+			}
+		})
+	}
+}
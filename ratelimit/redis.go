@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"tx-processor/tracing"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("tx-processor/ratelimit")
+
+// takeScript refills and spends a bucket atomically, using the Redis clock so
+// replicas with skewed clocks agree. A bucket expires once it would be full.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+    tokens = burst
+    ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= cost then
+    tokens = tokens - cost
+    allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisLimiter keeps buckets in Redis, so a fleet shares each caller's budget
+type RedisLimiter struct {
+	client *redis.Client
+	policy atomic.Pointer[Policy]
+	Prefix string
+}
+
+func NewRedisLimiter(client *redis.Client, policy Policy) *RedisLimiter {
+	l := &RedisLimiter{
+		client: client,
+		Prefix: "ratelimit",
+	}
+	l.SetPolicy(policy)
+	return l
+}
+
+func (l *RedisLimiter) SetPolicy(policy Policy) {
+	l.policy.Store(&policy)
+}
+
+func (l *RedisLimiter) Take(ctx context.Context, key string, cost int) (Result, error) {
+	ctx, span := tracer.Start(ctx, "RedisLimiter.Take")
+	defer span.End()
+
+	policy := l.policy.Load()
+	cost = policy.cost(cost)
+	reply, err := takeScript.Run(ctx, l.client, []string{l.Prefix + ":" + key},
+		policy.Rate, policy.Burst, cost).Slice()
+	if err != nil {
+		return Result{}, tracing.Error(span, fmt.Errorf("take tokens: %w", err))
+	}
+	if len(reply) != 2 {
+		return Result{}, tracing.Error(span, fmt.Errorf("take tokens: unexpected reply %v", reply))
+	}
+
+	allowed, _ := reply[0].(int64)
+	tokensStr, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, tracing.Error(span, fmt.Errorf("take tokens: parse %q: %w", tokensStr, err))
+	}
+	return policy.result(allowed == 1, tokens, cost), nil
+}
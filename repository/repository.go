@@ -4,109 +4,484 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"iter"
+	"strings"
+	"time"
+	"tx-processor/logger"
 	"tx-processor/models"
+	"tx-processor/tracing"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = otel.Tracer("tx-processor/repository")
+
 // AnalyticsRepo provides methods for interacting with user analytics data.
 // It implements Analytics interface
 type AnalyticsRepo struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	logger logger.Logger
 }
 
 // NewAnalyticsRepo creates a new AnalyticsRepo instance.
-func NewAnalyticsRepo(db *sqlx.DB) *AnalyticsRepo {
-	return &AnalyticsRepo{db: db}
+func NewAnalyticsRepo(db *sqlx.DB, logger logger.Logger) *AnalyticsRepo {
+	return &AnalyticsRepo{db: db, logger: logger}
 }
 
-// UpdateAnalytics applies aggregated transaction updates for multiple users atomically.
-func (r *AnalyticsRepo) UpdateAnalytics(ctx context.Context, updates map[string]*models.UserAnalytics) error {
-	if len(updates) == 0 {
+// UpdateAnalytics applies aggregated transaction updates and their activity buckets
+// for multiple users atomically.
+func (r *AnalyticsRepo) UpdateAnalytics(ctx context.Context, updates map[string]*models.UserAnalytics, activity []models.ActivityBucket) error {
+	ctx, span := tracer.Start(ctx, "AnalyticsRepo.UpdateAnalytics")
+	defer span.End()
+
+	if len(updates) == 0 && len(activity) == 0 {
 		return nil
 	}
 
 	tx, err := r.db.Beginx()
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return tracing.Error(span, fmt.Errorf("begin transaction: %w", err))
 	}
 
 	defer func() {
 		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
-			fmt.Printf("rollback error: %v\n", err)
+			logger.WithTrace(ctx, r.logger).Error("failed to roll back transaction", "error", err)
 		}
 	}()
 
 	// This query handles both new and existing users atomically
 	query := `
-    INSERT INTO user_analytics (user_id, total_orders, total_spent)
-    VALUES ($1, $2, $3)
+    INSERT INTO user_analytics (user_id, total_orders, total_spent, first_order_at, last_order_at)
+    VALUES ($1, $2, $3, $4, $5)
     ON CONFLICT(user_id) DO UPDATE SET
         total_orders = user_analytics.total_orders + EXCLUDED.total_orders,
-        total_spent = user_analytics.total_spent + EXCLUDED.total_spent
+        total_spent = user_analytics.total_spent + EXCLUDED.total_spent,
+        first_order_at = LEAST(user_analytics.first_order_at, EXCLUDED.first_order_at),
+        last_order_at = GREATEST(user_analytics.last_order_at, EXCLUDED.last_order_at)
     `
 
 	stmt, err := tx.Preparex(query)
 	if err != nil {
-		return fmt.Errorf("prepare statement: %w", err)
+		return tracing.Error(span, fmt.Errorf("prepare statement: %w", err))
 	}
 	defer stmt.Close()
 
 	for _, analytics := range updates {
 		// Check if context was cancelled
 		if err := ctx.Err(); err != nil {
-			return fmt.Errorf("context cancelled: %w", err)
+			return tracing.Error(span, fmt.Errorf("context cancelled: %w", err))
 		}
 
-		if _, err := stmt.ExecContext(ctx, analytics.UserID, analytics.TotalOrders, analytics.TotalSpent); err != nil {
-			return fmt.Errorf("exec update for user %s: %w", analytics.UserID, err)
+		if _, err := stmt.ExecContext(ctx, analytics.UserID, analytics.TotalOrders, analytics.TotalSpent, analytics.FirstOrderAt, analytics.LastOrderAt); err != nil {
+			return tracing.Error(span, fmt.Errorf("exec update for user %s: %w", analytics.UserID, err))
 		}
 	}
 
-	return tx.Commit()
+	if len(activity) > 0 {
+		activityStmt, err := tx.Preparex(`
+        INSERT INTO user_activity (user_id, bucket_start, orders, spent)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT(user_id, bucket_start) DO UPDATE SET
+            orders = user_activity.orders + EXCLUDED.orders,
+            spent = user_activity.spent + EXCLUDED.spent
+        `)
+		if err != nil {
+			return tracing.Error(span, fmt.Errorf("prepare activity statement: %w", err))
+		}
+		defer activityStmt.Close()
+
+		for _, bucket := range activity {
+			if err := ctx.Err(); err != nil {
+				return tracing.Error(span, fmt.Errorf("context cancelled: %w", err))
+			}
+
+			if _, err := activityStmt.ExecContext(ctx, bucket.UserID, bucket.BucketStart, bucket.Orders, bucket.Spent); err != nil {
+				return tracing.Error(span, fmt.Errorf("exec activity for user %s: %w", bucket.UserID, err))
+			}
+		}
+	}
+
+	return tracing.Error(span, tx.Commit())
 }
 
 // UserAnalytics retrieves analytics for a specific user
 func (r *AnalyticsRepo) UserAnalytics(ctx context.Context, userID string) (*models.UserAnalytics, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsRepo.UserAnalytics")
+	defer span.End()
+
 	if userID == "" {
-		return nil, fmt.Errorf("userID cannot be empty")
+		return nil, tracing.Error(span, fmt.Errorf("userID cannot be empty"))
 	}
 
 	var analytics models.UserAnalytics
-	query := "SELECT user_id, total_orders, total_spent FROM user_analytics WHERE user_id = $1"
+	query := "SELECT user_id, total_orders, total_spent, first_order_at, last_order_at FROM user_analytics WHERE user_id = $1"
 
 	if err := r.db.GetContext(ctx, &analytics, query, userID); err != nil {
 		if err == sql.ErrNoRows {
-			return &models.UserAnalytics{UserID: userID, TotalOrders: 0, TotalSpent: 0}, nil
+			return nil, models.ErrUserNotFound
 		}
-		return nil, fmt.Errorf("select user analytics: %w", err)
+		return nil, tracing.Error(span, fmt.Errorf("select user analytics: %w", err))
 	}
 
 	return &analytics, nil
 }
 
-// TopUsers returns top users ordered by total orders.
-func (r *AnalyticsRepo) TopUsers(ctx context.Context, limit int) ([]models.UserAnalytics, error) {
-	if limit <= 0 {
-		return nil, fmt.Errorf("limit must be positive, got %d", limit)
+// rankColumns maps a ranking onto the column it sorts by.
+var rankColumns = map[models.RankBy]string{
+	models.RankByOrders: "total_orders",
+	models.RankBySpend:  "total_spent",
+}
+
+// UserProfiles returns profiles for the given users, skipping any with no
+// analytics. Ranks and percentiles are taken over every user; anomaly status
+// uses the same thresholds as UserAnomalies.
+func (r *AnalyticsRepo) UserProfiles(ctx context.Context, userIDs []string) ([]models.UserProfile, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsRepo.UserProfiles")
+	defer span.End()
+
+	if len(userIDs) == 0 {
+		return nil, nil
 	}
-	var users []models.UserAnalytics
+
+	// Ranks and percentiles come from one pass over the table, however many
+	// users are asked for; the filter applies after the window functions
 	query := `
-    SELECT user_id, total_orders, total_spent 
-    FROM user_analytics 
-    ORDER BY total_orders DESC 
-    LIMIT $1
+    WITH stats AS (
+        SELECT
+            AVG(total_orders)::FLOAT AS avg_orders,
+            STDDEV(total_orders)::FLOAT AS stddev_orders,
+            AVG(total_spent)::FLOAT AS avg_spent,
+            STDDEV(total_spent)::FLOAT AS stddev_spent
+        FROM user_analytics
+        WHERE total_orders > 0
+    ),
+    ranked AS (
+        SELECT
+            user_id,
+            total_orders,
+            total_spent,
+            first_order_at,
+            last_order_at,
+            last_updated,
+            rank() OVER (ORDER BY total_orders DESC) AS orders_rank,
+            rank() OVER (ORDER BY total_spent DESC) AS spend_rank,
+            (cume_dist() OVER (ORDER BY total_orders) * 100)::FLOAT AS orders_percentile,
+            (cume_dist() OVER (ORDER BY total_spent) * 100)::FLOAT AS spend_percentile,
+            COUNT(*) OVER () AS population
+        FROM user_analytics
+    )
+    SELECT
+        u.user_id,
+        u.total_orders,
+        u.total_spent::FLOAT AS total_spent,
+        COALESCE(u.total_spent / NULLIF(u.total_orders, 0), 0)::FLOAT AS avg_order_value,
+        u.first_order_at,
+        u.last_order_at,
+        u.last_updated,
+        u.orders_rank,
+        u.spend_rank,
+        u.orders_percentile,
+        u.spend_percentile,
+        u.population,
+        COALESCE(u.total_orders > stats.avg_orders + 2 * stats.stddev_orders, FALSE) AS order_anomaly,
+        COALESCE(u.total_spent > stats.avg_spent + 2 * stats.stddev_spent, FALSE) AS spending_anomaly
+    FROM ranked u, stats
+    WHERE u.user_id = ANY($1)
+    ORDER BY u.user_id
     `
 
-	if err := r.db.SelectContext(ctx, &users, query, limit); err != nil {
-		return nil, fmt.Errorf("select top users: %w", err)
+	var rows []struct {
+		UserID           string     `json:"user_id"`
+		TotalOrders      int        `json:"total_orders"`
+		TotalSpent       float64    `json:"total_spent"`
+		AvgOrderValue    float64    `json:"avg_order_value"`
+		FirstOrderAt     *time.Time `json:"first_order_at"`
+		LastOrderAt      *time.Time `json:"last_order_at"`
+		LastUpdated      time.Time  `json:"last_updated"`
+		OrdersRank       int        `json:"orders_rank"`
+		SpendRank        int        `json:"spend_rank"`
+		OrdersPercentile float64    `json:"orders_percentile"`
+		SpendPercentile  float64    `json:"spend_percentile"`
+		Population       int        `json:"population"`
+		OrderAnomaly     bool       `json:"order_anomaly"`
+		SpendingAnomaly  bool       `json:"spending_anomaly"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(userIDs)); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("select user profiles: %w", err))
+	}
+
+	profiles := make([]models.UserProfile, len(rows))
+	for i, row := range rows {
+		profiles[i] = models.UserProfile{
+			UserID:          row.UserID,
+			TotalOrders:     row.TotalOrders,
+			TotalSpent:      row.TotalSpent,
+			AvgOrderValue:   row.AvgOrderValue,
+			FirstOrderAt:    row.FirstOrderAt,
+			LastOrderAt:     row.LastOrderAt,
+			LastUpdated:     row.LastUpdated,
+			Rank:            models.ProfileRanks{Orders: row.OrdersRank, Spend: row.SpendRank},
+			Percentile:      models.ProfilePercentiles{Orders: row.OrdersPercentile, Spend: row.SpendPercentile},
+			Population:      row.Population,
+			OrderAnomaly:    row.OrderAnomaly,
+			SpendingAnomaly: row.SpendingAnomaly,
+		}
 	}
 
-	return users, nil
+	return profiles, nil
+}
+
+// sortColumn is the SQL expression a listing sorts by and the type its
+// cursor value is cast back to.
+type sortColumn struct {
+	expr string
+	cast string
+}
+
+// sortColumns maps a sort key onto its expression. Average order value has no
+// index, so sorting by it scans the filtered rows.
+var sortColumns = map[models.SortKey]sortColumn{
+	models.SortByOrders:        {expr: "total_orders", cast: "INTEGER"},
+	models.SortBySpend:         {expr: "total_spent", cast: "NUMERIC"},
+	models.SortByAvgOrderValue: {expr: "COALESCE(total_spent / NULLIF(total_orders, 0), 0)", cast: "NUMERIC"},
+	models.SortByLastUpdated:   {expr: "last_updated", cast: "TIMESTAMP"},
+}
+
+// TopUsers returns one page of users sorted highest first, ties broken by
+// user ID, after applying the query's filters. Pages continue from the
+// query's cursor by keyset, so they stay consistent while totals change.
+func (r *AnalyticsRepo) TopUsers(ctx context.Context, query models.UserQuery) (*models.UserPage, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsRepo.TopUsers")
+	defer span.End()
+
+	if query.Limit <= 0 {
+		return nil, tracing.Error(span, fmt.Errorf("limit must be positive, got %d", query.Limit))
+	}
+	column, ok := sortColumns[query.Sort]
+	if !ok {
+		return nil, tracing.Error(span, fmt.Errorf("unknown sort key %q", query.Sort))
+	}
+
+	var (
+		conds []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if after := query.After; after != nil {
+		if after.Sort != query.Sort {
+			return nil, tracing.Error(span, fmt.Errorf("cursor is for sort key %q, not %q", after.Sort, query.Sort))
+		}
+		conds = append(conds, fmt.Sprintf("(%s, user_id) < (%s::%s, %s)", column.expr, arg(after.Value), column.cast, arg(after.UserID)))
+	}
+	conds = append(conds, filterConditions(query.Filter, arg)...)
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	// One extra row tells us whether there is a next page
+	sqlQuery := fmt.Sprintf(`
+    SELECT
+        user_id,
+        total_orders,
+        total_spent,
+        COALESCE(total_spent / NULLIF(total_orders, 0), 0)::FLOAT AS avg_order_value,
+        last_updated,
+        (%[1]s)::TEXT AS sort_value
+    FROM user_analytics
+    %[2]s
+    ORDER BY %[1]s DESC, user_id DESC
+    LIMIT %[3]s
+    `, column.expr, where, arg(query.Limit+1))
+
+	var rows []struct {
+		models.UserSummary
+		SortValue string `json:"sort_value"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, sqlQuery, args...); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("select top users: %w", err))
+	}
+
+	page := &models.UserPage{}
+	if len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+		last := rows[len(rows)-1]
+		page.Next = &models.Cursor{Sort: query.Sort, Value: last.SortValue, UserID: last.UserID}
+	}
+	page.Users = make([]models.UserSummary, len(rows))
+	for i, row := range rows {
+		page.Users[i] = row.UserSummary
+	}
+
+	return page, nil
+}
+
+// filterConditions renders the filter's bounds as SQL conditions, binding
+// values through arg
+func filterConditions(filter models.UserFilter, arg func(any) string) []string {
+	var conds []string
+	if filter.MinOrders != nil {
+		conds = append(conds, "total_orders >= "+arg(*filter.MinOrders))
+	}
+	if filter.MaxOrders != nil {
+		conds = append(conds, "total_orders <= "+arg(*filter.MaxOrders))
+	}
+	if filter.MinSpent != nil {
+		conds = append(conds, "total_spent >= "+arg(*filter.MinSpent))
+	}
+	if filter.MaxSpent != nil {
+		conds = append(conds, "total_spent <= "+arg(*filter.MaxSpent))
+	}
+	if filter.UpdatedSince != nil {
+		conds = append(conds, "last_updated >= "+arg(*filter.UpdatedSince))
+	}
+	return conds
+}
+
+// exportFetchSize is how many rows each FETCH from an export cursor returns
+const exportFetchSize = 1000
+
+// ExportUsers streams every user matching the filter, ordered by user ID.
+// Rows come from a server-side cursor in a read-only snapshot, so memory stays
+// flat and the export is consistent however long it takes to consume.
+func (r *AnalyticsRepo) ExportUsers(ctx context.Context, filter models.UserFilter) iter.Seq2[models.UserSummary, error] {
+	return func(yield func(models.UserSummary, error) bool) {
+		ctx, span := tracer.Start(ctx, "AnalyticsRepo.ExportUsers")
+		defer span.End()
+
+		tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err != nil {
+			yield(models.UserSummary{}, tracing.Error(span, fmt.Errorf("begin export transaction: %w", err)))
+			return
+		}
+		defer tx.Rollback()
+
+		var args []any
+		arg := func(v any) string {
+			args = append(args, v)
+			return fmt.Sprintf("$%d", len(args))
+		}
+		where := ""
+		if conds := filterConditions(filter, arg); len(conds) > 0 {
+			where = "WHERE " + strings.Join(conds, " AND ")
+		}
+
+		declare := fmt.Sprintf(`
+        DECLARE user_export NO SCROLL CURSOR FOR
+        SELECT
+            user_id,
+            total_orders,
+            total_spent,
+            COALESCE(total_spent / NULLIF(total_orders, 0), 0)::FLOAT AS avg_order_value,
+            first_order_at,
+            last_order_at,
+            last_updated
+        FROM user_analytics
+        %s
+        ORDER BY user_id
+        `, where)
+		if _, err := tx.ExecContext(ctx, declare, args...); err != nil {
+			yield(models.UserSummary{}, tracing.Error(span, fmt.Errorf("declare export cursor: %w", err)))
+			return
+		}
+
+		exported := 0
+		fetch := fmt.Sprintf("FETCH FORWARD %d FROM user_export", exportFetchSize)
+		for {
+			var users []models.UserSummary
+			if err := tx.SelectContext(ctx, &users, fetch); err != nil {
+				yield(models.UserSummary{}, tracing.Error(span, fmt.Errorf("fetch export rows: %w", err)))
+				return
+			}
+
+			for _, user := range users {
+				if !yield(user, nil) {
+					return
+				}
+			}
+			exported += len(users)
+			span.SetAttributes(attribute.Int("export.users", exported))
+
+			if len(users) < exportFetchSize {
+				return
+			}
+		}
+	}
+}
+
+// UserRank returns the user's 1-based rank, or nil if the user has no analytics.
+// Users with equal scores share a rank.
+func (r *AnalyticsRepo) UserRank(ctx context.Context, by models.RankBy, userID string) (*models.UserRank, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsRepo.UserRank")
+	defer span.End()
+
+	if userID == "" {
+		return nil, tracing.Error(span, fmt.Errorf("userID cannot be empty"))
+	}
+	column, ok := rankColumns[by]
+	if !ok {
+		return nil, tracing.Error(span, fmt.Errorf("unknown ranking %q", by))
+	}
+
+	query := fmt.Sprintf(`
+    SELECT
+        u.%[1]s::FLOAT AS score,
+        (SELECT COUNT(*) FROM user_analytics o WHERE o.%[1]s > u.%[1]s) + 1 AS rank
+    FROM user_analytics u
+    WHERE u.user_id = $1
+    `, column)
+
+	rank := models.UserRank{UserID: userID, By: by}
+	if err := r.db.GetContext(ctx, &rank, query, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, tracing.Error(span, fmt.Errorf("select user rank: %w", err))
+	}
+
+	return &rank, nil
+}
+
+// AllUsers streams every user's analytics without loading the table into memory.
+func (r *AnalyticsRepo) AllUsers(ctx context.Context) iter.Seq2[models.UserAnalytics, error] {
+	return func(yield func(models.UserAnalytics, error) bool) {
+		rows, err := r.db.QueryxContext(ctx, "SELECT user_id, total_orders, total_spent FROM user_analytics")
+		if err != nil {
+			yield(models.UserAnalytics{}, fmt.Errorf("select all users: %w", err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var analytics models.UserAnalytics
+			if err := rows.StructScan(&analytics); err != nil {
+				yield(models.UserAnalytics{}, fmt.Errorf("scan user analytics: %w", err))
+				return
+			}
+			if !yield(analytics, nil) {
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			yield(models.UserAnalytics{}, fmt.Errorf("iterate users: %w", err))
+		}
+	}
 }
 
 // UserAnomalies returns users with anomalous activity based on order/spend deviation.
 func (r *AnalyticsRepo) UserAnomalies(ctx context.Context) ([]models.AnomalyUser, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsRepo.UserAnomalies")
+	defer span.End()
+
 	query := `
     WITH stats AS (
         SELECT 
@@ -131,7 +506,168 @@ func (r *AnalyticsRepo) UserAnomalies(ctx context.Context) ([]models.AnomalyUser
 
 	var anomalies []models.AnomalyUser
 	if err := r.db.SelectContext(ctx, &anomalies, query); err != nil {
-		return nil, fmt.Errorf("select anomalies: %w", err)
+		return nil, tracing.Error(span, fmt.Errorf("select anomalies: %w", err))
+	}
+	return anomalies, nil
+}
+
+// UserVelocity returns a user's activity within the trailing window ending
+// at anchor: the current activity bucket, or the user's latest one so the
+// result stays meaningful for historical loads.
+func (r *AnalyticsRepo) UserVelocity(ctx context.Context, userID string, window time.Duration, anchor models.VelocityAnchor) (*models.UserVelocity, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsRepo.UserVelocity")
+	defer span.End()
+
+	if userID == "" {
+		return nil, tracing.Error(span, fmt.Errorf("userID cannot be empty"))
+	}
+	if window < models.ActivityBucketSize {
+		return nil, tracing.Error(span, fmt.Errorf("window must be at least %s, got %s", models.ActivityBucketSize, window))
+	}
+	if !anchor.Valid() {
+		return nil, tracing.Error(span, fmt.Errorf("unknown velocity anchor %q", anchor))
+	}
+
+	// window_end is the end of the anchoring bucket; the window holds the
+	// buckets starting within window of it
+	query := `
+    WITH anchor AS (
+        SELECT CASE
+            WHEN $3::BOOLEAN THEN (SELECT MAX(bucket_start) FROM user_activity WHERE user_id = $1)
+            ELSE to_timestamp(floor(extract(epoch FROM now()) / $4::FLOAT) * $4::FLOAT)
+        END + $4::FLOAT * INTERVAL '1 second' AS window_end
+    )
+    SELECT
+        anchor.window_end,
+        COALESCE(SUM(a.orders), 0) AS orders,
+        COALESCE(SUM(a.spent), 0)::FLOAT AS spent
+    FROM anchor
+    LEFT JOIN user_activity a
+        ON a.user_id = $1
+       AND a.bucket_start >= anchor.window_end - $2::FLOAT * INTERVAL '1 second'
+       AND a.bucket_start < anchor.window_end
+    GROUP BY anchor.window_end
+    `
+
+	var row struct {
+		WindowEnd sql.NullTime `json:"window_end"`
+		Orders    int          `json:"orders"`
+		Spent     float64      `json:"spent"`
+	}
+	bucket := int64(models.ActivityBucketSize / time.Second)
+	if err := r.db.GetContext(ctx, &row, query, userID, int64(window/time.Second), anchor == models.AnchorLatest, bucket); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("select user velocity: %w", err))
+	}
+
+	velocity := &models.UserVelocity{
+		UserID: userID,
+		Window: models.Duration(window),
+		Anchor: anchor,
+		Orders: row.Orders,
+		Spent:  row.Spent,
+	}
+	if row.WindowEnd.Valid {
+		velocity.WindowEnd = &row.WindowEnd.Time
+	}
+
+	return velocity, nil
+}
+
+// VelocityAnomalies returns users whose busiest sliding window ending within
+// the rule's scan period broke the rule, reporting that window for each user.
+// Older bursts are left out, as are the buckets that can't reach a window in
+// the period, so the bucket_start index bounds the scan.
+func (r *AnalyticsRepo) VelocityAnomalies(ctx context.Context, rule models.VelocityRule) ([]models.VelocityAnomaly, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsRepo.VelocityAnomalies")
+	defer span.End()
+
+	if rule.Window < models.ActivityBucketSize {
+		return nil, tracing.Error(span, fmt.Errorf("window must be at least %s, got %s", models.ActivityBucketSize, rule.Window))
+	}
+	if rule.OrderThreshold <= 0 && rule.SpendThreshold <= 0 {
+		return nil, tracing.Error(span, fmt.Errorf("velocity rule needs an order or spend threshold"))
+	}
+
+	// The frame covers the current bucket plus the preceding (window - bucket);
+	// windows end within the scan period ($5), so they read buckets back to
+	// the period plus one frame
+	query := `
+    WITH windows AS (
+        SELECT
+            user_id,
+            bucket_start,
+            SUM(orders) OVER w AS orders,
+            SUM(spent) OVER w AS spent
+        FROM user_activity
+        WHERE bucket_start >= now() - ($5::FLOAT + $1::FLOAT) * INTERVAL '1 second'
+        WINDOW w AS (
+            PARTITION BY user_id
+            ORDER BY bucket_start
+            RANGE BETWEEN $1::FLOAT * INTERVAL '1 second' PRECEDING AND CURRENT ROW
+        )
+    ),
+    flagged AS (
+        SELECT DISTINCT ON (user_id)
+            user_id,
+            bucket_start + $4::FLOAT * INTERVAL '1 second' AS window_end,
+            orders,
+            spent::FLOAT AS spent,
+            ($2::INTEGER > 0 AND orders >= $2::INTEGER) AS order_anomaly,
+            ($3::FLOAT > 0 AND spent >= $3::FLOAT) AS spending_anomaly
+        FROM windows
+        WHERE bucket_start >= now() - $5::FLOAT * INTERVAL '1 second'
+          AND (($2::INTEGER > 0 AND orders >= $2::INTEGER) OR ($3::FLOAT > 0 AND spent >= $3::FLOAT))
+        ORDER BY user_id, orders DESC, spent DESC
+    )
+    SELECT * FROM flagged
+    ORDER BY orders DESC, spent DESC
+    `
+
+	preceding := int64((rule.Window - models.ActivityBucketSize) / time.Second)
+	bucket := int64(models.ActivityBucketSize / time.Second)
+	period := int64(rule.ScanPeriod() / time.Second)
+
+	var anomalies []models.VelocityAnomaly
+	if err := r.db.SelectContext(ctx, &anomalies, query, preceding, rule.OrderThreshold, rule.SpendThreshold, bucket, period); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("select velocity anomalies: %w", err))
 	}
 	return anomalies, nil
 }
+
+// pruneBatch is how many activity buckets PruneActivity deletes per statement,
+// keeping each delete's locks and WAL short
+const pruneBatch = 10000
+
+// PruneActivity deletes activity buckets that started before the cutoff, a
+// batch at a time, and returns how many it deleted. Stopping between batches
+// keeps what was already deleted.
+func (r *AnalyticsRepo) PruneActivity(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsRepo.PruneActivity")
+	defer span.End()
+
+	query := `
+    DELETE FROM user_activity
+    WHERE ctid IN (
+        SELECT ctid FROM user_activity
+        WHERE bucket_start < $1
+        LIMIT $2
+    )
+    `
+
+	var deleted int64
+	for {
+		result, err := r.db.ExecContext(ctx, query, before.UTC(), pruneBatch)
+		if err != nil {
+			return deleted, tracing.Error(span, fmt.Errorf("delete activity: %w", err))
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return deleted, tracing.Error(span, fmt.Errorf("delete activity: %w", err))
+		}
+		deleted += n
+		if n < pruneBatch {
+			span.SetAttributes(attribute.Int64("activity.deleted", deleted))
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	analyticsv1 "tx-processor/api/analytics/v1"
+	"tx-processor/auth"
+	"tx-processor/logger"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methodScopes is the scope each RPC requires; methods not listed need admin
+var methodScopes = map[string]auth.Scope{
+	analyticsv1.AnalyticsService_GetUserAnalytics_FullMethodName:      auth.ScopeReadAnalytics,
+	analyticsv1.AnalyticsService_ListTopUsers_FullMethodName:          auth.ScopeReadAnalytics,
+	analyticsv1.AnalyticsService_WatchEvents_FullMethodName:           auth.ScopeReadAnalytics,
+	analyticsv1.AnalyticsService_ListAnomalies_FullMethodName:         auth.ScopeReadAnomalies,
+	analyticsv1.AnalyticsService_ListVelocityAnomalies_FullMethodName: auth.ScopeReadAnomalies,
+	analyticsv1.AnalyticsService_PushTransactions_FullMethodName:      auth.ScopeWriteTransactions,
+}
+
+// AuthInterceptors authenticate each call from its authorization or x-api-key
+// metadata and enforce the scope its method requires
+func AuthInterceptors(a *auth.Authenticator, log logger.Logger) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, a, log, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), a, log, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+	return unary, stream
+}
+
+func authorize(ctx context.Context, a *auth.Authenticator, log logger.Logger, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id, err := a.Authenticate(ctx, first(md, "authorization"), first(md, "x-api-key"))
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		return nil, status.Error(codes.Unauthenticated, "credentials are required")
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		return nil, status.Error(codes.Unauthenticated, "credentials were rejected")
+	case err != nil:
+		logger.WithTrace(ctx, log).Error("failed to authenticate call", "method", method, "error", err)
+		return nil, status.Error(codes.Unavailable, "authentication is unavailable")
+	}
+
+	scope, ok := methodScopes[method]
+	if !ok {
+		scope = auth.ScopeAdmin
+	}
+	if !id.Has(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "scope %s is required", scope)
+	}
+
+	ctx = auth.WithIdentity(ctx, id)
+	ctx = logger.ContextWith(ctx, "caller", id.Subject, "auth_method", id.Method)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("enduser.id", id.Subject))
+	return ctx, nil
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// contextStream replaces a stream's context with one carrying the caller
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
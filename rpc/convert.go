@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"time"
+	analyticsv1 "tx-processor/api/analytics/v1"
+	"tx-processor/models"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var sortKeys = map[analyticsv1.SortKey]models.SortKey{
+	analyticsv1.SortKey_SORT_KEY_UNSPECIFIED:     models.SortByOrders,
+	analyticsv1.SortKey_SORT_KEY_ORDERS:          models.SortByOrders,
+	analyticsv1.SortKey_SORT_KEY_SPEND:           models.SortBySpend,
+	analyticsv1.SortKey_SORT_KEY_AVG_ORDER_VALUE: models.SortByAvgOrderValue,
+	analyticsv1.SortKey_SORT_KEY_LAST_UPDATED:    models.SortByLastUpdated,
+}
+
+var rankBys = map[analyticsv1.RankBy]models.RankBy{
+	analyticsv1.RankBy_RANK_BY_UNSPECIFIED: "",
+	analyticsv1.RankBy_RANK_BY_ORDERS:      models.RankByOrders,
+	analyticsv1.RankBy_RANK_BY_SPEND:       models.RankBySpend,
+}
+
+var eventTypes = map[analyticsv1.EventType]models.EventType{
+	analyticsv1.EventType_EVENT_TYPE_ANALYTICS:   models.EventAnalytics,
+	analyticsv1.EventType_EVENT_TYPE_LEADERBOARD: models.EventLeaderboard,
+}
+
+func rankByProto(by models.RankBy) analyticsv1.RankBy {
+	switch by {
+	case models.RankByOrders:
+		return analyticsv1.RankBy_RANK_BY_ORDERS
+	case models.RankBySpend:
+		return analyticsv1.RankBy_RANK_BY_SPEND
+	}
+	return analyticsv1.RankBy_RANK_BY_UNSPECIFIED
+}
+
+// timestampProto converts an optional time, leaving the field unset for nil
+func timestampProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func userAnalyticsProto(analytics *models.UserAnalytics) *analyticsv1.UserAnalytics {
+	return &analyticsv1.UserAnalytics{
+		UserId:       analytics.UserID,
+		TotalOrders:  int64(analytics.TotalOrders),
+		TotalSpent:   analytics.TotalSpent,
+		FirstOrderAt: timestampProto(analytics.FirstOrderAt),
+		LastOrderAt:  timestampProto(analytics.LastOrderAt),
+	}
+}
+
+func userSummariesProto(users []models.UserSummary) []*analyticsv1.UserSummary {
+	out := make([]*analyticsv1.UserSummary, len(users))
+	for i, user := range users {
+		out[i] = &analyticsv1.UserSummary{
+			UserId:        user.UserID,
+			TotalOrders:   int64(user.TotalOrders),
+			TotalSpent:    user.TotalSpent,
+			FirstOrderAt:  timestampProto(user.FirstOrderAt),
+			LastOrderAt:   timestampProto(user.LastOrderAt),
+			AvgOrderValue: user.AvgOrderValue,
+			LastUpdated:   timestampProto(user.LastUpdated),
+		}
+	}
+	return out
+}
+
+func anomaliesProto(anomalies []models.AnomalyUser) []*analyticsv1.AnomalyUser {
+	out := make([]*analyticsv1.AnomalyUser, len(anomalies))
+	for i, anomaly := range anomalies {
+		out[i] = &analyticsv1.AnomalyUser{
+			UserId:          anomaly.UserID,
+			TotalOrders:     int64(anomaly.TotalOrders),
+			TotalSpent:      anomaly.TotalSpent,
+			OrderAnomaly:    anomaly.OrderAnomaly,
+			SpendingAnomaly: anomaly.SpendingAnomaly,
+		}
+	}
+	return out
+}
+
+func velocityAnomaliesProto(rule models.VelocityRule, anomalies []models.VelocityAnomaly) *analyticsv1.ListVelocityAnomaliesResponse {
+	resp := &analyticsv1.ListVelocityAnomaliesResponse{
+		Window:    durationpb.New(rule.Window),
+		MinOrders: int64(rule.OrderThreshold),
+		MinSpent:  rule.SpendThreshold,
+		Anomalies: make([]*analyticsv1.VelocityAnomaly, len(anomalies)),
+	}
+	for i, anomaly := range anomalies {
+		resp.Anomalies[i] = &analyticsv1.VelocityAnomaly{
+			UserId:          anomaly.UserID,
+			WindowEnd:       timestamppb.New(anomaly.WindowEnd),
+			Orders:          int64(anomaly.Orders),
+			Spent:           anomaly.Spent,
+			OrderAnomaly:    anomaly.OrderAnomaly,
+			SpendingAnomaly: anomaly.SpendingAnomaly,
+		}
+	}
+	return resp
+}
+
+func eventProto(event models.Event) *analyticsv1.Event {
+	out := &analyticsv1.Event{Id: event.ID, At: timestamppb.New(event.At)}
+	switch event.Type {
+	case models.EventAnalytics:
+		deltas := make([]*analyticsv1.UserDelta, len(event.Deltas))
+		for i, delta := range event.Deltas {
+			deltas[i] = &analyticsv1.UserDelta{UserId: delta.UserID, Orders: int64(delta.Orders), Spent: delta.Spent}
+		}
+		out.Payload = &analyticsv1.Event_Analytics{Analytics: &analyticsv1.AnalyticsUpdate{Deltas: deltas}}
+	case models.EventLeaderboard:
+		change := &analyticsv1.LeaderboardChange{}
+		if event.Leaderboard != nil {
+			change.By = rankByProto(event.Leaderboard.By)
+			change.Users = userSummariesProto(event.Leaderboard.Users)
+		}
+		out.Payload = &analyticsv1.Event_Leaderboard{Leaderboard: change}
+	}
+	return out
+}
+
+func droppedProto(count uint64) *analyticsv1.Event {
+	return &analyticsv1.Event{
+		At:      timestamppb.Now(),
+		Payload: &analyticsv1.Event_Dropped{Dropped: &analyticsv1.EventsDropped{Count: count}},
+	}
+}
+
+func transactionModel(tx *analyticsv1.Transaction) models.Transaction {
+	out := models.Transaction{
+		OrderID:   tx.GetOrderId(),
+		UserID:    tx.GetUserId(),
+		ProductID: tx.GetProductId(),
+		Quantity:  int(tx.GetQuantity()),
+		Price:     tx.GetPrice(),
+	}
+	if tx.Timestamp != nil {
+		out.Timestamp = tx.Timestamp.AsTime()
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	analyticsv1 "tx-processor/api/analytics/v1"
+	"tx-processor/auth"
+	"tx-processor/logger"
+	"tx-processor/ratelimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// methodCosts is what each RPC spends from the caller's bucket, matching the
+// HTTP routes; methods not listed cost one token. Streams are charged once,
+// when they open.
+var methodCosts = map[string]int{
+	analyticsv1.AnalyticsService_ListAnomalies_FullMethodName:         10,
+	analyticsv1.AnalyticsService_ListVelocityAnomalies_FullMethodName: 5,
+}
+
+// RateLimitInterceptors meter callers with limiter. They must run after the
+// auth interceptors so authenticated callers share one bucket with their HTTP
+// requests; anonymous callers are metered by peer IP.
+func RateLimitInterceptors(limiter ratelimit.Limiter, log logger.Logger) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := take(ctx, limiter, log, info.FullMethod, grpc.SetHeader); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		setHeader := func(_ context.Context, md metadata.MD) error { return ss.SetHeader(md) }
+		if err := take(ss.Context(), limiter, log, info.FullMethod, setHeader); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+	return unary, stream
+}
+
+func take(ctx context.Context, limiter ratelimit.Limiter, log logger.Logger, method string, setHeader func(context.Context, metadata.MD) error) error {
+	cost, ok := methodCosts[method]
+	if !ok {
+		cost = 1
+	}
+	key := ratelimit.Key(auth.FromContext(ctx), peerIP(ctx))
+
+	res, err := limiter.Take(ctx, key, cost)
+	if err != nil {
+		// Fail open, as the HTTP routes do
+		logger.WithTrace(ctx, log).Error("rate limiter unavailable", "key", key, "error", err)
+		return nil
+	}
+
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(res.Limit),
+		"ratelimit-remaining", strconv.Itoa(res.Remaining),
+		"ratelimit-reset", strconv.Itoa(ceilSeconds(res.Reset.Seconds())),
+	)
+	if !res.Allowed {
+		retry := ceilSeconds(res.RetryAfter.Seconds())
+		md.Set("retry-after", strconv.Itoa(retry))
+		setHeader(ctx, md)
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %ds", retry)
+	}
+	setHeader(ctx, md)
+	return nil
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func ceilSeconds(s float64) int {
+	return int(math.Ceil(s))
+}
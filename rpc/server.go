@@ -0,0 +1,290 @@
+// Package rpc serves the analytics API over gRPC, backed by the same service
+// as the HTTP handlers. The service definition lives in api/analytics/v1.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	analyticsv1 "tx-processor/api/analytics/v1"
+	"tx-processor/config"
+	"tx-processor/events"
+	"tx-processor/logger"
+	"tx-processor/models"
+	"tx-processor/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultLimit and maxLimit bound a ListTopUsers page, as on /v1/leaderboard
+	defaultLimit = 10
+	maxLimit     = 1000
+	// maxEventUsers bounds the user IDs one WatchEvents call may filter on
+	maxEventUsers = 1000
+)
+
+// Ingester commits decoded transactions; *processor.Processor implements it
+type Ingester interface {
+	ProcessBatch(ctx context.Context, txs []models.Transaction) error
+}
+
+type AnalyticsServer struct {
+	analyticsv1.UnimplementedAnalyticsServiceServer
+
+	analyticsService *services.AnalyticsService
+	cfg              *config.Config
+	store            *config.Store
+	logger           logger.Logger
+	events           *events.Broker
+	ingester         Ingester
+
+	drainOnce sync.Once
+	draining  chan struct{}
+}
+
+// Option configures optional AnalyticsServer dependencies
+type Option func(*AnalyticsServer)
+
+// WithEvents serves WatchEvents from the given broker
+func WithEvents(broker *events.Broker) Option {
+	return func(s *AnalyticsServer) {
+		s.events = broker
+	}
+}
+
+// WithIngester accepts PushTransactions through the given ingester
+func WithIngester(ingester Ingester) Option {
+	return func(s *AnalyticsServer) {
+		s.ingester = ingester
+	}
+}
+
+// WithSettings reads reloadable settings, such as the default velocity rule,
+// from store rather than the configuration the server was built with
+func WithSettings(store *config.Store) Option {
+	return func(s *AnalyticsServer) {
+		s.store = store
+	}
+}
+
+func NewAnalyticsServer(analyticsService *services.AnalyticsService, cfg *config.Config, logger logger.Logger, opts ...Option) *AnalyticsServer {
+	s := &AnalyticsServer{
+		analyticsService: analyticsService,
+		cfg:              cfg,
+		logger:           logger,
+		draining:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// settings returns the configuration in effect for a call
+func (s *AnalyticsServer) settings() *config.Config {
+	if s.store != nil {
+		return s.store.Current()
+	}
+	return s.cfg
+}
+
+// Drain ends open PushTransactions streams early: each commits what it has
+// received and responds with the count, so clients can resume from there
+// once the server has stopped
+func (s *AnalyticsServer) Drain() {
+	s.drainOnce.Do(func() {
+		close(s.draining)
+	})
+}
+
+// Register adds the analytics service to a gRPC server
+func (s *AnalyticsServer) Register(r grpc.ServiceRegistrar) {
+	analyticsv1.RegisterAnalyticsServiceServer(r, s)
+}
+
+func (s *AnalyticsServer) GetUserAnalytics(ctx context.Context, req *analyticsv1.GetUserAnalyticsRequest) (*analyticsv1.UserAnalytics, error) {
+	log := logger.WithTrace(ctx, s.logger)
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	analytics, err := s.analyticsService.FindUserAnalytics(ctx, req.GetUserId())
+	if errors.Is(err, services.ErrUserNotFound) {
+		return nil, status.Errorf(codes.NotFound, "user %s not found", req.GetUserId())
+	}
+	if err != nil {
+		log.Error("failed to get user analytics", "user_id", req.GetUserId(), "error", err)
+		return nil, status.Error(codes.Internal, "failed to get user analytics")
+	}
+	return userAnalyticsProto(analytics), nil
+}
+
+func (s *AnalyticsServer) ListTopUsers(ctx context.Context, req *analyticsv1.ListTopUsersRequest) (*analyticsv1.ListTopUsersResponse, error) {
+	log := logger.WithTrace(ctx, s.logger)
+
+	query, err := userQuery(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page, err := s.analyticsService.GetTopUsers(ctx, query)
+	if err != nil {
+		log.Error("failed to get top users", "limit", query.Limit, "sort", query.Sort, "error", err)
+		return nil, status.Error(codes.Internal, "failed to get top users")
+	}
+
+	resp := &analyticsv1.ListTopUsersResponse{Users: userSummariesProto(page.Users)}
+	if page.Next != nil {
+		resp.NextCursor = page.Next.Encode()
+	}
+	return resp, nil
+}
+
+// userQuery validates a ListTopUsers request the way /v1/leaderboard validates its parameters
+func userQuery(req *analyticsv1.ListTopUsersRequest) (models.UserQuery, error) {
+	sort, ok := sortKeys[req.GetSort()]
+	if !ok {
+		return models.UserQuery{}, fmt.Errorf("unknown sort %v", req.GetSort())
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if limit < 0 || limit > maxLimit {
+		return models.UserQuery{}, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	query := models.UserQuery{Sort: sort, Limit: limit}
+
+	if token := req.GetCursor(); token != "" {
+		cursor, err := models.ParseCursor(token)
+		if err != nil {
+			return models.UserQuery{}, fmt.Errorf("cursor is malformed")
+		}
+		if cursor.Sort != sort {
+			return models.UserQuery{}, fmt.Errorf("cursor was issued for sort %s, not %s", cursor.Sort, sort)
+		}
+		query.After = cursor
+	}
+
+	filter, err := userFilter(req.GetFilter())
+	if err != nil {
+		return models.UserQuery{}, err
+	}
+	query.Filter = filter
+
+	return query, nil
+}
+
+func userFilter(f *analyticsv1.UserFilter) (models.UserFilter, error) {
+	var filter models.UserFilter
+	if f == nil {
+		return filter, nil
+	}
+
+	var err error
+	if filter.MinOrders, err = count("min_orders", f.MinOrders); err != nil {
+		return models.UserFilter{}, err
+	}
+	if filter.MaxOrders, err = count("max_orders", f.MaxOrders); err != nil {
+		return models.UserFilter{}, err
+	}
+	if filter.MinSpent, err = amount("min_spent", f.MinSpent); err != nil {
+		return models.UserFilter{}, err
+	}
+	if filter.MaxSpent, err = amount("max_spent", f.MaxSpent); err != nil {
+		return models.UserFilter{}, err
+	}
+
+	if filter.MinOrders != nil && filter.MaxOrders != nil && *filter.MinOrders > *filter.MaxOrders {
+		return models.UserFilter{}, fmt.Errorf("min_orders must not exceed max_orders")
+	}
+	if filter.MinSpent != nil && filter.MaxSpent != nil && *filter.MinSpent > *filter.MaxSpent {
+		return models.UserFilter{}, fmt.Errorf("min_spent must not exceed max_spent")
+	}
+
+	if f.UpdatedSince != nil {
+		if err := f.UpdatedSince.CheckValid(); err != nil {
+			return models.UserFilter{}, fmt.Errorf("updated_since is invalid: %w", err)
+		}
+		since := f.UpdatedSince.AsTime()
+		filter.UpdatedSince = &since
+	}
+
+	return filter, nil
+}
+
+// count checks an optional order count, nil if unset
+func count(name string, value *int64) (*int, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if *value < 0 || *value > math.MaxInt32 {
+		return nil, fmt.Errorf("%s must be a non-negative 32-bit integer", name)
+	}
+	n := int(*value)
+	return &n, nil
+}
+
+// amount checks an optional spend, nil if unset
+func amount(name string, value *float64) (*float64, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if *value < 0 || math.IsInf(*value, 0) || math.IsNaN(*value) {
+		return nil, fmt.Errorf("%s must be a non-negative number", name)
+	}
+	return value, nil
+}
+
+func (s *AnalyticsServer) ListAnomalies(ctx context.Context, req *analyticsv1.ListAnomaliesRequest) (*analyticsv1.ListAnomaliesResponse, error) {
+	log := logger.WithTrace(ctx, s.logger)
+
+	anomalies, err := s.analyticsService.DetectAnomalies(ctx)
+	if err != nil {
+		log.Error("failed to detect anomalies", "error", err)
+		return nil, status.Error(codes.Internal, "failed to detect anomalies")
+	}
+	return &analyticsv1.ListAnomaliesResponse{Anomalies: anomaliesProto(anomalies)}, nil
+}
+
+func (s *AnalyticsServer) ListVelocityAnomalies(ctx context.Context, req *analyticsv1.ListVelocityAnomaliesRequest) (*analyticsv1.ListVelocityAnomaliesResponse, error) {
+	log := logger.WithTrace(ctx, s.logger)
+
+	rule := s.settings().AnomalyConfig.VelocityRule()
+	if req.Window != nil {
+		if err := req.Window.CheckValid(); err != nil || req.Window.AsDuration() < models.ActivityBucketSize {
+			return nil, status.Errorf(codes.InvalidArgument, "window must be a duration of at least %s", models.ActivityBucketSize)
+		}
+		rule.Window = req.Window.AsDuration()
+	}
+	minOrders, err := count("min_orders", req.MinOrders)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if minOrders != nil {
+		rule.OrderThreshold = *minOrders
+	}
+	minSpent, err := amount("min_spent", req.MinSpent)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if minSpent != nil {
+		rule.SpendThreshold = *minSpent
+	}
+	if rule.OrderThreshold == 0 && rule.SpendThreshold == 0 {
+		return nil, status.Error(codes.InvalidArgument, "min_orders or min_spent must be positive")
+	}
+
+	anomalies, err := s.analyticsService.DetectVelocityAnomalies(ctx, rule)
+	if err != nil {
+		log.Error("failed to detect velocity anomalies", "window", rule.Window, "error", err)
+		return nil, status.Error(codes.Internal, "failed to detect velocity anomalies")
+	}
+	return velocityAnomaliesProto(rule, anomalies), nil
+}
@@ -0,0 +1,462 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+	analyticsv1 "tx-processor/api/analytics/v1"
+	"tx-processor/cache/memory"
+	"tx-processor/config"
+	"tx-processor/events"
+	"tx-processor/logger"
+	"tx-processor/models"
+	"tx-processor/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeRepo stands in for the database, knowing only user u1. Methods the
+// tests don't reach are left to the nil embedded interface.
+type fakeRepo struct {
+	services.Analytics
+
+	mu    sync.Mutex
+	query models.UserQuery // Last TopUsers query
+}
+
+func (r *fakeRepo) UserAnalytics(ctx context.Context, userID string) (*models.UserAnalytics, error) {
+	if userID != "u1" {
+		return nil, models.ErrUserNotFound
+	}
+	return &models.UserAnalytics{UserID: "u1", TotalOrders: 3, TotalSpent: 42.5}, nil
+}
+
+func (r *fakeRepo) TopUsers(ctx context.Context, query models.UserQuery) (*models.UserPage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.query = query
+	return &models.UserPage{
+		Users: []models.UserSummary{{UserAnalytics: models.UserAnalytics{UserID: "u1", TotalOrders: 3, TotalSpent: 42.5}}},
+		Next:  &models.Cursor{Sort: query.Sort, Value: "3", UserID: "u1"},
+	}, nil
+}
+
+func (r *fakeRepo) lastQuery() models.UserQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.query
+}
+
+// fakeIngester records the size of each committed batch and fails any batch
+// holding a transaction for user fail
+type fakeIngester struct {
+	mu      sync.Mutex
+	batches []int
+}
+
+func (i *fakeIngester) ProcessBatch(ctx context.Context, txs []models.Transaction) error {
+	if slices.ContainsFunc(txs, func(tx models.Transaction) bool { return tx.UserID == "fail" }) {
+		return errors.New("database unavailable")
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.batches = append(i.batches, len(txs))
+	return nil
+}
+
+func (i *fakeIngester) committed() []int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return slices.Clone(i.batches)
+}
+
+type testServer struct {
+	server   *AnalyticsServer
+	client   analyticsv1.AnalyticsServiceClient
+	repo     *fakeRepo
+	broker   *events.Broker
+	ingester *fakeIngester
+}
+
+// newTestServer serves an AnalyticsServer over bufconn, committing pushed
+// transactions two at a time
+func newTestServer(t *testing.T, opts ...grpc.ServerOption) *testServer {
+	t.Helper()
+	ts := &testServer{repo: &fakeRepo{}, broker: events.NewBroker(1), ingester: &fakeIngester{}}
+
+	service := services.NewAnalyticsService(ts.repo, memory.NewMemoryAnalyticsCache(100, time.Minute))
+	t.Cleanup(service.Close)
+	cfg := &config.Config{GRPCConfig: config.GRPCConfig{IngestBatchSize: 2}}
+	log := logger.NewSlogAdapter(slog.New(slog.DiscardHandler))
+	ts.server = NewAnalyticsServer(service, cfg, log, WithEvents(ts.broker), WithIngester(ts.ingester))
+
+	ts.client = serve(t, ts.server, opts...)
+	return ts
+}
+
+// serve runs s on a gRPC server over bufconn and returns a client for it
+func serve(t *testing.T, s *AnalyticsServer, opts ...grpc.ServerOption) analyticsv1.AnalyticsServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(opts...)
+	s.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return analyticsv1.NewAnalyticsServiceClient(conn)
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// wantCode fails the test unless err is a status with code
+func wantCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != code {
+		t.Errorf("error = %v, want code %s", err, code)
+	}
+}
+
+func TestGetUserAnalytics(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := testContext(t)
+
+	user, err := ts.client.GetUserAnalytics(ctx, &analyticsv1.GetUserAnalyticsRequest{UserId: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.GetTotalOrders() != 3 || user.GetTotalSpent() != 42.5 {
+		t.Errorf("u1 = %v, want 3 orders and 42.5 spent", user)
+	}
+
+	// Twice, so the second is answered from the negative cache
+	for range 2 {
+		_, err = ts.client.GetUserAnalytics(ctx, &analyticsv1.GetUserAnalyticsRequest{UserId: "u2"})
+		wantCode(t, err, codes.NotFound)
+	}
+
+	_, err = ts.client.GetUserAnalytics(ctx, &analyticsv1.GetUserAnalyticsRequest{})
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestListTopUsers(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := testContext(t)
+
+	first, err := ts.client.ListTopUsers(ctx, &analyticsv1.ListTopUsersRequest{Sort: analyticsv1.SortKey_SORT_KEY_SPEND})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := ts.repo.lastQuery(); q.Limit != defaultLimit || q.Sort != models.SortBySpend || q.After != nil {
+		t.Errorf("first page query = %+v, want the default limit by spend", q)
+	}
+	if len(first.GetUsers()) != 1 || first.GetNextCursor() == "" {
+		t.Fatalf("first page = %v, want a user and a cursor", first)
+	}
+
+	if _, err := ts.client.ListTopUsers(ctx, &analyticsv1.ListTopUsersRequest{
+		Sort: analyticsv1.SortKey_SORT_KEY_SPEND, Limit: maxLimit, Cursor: first.GetNextCursor(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if q := ts.repo.lastQuery(); q.Limit != maxLimit || q.After == nil || q.After.UserID != "u1" {
+		t.Errorf("next page query = %+v, want the cursor after u1", q)
+	}
+
+	invalid := map[string]*analyticsv1.ListTopUsersRequest{
+		"cursor for another sort": {Sort: analyticsv1.SortKey_SORT_KEY_ORDERS, Cursor: first.GetNextCursor()},
+		"malformed cursor":        {Cursor: "not-a-cursor"},
+		"limit above the maximum": {Limit: maxLimit + 1},
+		"negative limit":          {Limit: -1},
+		"unknown sort":            {Sort: 99},
+		"min above max":           {Filter: &analyticsv1.UserFilter{MinOrders: ptr[int64](5), MaxOrders: ptr[int64](2)}},
+		"negative spend":          {Filter: &analyticsv1.UserFilter{MinSpent: ptr(-1.0)}},
+	}
+	for name, req := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := ts.client.ListTopUsers(ctx, req)
+			wantCode(t, err, codes.InvalidArgument)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// awaitSubscribers waits for n subscribers, so events published next reach them
+func awaitSubscribers(t *testing.T, broker *events.Broker, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for broker.Stats().Subscribers != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscribers, want %d", broker.Stats().Subscribers, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func analyticsEvent(userIDs ...string) models.Event {
+	event := models.Event{Type: models.EventAnalytics, At: time.Now()}
+	for _, userID := range userIDs {
+		event.Deltas = append(event.Deltas, models.UserDelta{UserID: userID, Orders: 1, Spent: 10})
+	}
+	return event
+}
+
+func TestWatchEventsFilters(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := testContext(t)
+
+	stream, err := ts.client.WatchEvents(ctx, &analyticsv1.WatchEventsRequest{
+		Types:   []analyticsv1.EventType{analyticsv1.EventType_EVENT_TYPE_ANALYTICS},
+		UserIds: []string{"u1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	awaitSubscribers(t, ts.broker, 1)
+
+	leaderboard := models.Event{Type: models.EventLeaderboard, Leaderboard: &models.LeaderboardChange{
+		By: models.RankByOrders, Users: []models.UserSummary{{UserAnalytics: models.UserAnalytics{UserID: "u1", TotalOrders: 1}}},
+	}}
+	for _, event := range []models.Event{leaderboard, analyticsEvent("u2"), analyticsEvent("u2", "u1")} {
+		ts.broker.Publish(ctx, event)
+	}
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltas := event.GetAnalytics().GetDeltas()
+	if event.GetId() != 3 || len(deltas) != 1 || deltas[0].GetUserId() != "u1" {
+		t.Errorf("event = %v, want only u1's delta from the third", event)
+	}
+
+	invalid := map[string]*analyticsv1.WatchEventsRequest{
+		"negative top":   {Top: -1},
+		"unknown type":   {Types: []analyticsv1.EventType{99}},
+		"unknown rank":   {By: 99},
+		"too many users": {UserIds: make([]string, maxEventUsers+1)},
+	}
+	for name, req := range invalid {
+		t.Run(name, func(t *testing.T) {
+			stream, err := ts.client.WatchEvents(ctx, req)
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			wantCode(t, err, codes.InvalidArgument)
+		})
+	}
+}
+
+// gatedStream holds the first message a stream sends until release is
+// closed, closing entered once it is waiting
+type gatedStream struct {
+	grpc.ServerStream
+	once             sync.Once
+	entered, release chan struct{}
+}
+
+func (s *gatedStream) SendMsg(m any) error {
+	s.once.Do(func() {
+		close(s.entered)
+		<-s.release
+	})
+	return s.ServerStream.SendMsg(m)
+}
+
+func TestWatchEventsReportsDrops(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	ts := newTestServer(t, grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &gatedStream{ServerStream: ss, entered: entered, release: release})
+	}))
+	ctx := testContext(t)
+
+	stream, err := ts.client.WatchEvents(ctx, &analyticsv1.WatchEventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	awaitSubscribers(t, ts.broker, 1)
+
+	// The first event is held in Send, the second fills the one-event buffer
+	// and the next two are dropped
+	ts.broker.Publish(ctx, analyticsEvent("u1"))
+	<-entered
+	for range 3 {
+		ts.broker.Publish(ctx, analyticsEvent("u1"))
+	}
+	close(release)
+
+	var got []string
+	for range 3 {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dropped := event.GetDropped(); dropped != nil {
+			if dropped.GetCount() != 2 {
+				t.Errorf("dropped count = %d, want 2", dropped.GetCount())
+			}
+			got = append(got, "dropped")
+		} else {
+			got = append(got, "event")
+		}
+	}
+	if want := []string{"event", "event", "dropped"}; !slices.Equal(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+}
+
+func transactions(userIDs ...string) []*analyticsv1.Transaction {
+	txs := make([]*analyticsv1.Transaction, len(userIDs))
+	for i, userID := range userIDs {
+		txs[i] = &analyticsv1.Transaction{OrderId: "o" + userID, UserId: userID, Quantity: 1, Price: 10}
+	}
+	return txs
+}
+
+func TestPushTransactions(t *testing.T) {
+	tests := []struct {
+		name        string
+		txs         []*analyticsv1.Transaction
+		wantCode    codes.Code
+		wantMessage string
+		wantBatches []int
+	}{
+		{
+			name:        "batches with a remainder",
+			txs:         transactions("u1", "u2", "u1", "u3", "u4"),
+			wantBatches: []int{2, 2, 1},
+		},
+		{
+			name:        "missing user_id",
+			txs:         transactions("u1", "u2", "u3", ""),
+			wantCode:    codes.InvalidArgument,
+			wantMessage: "transaction 3 has no user_id; 2 were committed",
+			wantBatches: []int{2},
+		},
+		{
+			name:        "failed batch",
+			txs:         transactions("u1", "u2", "u3", "fail"),
+			wantCode:    codes.Internal,
+			wantMessage: "failed to commit transactions after 2 were committed",
+			wantBatches: []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			stream, err := ts.client.PushTransactions(testContext(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tx := range tt.txs {
+				// The server may have ended the call already; its status is reported below
+				if err := stream.Send(tx); err != nil {
+					break
+				}
+			}
+			resp, err := stream.CloseAndRecv()
+
+			if tt.wantCode != codes.OK {
+				wantCode(t, err, tt.wantCode)
+				if msg := status.Convert(err).Message(); msg != tt.wantMessage {
+					t.Errorf("message = %q, want %q", msg, tt.wantMessage)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if resp.GetAccepted() != int64(len(tt.txs)) || resp.GetBatches() != int64(len(tt.wantBatches)) {
+				t.Errorf("response = %v, want %d accepted in %d batches", resp, len(tt.txs), len(tt.wantBatches))
+			}
+			if got := ts.ingester.committed(); !slices.Equal(got, tt.wantBatches) {
+				t.Errorf("committed batches %v, want %v", got, tt.wantBatches)
+			}
+		})
+	}
+}
+
+// countedStream reports each RecvMsg call as it starts
+type countedStream struct {
+	grpc.ServerStream
+	recvs chan<- struct{}
+}
+
+func (s *countedStream) RecvMsg(m any) error {
+	s.recvs <- struct{}{}
+	return s.ServerStream.RecvMsg(m)
+}
+
+func TestPushTransactionsDrain(t *testing.T) {
+	recvs := make(chan struct{}, 16)
+	ts := newTestServer(t, grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &countedStream{ServerStream: ss, recvs: recvs})
+	}))
+
+	stream, err := ts.client.PushTransactions(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range transactions("u1", "u2", "u3") {
+		if err := stream.Send(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The fourth Recv starts only once the third transaction was handed over,
+	// leaving it pending in a partial batch
+	for range 4 {
+		<-recvs
+	}
+	ts.server.Drain()
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetAccepted() != 3 || resp.GetBatches() != 2 {
+		t.Errorf("response = %v, want 3 accepted in 2 batches", resp)
+	}
+	if got, want := ts.ingester.committed(), []int{2, 1}; !slices.Equal(got, want) {
+		t.Errorf("committed batches %v, want %v", got, want)
+	}
+}
+
+func TestUnavailableWithoutDependencies(t *testing.T) {
+	service := services.NewAnalyticsService(&fakeRepo{}, memory.NewMemoryAnalyticsCache(100, time.Minute))
+	t.Cleanup(service.Close)
+	s := NewAnalyticsServer(service, &config.Config{}, logger.NewSlogAdapter(slog.New(slog.DiscardHandler)))
+	client := serve(t, s)
+	ctx := testContext(t)
+
+	events, err := client.WatchEvents(ctx, &analyticsv1.WatchEventsRequest{})
+	if err == nil {
+		_, err = events.Recv()
+	}
+	wantCode(t, err, codes.Unavailable)
+
+	push, err := client.PushTransactions(ctx)
+	if err == nil {
+		_, err = push.CloseAndRecv()
+	}
+	wantCode(t, err, codes.Unavailable)
+}
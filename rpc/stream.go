@@ -0,0 +1,182 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	analyticsv1 "tx-processor/api/analytics/v1"
+	"tx-processor/logger"
+	"tx-processor/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchEvents streams live updates from the broker. As with the SSE stream,
+// a client that reads too slowly loses events rather than holding up the
+// broker, and is sent an EventsDropped where they were lost.
+func (s *AnalyticsServer) WatchEvents(req *analyticsv1.WatchEventsRequest, stream grpc.ServerStreamingServer[analyticsv1.Event]) error {
+	ctx := stream.Context()
+	log := logger.WithTrace(ctx, s.logger)
+
+	if s.events == nil {
+		return status.Error(codes.Unavailable, "live updates are not enabled")
+	}
+	filter, err := eventFilter(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub := s.events.Subscribe(filter)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if err := stream.Send(eventProto(event)); err != nil {
+				log.Info("event stream closed", "error", err)
+				return err
+			}
+			if len(sub.Events()) == 0 {
+				if dropped := sub.TakeDropped(); dropped > 0 {
+					if err := stream.Send(droppedProto(dropped)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+}
+
+// eventFilter validates a WatchEvents request the way /v1/events validates its parameters
+func eventFilter(req *analyticsv1.WatchEventsRequest) (models.EventFilter, error) {
+	var filter models.EventFilter
+
+	for _, eventType := range req.GetTypes() {
+		t, ok := eventTypes[eventType]
+		if !ok {
+			return models.EventFilter{}, fmt.Errorf("unknown event type %v", eventType)
+		}
+		filter.Types = append(filter.Types, t)
+	}
+
+	if len(req.GetUserIds()) > maxEventUsers {
+		return models.EventFilter{}, fmt.Errorf("user_ids may list at most %d users", maxEventUsers)
+	}
+	for _, userID := range req.GetUserIds() {
+		if userID != "" {
+			filter.UserIDs = append(filter.UserIDs, userID)
+		}
+	}
+
+	by, ok := rankBys[req.GetBy()]
+	if !ok {
+		return models.EventFilter{}, fmt.Errorf("unknown ranking %v", req.GetBy())
+	}
+	filter.By = by
+
+	if req.GetTop() < 0 {
+		return models.EventFilter{}, fmt.Errorf("top must not be negative")
+	}
+	filter.Top = int(req.GetTop())
+
+	return filter, nil
+}
+
+// PushTransactions commits streamed transactions in batches of the configured
+// size, then the remainder when the client closes its side. A failed batch
+// ends the call; batches committed before it stay committed.
+func (s *AnalyticsServer) PushTransactions(stream grpc.ClientStreamingServer[analyticsv1.Transaction, analyticsv1.PushTransactionsResponse]) error {
+	ctx := stream.Context()
+	log := logger.WithTrace(ctx, s.logger)
+
+	if s.ingester == nil {
+		return status.Error(codes.Unavailable, "ingestion is not enabled")
+	}
+
+	batchSize := max(s.settings().GRPCConfig.IngestBatchSize, 1)
+	batch := make([]models.Transaction, 0, batchSize)
+	var resp analyticsv1.PushTransactionsResponse
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.ingester.ProcessBatch(ctx, batch); err != nil {
+			log.Error("failed to commit pushed transactions", "transactions", len(batch), "committed", resp.Accepted, "error", err)
+			return status.Errorf(codes.Internal, "failed to commit transactions after %d were committed", resp.Accepted)
+		}
+		resp.Accepted += int64(len(batch))
+		resp.Batches++
+		batch = batch[:0]
+		return nil
+	}
+
+	// Receive in the background so draining can interrupt a client that is
+	// between messages
+	type received struct {
+		tx  *analyticsv1.Transaction
+		err error
+	}
+	recvs := make(chan received)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			tx, err := stream.Recv()
+			select {
+			case recvs <- received{tx, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+Receive:
+	for {
+		var tx *analyticsv1.Transaction
+		select {
+		case <-s.draining:
+			log.Info("ending push for shutdown", "committed", resp.Accepted, "pending", len(batch))
+			break Receive
+		case r := <-recvs:
+			if errors.Is(r.err, io.EOF) {
+				break Receive
+			}
+			if r.err != nil {
+				return r.err
+			}
+			tx = r.tx
+		}
+
+		if tx.GetUserId() == "" {
+			return status.Errorf(codes.InvalidArgument, "transaction %d has no user_id; %d were committed", resp.Accepted+int64(len(batch)), resp.Accepted)
+		}
+		if tx.Timestamp != nil {
+			if err := tx.Timestamp.CheckValid(); err != nil {
+				return status.Errorf(codes.InvalidArgument, "transaction %d has an invalid timestamp; %d were committed", resp.Accepted+int64(len(batch)), resp.Accepted)
+			}
+		}
+		batch = append(batch, transactionModel(tx))
+
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+	return stream.SendAndClose(&resp)
+}
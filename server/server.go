@@ -4,58 +4,162 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
+	"sync"
 	"time"
 	"tx-processor/handlers"
+	"tx-processor/health"
 	"tx-processor/logger"
+	"tx-processor/middleware"
+
+	"google.golang.org/grpc"
 )
 
 type Server struct {
 	httpServer *http.Server
+	grpcServer *grpc.Server
+	grpcAddr   string
 	logger     logger.Logger
+	health     *health.Registry
+	drainDelay time.Duration
+	onShutdown []func()
+	onError    func(error)
 }
 
 type Config struct {
-	Port   string
-	Logger logger.Logger
+	Port       string
+	Logger     logger.Logger
+	Metrics    http.Handler                      // Served at /metrics when set
+	Middleware []func(http.Handler) http.Handler // Applied in order, first is outermost
+	Health     *health.Registry                  // Marked draining on shutdown when set
+	DrainDelay time.Duration                     // How long readiness fails before the listener closes
+	OnShutdown []func()                          // Called once draining ends, to end long-lived requests such as event streams and ingestion
+	OnError    func(error)                       // Called when a listener stops serving unexpectedly
+	GRPC       *grpc.Server                      // Served on GRPCAddr alongside HTTP when set
+	GRPCAddr   string
+
+	// Connection limits; zero means none
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
 }
 
 func New(cfg Config, handler *handlers.Handler) *Server {
 	mux := http.NewServeMux()
 
 	handler.RegisterRoutes(mux)
+	if cfg.Metrics != nil {
+		mux.Handle("/metrics", cfg.Metrics)
+	}
 
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%s", cfg.Port),
-		Handler: mux,
+		Addr:              fmt.Sprintf(":%s", cfg.Port),
+		Handler:           middleware.Chain(middleware.Routed(mux), cfg.Middleware...),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
 	}
 
 	return &Server{
 		httpServer: srv,
+		grpcServer: cfg.GRPC,
+		grpcAddr:   cfg.GRPCAddr,
 		logger:     cfg.Logger,
+		health:     cfg.Health,
+		drainDelay: cfg.DrainDelay,
+		onShutdown: cfg.OnShutdown,
+		onError:    cfg.OnError,
 	}
 }
 
+// Start listens for HTTP and, when configured, gRPC, and serves both in the
+// background. It returns once both listeners are open.
 func (s *Server) Start(ctx context.Context) error {
+	httpLis, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("listen for HTTP on %s: %w", s.httpServer.Addr, err)
+	}
+	if s.grpcServer != nil {
+		grpcLis, err := net.Listen("tcp", s.grpcAddr)
+		if err != nil {
+			httpLis.Close()
+			return fmt.Errorf("listen for gRPC on %s: %w", s.grpcAddr, err)
+		}
+		go func() {
+			if err := s.grpcServer.Serve(grpcLis); err != nil {
+				s.fail(fmt.Errorf("serve gRPC: %w", err))
+			}
+		}()
+		s.logger.Info("gRPC server listening", "addr", grpcLis.Addr().String())
+	}
+
 	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			s.logger.Error("server failed to listen and serve", "error", err)
+		if err := s.httpServer.Serve(httpLis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.fail(fmt.Errorf("serve HTTP: %w", err))
 		}
 	}()
+	s.logger.Info("HTTP server listening", "addr", httpLis.Addr().String())
+	return nil
+}
+
+// Shutdown drains the server: readiness fails for the drain delay so load
+// balancers stop routing here, long-lived requests are told to end, and
+// in-flight requests and RPCs get until ctx expires to finish.
+func (s *Server) Shutdown(ctx context.Context) error {
+	// Fail readiness first so load balancers stop sending new requests
+	if s.health != nil {
+		s.health.SetDraining(true)
+		s.logger.Info("draining", "delay", s.drainDelay)
+		select {
+		case <-time.After(s.drainDelay):
+		case <-ctx.Done():
+		}
+	}
 
-	// graceful shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
-	s.logger.Info("shutting down server...")
+	for _, f := range s.onShutdown {
+		f()
+	}
 
-	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
-		s.logger.Error("server shutdown failed", "error", err)
-		return err
+	var wg sync.WaitGroup
+	if s.grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.stopGRPC(ctx)
+		}()
+	}
+	err := s.httpServer.Shutdown(ctx)
+	wg.Wait()
+	if err != nil {
+		return fmt.Errorf("shut down HTTP server: %w", err)
 	}
 	return nil
 }
+
+func (s *Server) fail(err error) {
+	s.logger.Error("server stopped serving", "error", err)
+	if s.onError != nil {
+		s.onError(err)
+	}
+}
+
+// stopGRPC lets in-flight RPCs finish, cutting them off when ctx expires
+func (s *Server) stopGRPC(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.logger.Warn("gRPC graceful stop timed out, closing connections")
+		s.grpcServer.Stop()
+		<-stopped
+	}
+}
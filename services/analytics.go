@@ -2,96 +2,512 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 	"tx-processor/cache"
+	"tx-processor/cache/memory"
 	"tx-processor/models"
+	"tx-processor/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+)
+
+var tracer = otel.Tracer("tx-processor/services")
+
+// ErrUserNotFound is returned by Analytics implementations for users with no
+// analytics; it is models.ErrUserNotFound, so callers needn't import models
+var ErrUserNotFound = models.ErrUserNotFound
+
+const (
+	// loadTimeout bounds a shared database load, which outlives any single caller's context
+	loadTimeout = 10 * time.Second
+	// fillTimeout bounds a single background cache fill
+	fillTimeout = 2 * time.Second
 )
 
 // Analytics defines methods for user analytics operations
 type Analytics interface {
-	UpdateAnalytics(ctx context.Context, updates map[string]*models.UserAnalytics) error
+	UpdateAnalytics(ctx context.Context, updates map[string]*models.UserAnalytics, activity []models.ActivityBucket) error
 	UserAnalytics(ctx context.Context, userID string) (*models.UserAnalytics, error)
-	TopUsers(ctx context.Context, limit int) ([]models.UserAnalytics, error)
+	TopUsers(ctx context.Context, query models.UserQuery) (*models.UserPage, error)
+	UserRank(ctx context.Context, by models.RankBy, userID string) (*models.UserRank, error)
+	UserProfiles(ctx context.Context, userIDs []string) ([]models.UserProfile, error)
+	AllUsers(ctx context.Context) iter.Seq2[models.UserAnalytics, error]
+	ExportUsers(ctx context.Context, filter models.UserFilter) iter.Seq2[models.UserSummary, error]
 	UserAnomalies(ctx context.Context) ([]models.AnomalyUser, error)
+	UserVelocity(ctx context.Context, userID string, window time.Duration, anchor models.VelocityAnchor) (*models.UserVelocity, error)
+	VelocityAnomalies(ctx context.Context, rule models.VelocityRule) ([]models.VelocityAnomaly, error)
+	PruneActivity(ctx context.Context, before time.Time) (int64, error)
 }
 
 type AnalyticsService struct {
-	repo  Analytics
-	cache cache.AnalyticsCache
+	repo        Analytics
+	cache       cache.AnalyticsCache
+	leaderboard cache.Leaderboard
+	local       cache.AnalyticsCache // In-process tier of cache, if it has one
+	negative    cache.AnalyticsCache // Short-lived entries for users with no analytics
+
+	loads       singleflight.Group // Coalesces concurrent loads per user
+	fillWorkers int
+	fills       chan models.UserAnalytics
+	stop        chan struct{}
+	wg          sync.WaitGroup
+	closeOnce   sync.Once
+
+	fillsQueued  atomic.Uint64
+	fillsDropped atomic.Uint64
+	fillsFailed  atomic.Uint64
+}
+
+// FillStats counts background cache fills
+type FillStats struct {
+	Queued  uint64 `json:"queued"`
+	Dropped uint64 `json:"dropped"` // Queue was full
+	Failed  uint64 `json:"failed"`  // Cache rejected the write
+}
+
+// Option configures optional AnalyticsService dependencies
+type Option func(*AnalyticsService)
+
+// WithLeaderboard serves top users and ranks from a leaderboard instead of the database
+func WithLeaderboard(leaderboard cache.Leaderboard) Option {
+	return func(s *AnalyticsService) {
+		s.leaderboard = leaderboard
+	}
+}
+
+// WithNegativeCache remembers unknown users so repeated lookups skip the database
+func WithNegativeCache(negative cache.AnalyticsCache) Option {
+	return func(s *AnalyticsService) {
+		s.negative = negative
+	}
 }
 
-func NewAnalyticsService(repo Analytics, cache cache.AnalyticsCache) *AnalyticsService {
-	return &AnalyticsService{
-		repo:  repo,
-		cache: cache,
+// WithLocalCache names the in-process tier of the service's cache, which
+// EvictLocal clears when another process invalidates users
+func WithLocalCache(local cache.AnalyticsCache) Option {
+	return func(s *AnalyticsService) {
+		s.local = local
 	}
 }
 
-// GetUserAnalytics retrieves user analytics with cache-first strategy
+// WithCacheFills bounds background cache fills to workers goroutines draining a
+// queue of queueSize; fills beyond that are dropped.
+func WithCacheFills(workers, queueSize int) Option {
+	return func(s *AnalyticsService) {
+		s.fillWorkers = workers
+		s.fills = make(chan models.UserAnalytics, queueSize)
+	}
+}
+
+func NewAnalyticsService(repo Analytics, cache cache.AnalyticsCache, opts ...Option) *AnalyticsService {
+	s := &AnalyticsService{
+		repo:        repo,
+		cache:       cache,
+		negative:    memory.NewMemoryAnalyticsCache(10000, 30*time.Second),
+		fillWorkers: 4,
+		fills:       make(chan models.UserAnalytics, 1024),
+		stop:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	for i := 0; i < s.fillWorkers; i++ {
+		s.wg.Add(1)
+		go s.fillCache()
+	}
+	return s
+}
+
+// Close stops the background cache fill workers; queued fills are dropped
+func (s *AnalyticsService) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+	})
+}
+
+func (s *AnalyticsService) fillCache() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case analytics := <-s.fills:
+			ctx, cancel := context.WithTimeout(context.Background(), fillTimeout)
+			if err := s.cache.Set(ctx, analytics); err != nil {
+				s.fillsFailed.Add(1)
+			}
+			cancel()
+		}
+	}
+}
+
+// queueFill schedules a cache fill without blocking the caller
+func (s *AnalyticsService) queueFill(analytics models.UserAnalytics) {
+	select {
+	case s.fills <- analytics:
+		s.fillsQueued.Add(1)
+	default:
+		// Queue full: the next miss will try again
+		s.fillsDropped.Add(1)
+	}
+}
+
+// CacheFillStats returns counters for background cache fills
+func (s *AnalyticsService) CacheFillStats() FillStats {
+	return FillStats{
+		Queued:  s.fillsQueued.Load(),
+		Dropped: s.fillsDropped.Load(),
+		Failed:  s.fillsFailed.Load(),
+	}
+}
+
+// GetUserAnalytics retrieves user analytics with cache-first strategy.
+// Unknown users get zero totals.
 func (s *AnalyticsService) GetUserAnalytics(ctx context.Context, userID string) (*models.UserAnalytics, error) {
-	// Try cache first
+	analytics, err := s.FindUserAnalytics(ctx, userID)
+	if errors.Is(err, ErrUserNotFound) {
+		return &models.UserAnalytics{UserID: userID}, nil
+	}
+	return analytics, err
+}
+
+// FindUserAnalytics is GetUserAnalytics for callers that tell unknown users
+// apart: it returns ErrUserNotFound for them
+func (s *AnalyticsService) FindUserAnalytics(ctx context.Context, userID string) (*models.UserAnalytics, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.FindUserAnalytics")
+	defer span.End()
+
+	// Try cache first; errors other than a miss just mean the cache can't help right now
 	if analytics, err := s.cache.Get(ctx, userID); err == nil {
+		span.SetAttributes(attribute.String("cache.result", "hit"))
 		return analytics, nil
 	}
 
-	// Fallback to database
+	if _, err := s.negative.Get(ctx, userID); err == nil {
+		span.SetAttributes(attribute.String("cache.result", "negative_hit"))
+		return nil, ErrUserNotFound
+	}
+	span.SetAttributes(attribute.String("cache.result", "miss"))
+
+	// Fallback to database, sharing one load among concurrent callers.
+	// The load is detached so one caller giving up doesn't fail the others.
+	result := s.loads.DoChan(userID, func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+		return s.loadUserAnalytics(loadCtx, userID)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, tracing.Error(span, ctx.Err())
+	case res := <-result:
+		if errors.Is(res.Err, ErrUserNotFound) {
+			return nil, res.Err
+		}
+		if res.Err != nil {
+			return nil, tracing.Error(span, res.Err)
+		}
+		// Callers share the loaded value, so each gets its own copy
+		analytics := *res.Val.(*models.UserAnalytics)
+		return &analytics, nil
+	}
+}
+
+func (s *AnalyticsService) loadUserAnalytics(ctx context.Context, userID string) (*models.UserAnalytics, error) {
 	analytics, err := s.repo.UserAnalytics(ctx, userID)
+	if errors.Is(err, ErrUserNotFound) {
+		s.negative.Set(ctx, models.UserAnalytics{UserID: userID})
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user analytics from repository: %w", err)
 	}
 
-	// Cache the result asynchronously (fire and forget)
-	go func() {
-		s.cache.Set(context.Background(), *analytics)
-	}()
-
+	s.queueFill(*analytics)
 	return analytics, nil
 }
 
 // GetUserTotalOrders gets total orders for a specific user
 func (s *AnalyticsService) GetUserTotalOrders(ctx context.Context, userID string) (int, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.GetUserTotalOrders")
+	defer span.End()
+
 	analytics, err := s.GetUserAnalytics(ctx, userID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get user total orders: %w", err)
+		return 0, tracing.Error(span, fmt.Errorf("failed to get user total orders: %w", err))
 	}
 	return analytics.TotalOrders, nil
 }
 
 // GetUserTotalSpendings gets total spendings for a specific user
 func (s *AnalyticsService) GetUserTotalSpendings(ctx context.Context, userID string) (float64, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.GetUserTotalSpendings")
+	defer span.End()
+
 	analytics, err := s.GetUserAnalytics(ctx, userID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get user total spendings: %w", err)
+		return 0, tracing.Error(span, fmt.Errorf("failed to get user total spendings: %w", err))
 	}
 	return analytics.TotalSpent, nil
 }
 
-// GetTopUsers retrieves top users by orders
-func (s *AnalyticsService) GetTopUsers(ctx context.Context, limit int) ([]models.UserAnalytics, error) {
-	// Get from database (cache can be added later if needed)
-	users, err := s.repo.TopUsers(ctx, limit)
+// GetTopUsers retrieves a sorted, filtered page of users. Unfiltered first
+// pages by orders or spend come from the leaderboard once it has been built.
+func (s *AnalyticsService) GetTopUsers(ctx context.Context, query models.UserQuery) (*models.UserPage, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.GetTopUsers")
+	defer span.End()
+
+	// Until a rebuild loads it, or while Redis is down, the database answers
+	if by, ok := query.Sort.RankBy(); ok && s.leaderboard != nil && query.After == nil && query.Filter.IsZero() {
+		if users, err := s.leaderboard.Top(ctx, by, query.Limit+1); err == nil {
+			return leaderboardPage(by, users, query.Limit), nil
+		}
+	}
+
+	page, err := s.repo.TopUsers(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get top users from repository: %w", err)
+		return nil, tracing.Error(span, fmt.Errorf("failed to get top users from repository: %w", err))
 	}
 
-	return users, nil
+	return page, nil
+}
+
+// leaderboardPage builds a page from up to limit+1 leaderboard entries. Its
+// cursor continues in the database, which breaks ties by user ID descending
+// just as the leaderboard does.
+func leaderboardPage(by models.RankBy, users []models.UserAnalytics, limit int) *models.UserPage {
+	page := &models.UserPage{}
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[len(users)-1]
+		// Spend is stored to the cent; rounding drops float drift from accumulated increments
+		value := strconv.FormatFloat(math.Round(last.TotalSpent*100)/100, 'f', 2, 64)
+		if by == models.RankByOrders {
+			value = strconv.Itoa(last.TotalOrders)
+		}
+		page.Next = &models.Cursor{Sort: models.SortKey(by), Value: value, UserID: last.UserID}
+	}
+
+	page.Users = make([]models.UserSummary, len(users))
+	for i, user := range users {
+		page.Users[i] = models.UserSummary{UserAnalytics: user}
+		if user.TotalOrders > 0 {
+			page.Users[i].AvgOrderValue = user.TotalSpent / float64(user.TotalOrders)
+		}
+	}
+	return page
+}
+
+// GetUserRank returns the user's rank by orders or spend, or nil if the user is unranked
+func (s *AnalyticsService) GetUserRank(ctx context.Context, by models.RankBy, userID string) (*models.UserRank, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.GetUserRank")
+	defer span.End()
+
+	if s.leaderboard != nil {
+		if rank, err := s.leaderboard.Rank(ctx, by, userID); err == nil && rank != nil {
+			return rank, nil
+		}
+	}
+
+	rank, err := s.repo.UserRank(ctx, by, userID)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get user rank from repository: %w", err))
+	}
+
+	return rank, nil
+}
+
+// GetUserProfile returns the user's full profile, or ErrUserNotFound if the user has no analytics
+func (s *AnalyticsService) GetUserProfile(ctx context.Context, userID string) (*models.UserProfile, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.GetUserProfile")
+	defer span.End()
+
+	profiles, err := s.repo.UserProfiles(ctx, []string{userID})
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get user profile from repository: %w", err))
+	}
+	if len(profiles) == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	return &profiles[0], nil
+}
+
+// GetUserProfiles returns profiles for many users in one round trip, listing
+// users with no analytics as missing. Duplicate IDs are looked up once.
+func (s *AnalyticsService) GetUserProfiles(ctx context.Context, userIDs []string) (*models.UserProfiles, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.GetUserProfiles")
+	defer span.End()
+
+	unique := make([]string, 0, len(userIDs))
+	seen := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if !seen[userID] {
+			seen[userID] = true
+			unique = append(unique, userID)
+		}
+	}
+	span.SetAttributes(attribute.Int("profiles.requested", len(unique)))
+
+	profiles, err := s.repo.UserProfiles(ctx, unique)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get user profiles from repository: %w", err))
+	}
+
+	found := make(map[string]bool, len(profiles))
+	for _, profile := range profiles {
+		found[profile.UserID] = true
+	}
+	result := &models.UserProfiles{Profiles: profiles, Missing: []string{}}
+	if result.Profiles == nil {
+		result.Profiles = []models.UserProfile{}
+	}
+	for _, userID := range unique {
+		if !found[userID] {
+			result.Missing = append(result.Missing, userID)
+		}
+	}
+
+	return result, nil
+}
+
+// ExportUsers streams every user matching the filter from the database,
+// ordered by user ID
+func (s *AnalyticsService) ExportUsers(ctx context.Context, filter models.UserFilter) iter.Seq2[models.UserSummary, error] {
+	return s.repo.ExportUsers(ctx, filter)
+}
+
+// RebuildLeaderboard repopulates the leaderboard from user_analytics
+func (s *AnalyticsService) RebuildLeaderboard(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.RebuildLeaderboard")
+	defer span.End()
+
+	if s.leaderboard == nil {
+		return 0, tracing.Error(span, fmt.Errorf("leaderboard is not configured"))
+	}
+
+	count, err := s.leaderboard.Rebuild(ctx, s.repo.AllUsers(ctx))
+	if err != nil {
+		return count, tracing.Error(span, fmt.Errorf("failed to rebuild leaderboard: %w", err))
+	}
+	return count, nil
 }
 
 // DetectAnomalies performs anomaly detection using the repository's implementation
 func (s *AnalyticsService) DetectAnomalies(ctx context.Context) ([]models.AnomalyUser, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.DetectAnomalies")
+	defer span.End()
+
 	// Use the repository's anomaly detection logic
 	anomalies, err := s.repo.UserAnomalies(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to detect anomalies: %w", err)
+		return nil, tracing.Error(span, fmt.Errorf("failed to detect anomalies: %w", err))
+	}
+
+	return anomalies, nil
+}
+
+// GetUserVelocity returns a user's orders and spend within the trailing
+// window ending at anchor
+func (s *AnalyticsService) GetUserVelocity(ctx context.Context, userID string, window time.Duration, anchor models.VelocityAnchor) (*models.UserVelocity, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.GetUserVelocity")
+	defer span.End()
+
+	velocity, err := s.repo.UserVelocity(ctx, userID, window, anchor)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get user velocity: %w", err))
 	}
+	return velocity, nil
+}
+
+// DetectVelocityAnomalies finds users whose activity burst past the rule's thresholds
+func (s *AnalyticsService) DetectVelocityAnomalies(ctx context.Context, rule models.VelocityRule) ([]models.VelocityAnomaly, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.DetectVelocityAnomalies")
+	defer span.End()
 
+	anomalies, err := s.repo.VelocityAnomalies(ctx, rule)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to detect velocity anomalies: %w", err))
+	}
 	return anomalies, nil
 }
 
 // InvalidateUserCache removes user data from cache (useful after updates)
 func (s *AnalyticsService) InvalidateUserCache(ctx context.Context, userID string) error {
-	if err := s.cache.Delete(ctx, userID); err != nil {
-		return fmt.Errorf("failed to invalidate user cache: %w", err)
+	ctx, span := tracer.Start(ctx, "AnalyticsService.InvalidateUserCache")
+	defer span.End()
+
+	if err := errors.Join(s.cache.Delete(ctx, userID), s.negative.Delete(ctx, userID)); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to invalidate user cache: %w", err))
 	}
 	return nil
 }
+
+// InvalidateUsers removes the users from every cache, including those cached
+// as unknown, so users seen for the first time are found at once
+func (s *AnalyticsService) InvalidateUsers(ctx context.Context, userIDs []string) error {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.InvalidateUsers")
+	defer span.End()
+
+	if err := errors.Join(s.cache.DeleteMany(ctx, userIDs), s.negative.DeleteMany(ctx, userIDs)); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to invalidate user cache: %w", err))
+	}
+	return nil
+}
+
+// EvictLocal drops this process's copies of users another process has
+// invalidated: its in-process tier and the users it cached as unknown
+func (s *AnalyticsService) EvictLocal(ctx context.Context, inv cache.Invalidation) {
+	caches := []cache.AnalyticsCache{s.negative}
+	if s.local != nil {
+		caches = append(caches, s.local)
+	}
+	for _, c := range caches {
+		if inv.Prefix != nil {
+			c.DeletePrefix(ctx, *inv.Prefix)
+		} else {
+			c.DeleteMany(ctx, inv.UserIDs)
+		}
+	}
+}
+
+// InvalidateCachePrefix removes every cached user whose ID starts with prefix,
+// or every cached user for an empty prefix, returning how many entries the
+// shared cache dropped
+func (s *AnalyticsService) InvalidateCachePrefix(ctx context.Context, prefix string) (int, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.InvalidateCachePrefix")
+	defer span.End()
+
+	deleted, err := s.cache.DeletePrefix(ctx, prefix)
+	_, negErr := s.negative.DeletePrefix(ctx, prefix)
+	if err := errors.Join(err, negErr); err != nil {
+		return deleted, tracing.Error(span, fmt.Errorf("failed to invalidate user cache: %w", err))
+	}
+	span.SetAttributes(attribute.Int("cache.invalidated", deleted))
+	return deleted, nil
+}
+
+// PruneActivity drops activity older than the retention period, returning how
+// many buckets it deleted. Totals are unaffected; users idle for longer than
+// the retention period lose their velocity history.
+func (s *AnalyticsService) PruneActivity(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, span := tracer.Start(ctx, "AnalyticsService.PruneActivity")
+	defer span.End()
+
+	deleted, err := s.repo.PruneActivity(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return deleted, tracing.Error(span, fmt.Errorf("failed to prune activity: %w", err))
+	}
+	return deleted, nil
+}
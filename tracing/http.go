@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"tx-processor/middleware"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("tx-processor/handlers")
+
+// Middleware starts a server span per request, continuing any trace named in
+// the incoming traceparent header. The span is renamed to the matched route once
+// the request has been served.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		// Let clients correlate their request with our trace
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(w.Header()))
+
+		rec := middleware.NewRecorder(w)
+		r, route := middleware.WithRoute(r.WithContext(ctx))
+		next.ServeHTTP(rec, r)
+
+		if pattern := route(); pattern != "" {
+			span.SetName(pattern)
+			span.SetAttributes(attribute.String("http.route", pattern))
+		}
+		status := rec.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+	})
+}